@@ -12,22 +12,57 @@ var C Config
 
 // Config defines the main configuration object.
 type Config struct {
-	Port              string `mapstructure:"server_port"`
-	JWTKey            string `mapstructure:"jwt_key"`
-	Keyseed           string `mapstructure:"keyseed"`
-	RedisHost         string `mapstructure:"redis_host"`
-	RedisPort         int    `mapstructure:"redis_port"`
-	RedisMaxActive    int    `mapstructure:"redis_max_active"`
-	RedisMaxIdle      int    `mapstructure:"redis_max_idle"`
-	DatabaseHost      string `mapstructure:"database_host"`
-	DatabasePort      int    `mapstructure:"database_port"`
-	DatabaseUser      string `mapstructure:"database_user"`
-	DatabasePassword  string `mapstructure:"database_password"`
-	DatabaseName      string `mapstructure:"database_name"`
-	WorkerNamespace   string `mapstructure:"worker_namespace"`
-	WorkerJobName     string `mapstructure:"worker_job_name"`
-	WorkerConcurrency uint   `mapstructure:"worker_concurrency"`
-	WorkDirectory     string `mapstructure:"work_dir"`
+	Port               string `mapstructure:"server_port"`
+	JWTKey             string `mapstructure:"jwt_key"`
+	Keyseed            string `mapstructure:"keyseed"`
+	RedisHost          string `mapstructure:"redis_host"`
+	RedisPort          int    `mapstructure:"redis_port"`
+	RedisMaxActive     int    `mapstructure:"redis_max_active"`
+	RedisMaxIdle       int    `mapstructure:"redis_max_idle"`
+	DatabaseHost       string `mapstructure:"database_host"`
+	DatabasePort       int    `mapstructure:"database_port"`
+	DatabaseUser       string `mapstructure:"database_user"`
+	DatabasePassword   string `mapstructure:"database_password"`
+	DatabaseName       string `mapstructure:"database_name"`
+	WorkerNamespace    string `mapstructure:"worker_namespace"`
+	WorkerJobName      string `mapstructure:"worker_job_name"`
+	WorkerConcurrency  uint   `mapstructure:"worker_concurrency"`
+	WorkerSize         string `mapstructure:"worker_size"` // Machine size slug.
+	WorkDirectory      string `mapstructure:"work_dir"`
+	S3PartSize         int64  `mapstructure:"s3_part_size"` // In MB.
+	S3Concurrency      int    `mapstructure:"s3_concurrency"`
+	WatcherInterval    int    `mapstructure:"watcher_interval"` // In seconds.
+	WebhookConcurrency uint   `mapstructure:"webhook_concurrency"`
+	RetentionInterval  int    `mapstructure:"retention_interval"`  // In seconds.
+	IdempotencyWindow  int    `mapstructure:"idempotency_window"`  // In seconds.
+	APIKeyRateLimit    int    `mapstructure:"api_key_rate_limit"`  // Requests per minute.
+	DisableLocalLogin  bool   `mapstructure:"disable_local_login"` // Disables username and password login.
+
+	PasswordMinLength      int    `mapstructure:"password_min_length"`
+	PasswordMinClasses     int    `mapstructure:"password_min_classes"`   // Of lowercase, uppercase, digits and symbols.
+	PasswordBreachedList   string `mapstructure:"password_breached_list"` // File of breached passwords or SHA-1 hashes.
+	BcryptCost             int    `mapstructure:"bcrypt_cost"`
+	LoginLockoutAttempts   int    `mapstructure:"login_lockout_attempts"`    // Failed logins per username before lockout.
+	LoginLockoutIPAttempts int    `mapstructure:"login_lockout_ip_attempts"` // Failed logins per IP before lockout.
+	LoginLockoutBase       int    `mapstructure:"login_lockout_base"`        // In seconds, doubled on each further failure.
+	LoginLockoutMax        int    `mapstructure:"login_lockout_max"`         // In seconds.
+
+	OIDCIssuer         string `mapstructure:"oidc_issuer"`
+	OIDCClientID       string `mapstructure:"oidc_client_id"`
+	OIDCClientSecret   string `mapstructure:"oidc_client_secret"`
+	OIDCRedirectURL    string `mapstructure:"oidc_redirect_url"`
+	OIDCScopes         string `mapstructure:"oidc_scopes"` // Space separated.
+	OIDCUsernameClaim  string `mapstructure:"oidc_username_claim"`
+	OIDCGroupsClaim    string `mapstructure:"oidc_groups_claim"`
+	OIDCAdminGroups    string `mapstructure:"oidc_admin_groups"`    // Comma separated.
+	OIDCOperatorGroups string `mapstructure:"oidc_operator_groups"` // Comma separated.
+	OIDCDefaultRole    string `mapstructure:"oidc_default_role"`    // Empty denies unmapped users.
+
+	IngestQueueURL  string `mapstructure:"ingest_queue_url"`
+	IngestRegion    string `mapstructure:"ingest_region"`
+	IngestEndpoint  string `mapstructure:"ingest_endpoint"`
+	IngestAccessKey string `mapstructure:"ingest_access_key"`
+	IngestSecretKey string `mapstructure:"ingest_secret_key"`
 
 	CloudinitRedisHost        string `mapstructure:"cloudinit_redis_host"`
 	CloudinitRedisPort        int    `mapstructure:"cloudinit_redis_port"`
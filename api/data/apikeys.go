@@ -0,0 +1,169 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// APIKeys represents the APIKeys database operations.
+type APIKeys interface {
+	GetAPIKeys(orgID int64, offset, count int) *[]types.APIKey
+	GetAPIKeysCount(orgID int64) int
+	GetAPIKeyByID(orgID, id int64) (*types.APIKey, error)
+	GetAPIKeyByHash(hash string) (*types.APIKey, error)
+	CreateAPIKey(key types.APIKey) (*types.APIKey, error)
+	RevokeAPIKeyByID(id int64) error
+	UpdateAPIKeyLastUsedByID(id int64) error
+}
+
+// APIKeysOp represents the API keys operations.
+type APIKeysOp struct {
+	a *APIKeys
+}
+
+var _ APIKeys = &APIKeysOp{}
+
+// The role of a key is the service account's role in the key's organization.
+const apiKeysSelect = `
+      SELECT
+        api_keys.*,
+        users.username,
+        COALESCE(organization_members.role, users.role) "role",
+        users.active
+      FROM api_keys
+      JOIN users ON users.id = api_keys.user_id
+      LEFT JOIN organization_members
+        ON organization_members.org_id = api_keys.org_id
+        AND organization_members.user_id = api_keys.user_id`
+
+// GetAPIKeys Gets the API keys of an organization.
+func (a APIKeysOp) GetAPIKeys(orgID int64, offset, count int) *[]types.APIKey {
+	const query = apiKeysSelect + `
+      WHERE api_keys.org_id = $1
+      ORDER BY api_keys.id DESC
+      LIMIT $2 OFFSET $3`
+
+	db, _ := ConnectDB()
+	keys := []types.APIKey{}
+	err := db.Select(&keys, query, orgID, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &keys
+}
+
+// GetAPIKeysCount Gets a count of the API keys of an organization.
+func (a APIKeysOp) GetAPIKeysCount(orgID int64) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM api_keys WHERE org_id = $1`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetAPIKeyByID Gets an API key of an organization by ID.
+func (a APIKeysOp) GetAPIKeyByID(orgID, id int64) (*types.APIKey, error) {
+	const query = apiKeysSelect + `
+      WHERE api_keys.org_id = $1 AND api_keys.id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	key := types.APIKey{}
+	err := db.Get(&key, query, orgID, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByHash Gets an unrevoked and unexpired API key by its hash.
+func (a APIKeysOp) GetAPIKeyByHash(hash string) (*types.APIKey, error) {
+	const query = apiKeysSelect + `
+      WHERE api_keys.key_hash = $1
+        AND api_keys.revoked = false
+        AND (api_keys.expires_date IS NULL OR api_keys.expires_date > now())`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	key := types.APIKey{}
+	err := db.Get(&key, query, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateAPIKey creates an API key.
+func (a APIKeysOp) CreateAPIKey(key types.APIKey) (*types.APIKey, error) {
+	const query = `
+      INSERT INTO
+        api_keys (user_id,name,prefix,key_hash,created_by,expires_date,rate_limit,org_id)
+      VALUES (:user_id,:name,:prefix,:key_hash,:created_by,:expires_date,:rate_limit,:org_id)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&key).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	key.ID = id
+
+	return &key, nil
+}
+
+// RevokeAPIKeyByID Revokes an API key by ID.
+func (a APIKeysOp) RevokeAPIKeyByID(id int64) error {
+	const query = `UPDATE api_keys SET revoked = true WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UpdateAPIKeyLastUsedByID Updates the last used date of an API key. Writes
+// are throttled to one a minute so busy clients don't update it per request.
+func (a APIKeysOp) UpdateAPIKeyLastUsedByID(id int64) error {
+	const query = `
+      UPDATE api_keys
+      SET last_used_date = now()
+      WHERE id = $1
+        AND (last_used_date IS NULL OR last_used_date < now() - interval '1 minute')`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
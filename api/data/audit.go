@@ -0,0 +1,135 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alfg/openencoder/api/types"
+	"github.com/lib/pq"
+)
+
+// Audit represents the audit log database operations. The audit log is
+// append-only, so entries can't be updated or deleted.
+type Audit interface {
+	CreateAuditEntry(entry types.AuditEntry) error
+	GetAuditEntries(filter AuditFilter) *[]types.AuditEntry
+	GetAuditEntriesCount(filter AuditFilter) int
+}
+
+// AuditOp represents the audit log operations.
+type AuditOp struct {
+	a *Audit
+}
+
+var _ Audit = &AuditOp{}
+
+// AuditFilter defines the filters and page for listing audit log entries.
+type AuditFilter struct {
+	Actor         string
+	Action        []string
+	TargetType    string
+	TargetID      string
+	OrgID         int64
+	Success       *bool
+	CreatedAfter  string
+	CreatedBefore string
+
+	Offset int
+	Count  int
+}
+
+// where returns the WHERE clause and its arguments.
+func (f AuditFilter) where() (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if len(f.Action) > 0 {
+		add("action = ANY($%d)", pq.Array(f.Action))
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.OrgID != 0 {
+		add("org_id = $%d", f.OrgID)
+	}
+	if f.Success != nil {
+		add("success = $%d", *f.Success)
+	}
+	if f.CreatedAfter != "" {
+		add("created_date >= $%d", f.CreatedAfter)
+	}
+	if f.CreatedBefore != "" {
+		add("created_date < $%d", f.CreatedBefore)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// CreateAuditEntry appends an entry to the audit log.
+func (a AuditOp) CreateAuditEntry(entry types.AuditEntry) error {
+	const query = `
+      INSERT INTO
+        audit_log (actor,org_id,ip,action,target_type,target_id,success,message,changes)
+      VALUES (:actor,:org_id,:ip,:action,:target_type,:target_id,:success,:message,:changes)`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.NamedExec(query, &entry)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetAuditEntries Gets audit log entries, newest first.
+func (a AuditOp) GetAuditEntries(filter AuditFilter) *[]types.AuditEntry {
+	const query = `
+      SELECT * FROM audit_log
+      %s
+      ORDER BY id DESC
+      LIMIT %d OFFSET %d`
+
+	where, args := filter.where()
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	entries := []types.AuditEntry{}
+	err := db.Select(&entries, fmt.Sprintf(query, where, filter.Count, filter.Offset), args...)
+	if err != nil {
+		log.Error(err)
+	}
+	return &entries
+}
+
+// GetAuditEntriesCount Gets a count of audit log entries.
+func (a AuditOp) GetAuditEntriesCount(filter AuditFilter) int {
+	const query = `SELECT COUNT(*) FROM audit_log %s`
+
+	where, args := filter.where()
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	var count int
+	err := db.Get(&count, fmt.Sprintf(query, where), args...)
+	if err != nil {
+		log.Error(err)
+	}
+	return count
+}
@@ -2,18 +2,42 @@ package data
 
 // Data represents the available database tables.
 type Data struct {
-	Presets  Presets
-	Settings Settings
-	Jobs     Jobs
-	Users    Users
+	Presets       Presets
+	Settings      Settings
+	Jobs          Jobs
+	Users         Users
+	Storage       StorageProfiles
+	Watchers      Watchers
+	Ingest        Ingest
+	Webhooks      Webhooks
+	Notifications Notifications
+	Workflows     Workflows
+	APIKeys       APIKeys
+	Roles         Roles
+	Organizations Organizations
+	Quotas        Quotas
+	Audit         Audit
+	Invitations   Invitations
 }
 
 // New creates a new database instance.
 func New() *Data {
 	return &Data{
-		Presets:  &PresetsOp{},
-		Settings: &SettingsOp{},
-		Jobs:     &JobsOp{},
-		Users:    &UsersOp{},
+		Presets:       &PresetsOp{},
+		Settings:      &SettingsOp{},
+		Jobs:          &JobsOp{},
+		Users:         &UsersOp{},
+		Storage:       &StorageProfilesOp{},
+		Watchers:      &WatchersOp{},
+		Ingest:        &IngestOp{},
+		Webhooks:      &WebhooksOp{},
+		Notifications: &NotificationsOp{},
+		Workflows:     &WorkflowsOp{},
+		APIKeys:       &APIKeysOp{},
+		Roles:         &RolesOp{},
+		Organizations: &OrganizationsOp{},
+		Quotas:        &QuotasOp{},
+		Audit:         &AuditOp{},
+		Invitations:   &InvitationsOp{},
 	}
 }
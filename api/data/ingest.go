@@ -0,0 +1,210 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Ingest represents the ingest rules and events database operations.
+type Ingest interface {
+	GetIngestRules(orgID int64, offset, count int) *[]types.IngestRule
+	GetIngestRulesCount(orgID int64) int
+	GetIngestRuleByID(orgID int64, id int) (*types.IngestRule, error)
+	GetActiveIngestRulesByBucket(bucket string) (*[]types.IngestRule, error)
+	CreateIngestRule(rule types.IngestRule) (*types.IngestRule, error)
+	UpdateIngestRuleByID(id int, rule types.IngestRule) (*types.IngestRule, error)
+	DeleteIngestRuleByID(id int) error
+	CreateIngestEvent(bucket, key, eventID string, ruleID int64, preset string) (bool, error)
+	DeleteIngestEvent(bucket, key, eventID string, ruleID int64, preset string) error
+}
+
+// IngestOp represents the ingest operations.
+type IngestOp struct {
+	i *Ingest
+}
+
+var _ Ingest = &IngestOp{}
+
+// GetIngestRules Gets the ingest rules of an organization.
+func (i IngestOp) GetIngestRules(orgID int64, offset, count int) *[]types.IngestRule {
+	const query = `
+      SELECT * FROM ingest_rules
+      WHERE org_id = $1
+      ORDER BY id DESC
+      LIMIT $2 OFFSET $3`
+
+	db, _ := ConnectDB()
+	rules := []types.IngestRule{}
+	err := db.Select(&rules, query, orgID, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &rules
+}
+
+// GetIngestRulesCount Gets a count of the ingest rules of an organization.
+func (i IngestOp) GetIngestRulesCount(orgID int64) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM ingest_rules WHERE org_id = $1`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetIngestRuleByID Gets an ingest rule of an organization by ID.
+func (i IngestOp) GetIngestRuleByID(orgID int64, id int) (*types.IngestRule, error) {
+	const query = `
+      SELECT *
+      FROM ingest_rules
+      WHERE org_id = $1 AND id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	rule := types.IngestRule{}
+	err := db.Get(&rule, query, orgID, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetActiveIngestRulesByBucket Gets the active ingest rules of all
+// organizations for a bucket.
+func (i IngestOp) GetActiveIngestRulesByBucket(bucket string) (*[]types.IngestRule, error) {
+	const query = `
+      SELECT * FROM ingest_rules
+      WHERE active = true AND bucket = $1
+      ORDER BY id ASC`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	rules := []types.IngestRule{}
+	err := db.Select(&rules, query, bucket)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// CreateIngestRule creates an ingest rule.
+func (i IngestOp) CreateIngestRule(rule types.IngestRule) (*types.IngestRule, error) {
+	const query = `
+      INSERT INTO
+        ingest_rules (name,bucket,prefix,glob,presets,source_profile,destination,dest_profile,active,org_id)
+      VALUES (:name,:bucket,:prefix,:glob,:presets,:source_profile,:destination,:dest_profile,:active,:org_id)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&rule).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	rule.ID = id
+
+	return &rule, nil
+}
+
+// UpdateIngestRuleByID Update ingest rule by ID.
+func (i IngestOp) UpdateIngestRuleByID(id int, rule types.IngestRule) (*types.IngestRule, error) {
+	const query = `
+        UPDATE ingest_rules
+        SET name = :name, bucket = :bucket, prefix = :prefix, glob = :glob,
+          presets = :presets, source_profile = :source_profile,
+          destination = :destination, dest_profile = :dest_profile, active = :active
+        WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	rule.ID = int64(id)
+	tx := db.MustBegin()
+	_, err := tx.NamedExec(query, &rule)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+
+	return &rule, nil
+}
+
+// DeleteIngestRuleByID Deletes an ingest rule by ID.
+func (i IngestOp) DeleteIngestRuleByID(id int) error {
+	const query = `DELETE FROM ingest_rules WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// CreateIngestEvent records an event handled for a preset of a rule. Returns
+// false if it was already recorded, i.e. the event is a redelivery.
+func (i IngestOp) CreateIngestEvent(bucket, key, eventID string, ruleID int64, preset string) (bool, error) {
+	const query = `
+      INSERT INTO
+        ingest_events (bucket,key,event_id,rule_id,preset)
+      VALUES ($1,$2,$3,$4,$5)
+      ON CONFLICT DO NOTHING`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec(query, bucket, key, eventID, ruleID, preset)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteIngestEvent removes an event recorded for a preset of a rule so a
+// redelivery is handled again.
+func (i IngestOp) DeleteIngestEvent(bucket, key, eventID string, ruleID int64, preset string) error {
+	const query = `
+      DELETE FROM ingest_events
+      WHERE bucket = $1 AND key = $2 AND event_id = $3 AND rule_id = $4 AND preset = $5`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, bucket, key, eventID, ruleID, preset)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
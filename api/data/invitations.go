@@ -0,0 +1,198 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// ErrInvitationInvalid is returned for invitations that don't exist, or are
+// used, revoked or expired.
+var ErrInvitationInvalid = errors.New("invalid or expired invitation")
+
+// Invitations represents the invitations database operations.
+type Invitations interface {
+	GetInvitations(offset, count int) *[]types.Invitation
+	GetInvitationsCount() int
+	GetInvitationByID(id int64) (*types.Invitation, error)
+	GetInvitationByHash(hash string) (*types.Invitation, error)
+	CreateInvitation(invitation types.Invitation) (*types.Invitation, error)
+	RevokeInvitationByID(id int64) error
+	CreateUserFromInvitation(hash string, user types.User) (*types.User, *types.Invitation, error)
+}
+
+// InvitationsOp represents the invitations operations.
+type InvitationsOp struct {
+	i *Invitations
+}
+
+var _ Invitations = &InvitationsOp{}
+
+// GetInvitations Gets invitations, newest first.
+func (i InvitationsOp) GetInvitations(offset, count int) *[]types.Invitation {
+	const query = `
+      SELECT * FROM invitations
+      ORDER BY id DESC
+      LIMIT $1 OFFSET $2`
+
+	db, _ := ConnectDB()
+	invitations := []types.Invitation{}
+	err := db.Select(&invitations, query, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &invitations
+}
+
+// GetInvitationsCount Gets a count of all invitations.
+func (i InvitationsOp) GetInvitationsCount() int {
+	var count int
+	const query = `SELECT COUNT(*) FROM invitations`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetInvitationByID Gets an invitation by ID.
+func (i InvitationsOp) GetInvitationByID(id int64) (*types.Invitation, error) {
+	const query = `SELECT * FROM invitations WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	invitation := types.Invitation{}
+	err := db.Get(&invitation, query, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// GetInvitationByHash Gets an unused, unrevoked and unexpired invitation by
+// the hash of its token.
+func (i InvitationsOp) GetInvitationByHash(hash string) (*types.Invitation, error) {
+	const query = `
+      SELECT * FROM invitations
+      WHERE token_hash = $1
+        AND used_date IS NULL
+        AND revoked = false
+        AND expires_date > now()`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	invitation := types.Invitation{}
+	err := db.Get(&invitation, query, hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// CreateInvitation creates an invitation.
+func (i InvitationsOp) CreateInvitation(invitation types.Invitation) (*types.Invitation, error) {
+	const query = `
+      INSERT INTO
+        invitations (token_hash,email,role,org_id,created_by,expires_date)
+      VALUES (:token_hash,:email,:role,:org_id,:created_by,:expires_date)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&invitation).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	invitation.ID = id
+
+	return &invitation, nil
+}
+
+// RevokeInvitationByID Revokes an invitation by ID.
+func (i InvitationsOp) RevokeInvitationByID(id int64) error {
+	const query = `UPDATE invitations SET revoked = true WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// CreateUserFromInvitation uses an invitation by the hash of its token, and
+// creates the user as a member of its organization with its role. The user
+// keeps their system role. The invitation is only used if the user is
+// created, and can't be used again.
+func (i InvitationsOp) CreateUserFromInvitation(hash string, user types.User) (*types.User, *types.Invitation, error) {
+	const query = `
+      UPDATE invitations
+      SET used_by = $2, used_date = now()
+      WHERE token_hash = $1
+        AND used_date IS NULL
+        AND revoked = false
+        AND expires_date > now()
+      RETURNING *`
+	const memberQuery = `
+      UPDATE organization_members
+      SET role = NULLIF($3,'')
+      WHERE org_id = $1 AND user_id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	invitation := types.Invitation{}
+	err := tx.Get(&invitation, query, hash, user.Username)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrInvitationInvalid
+		}
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	user.OrgID = invitation.OrgID
+	user.Pending = false
+	if err := insertUser(tx, &user); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(memberQuery, user.OrgID, user.ID, invitation.Role); err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, nil, err
+	}
+	tx.Commit()
+
+	return &user, &invitation, nil
+}
@@ -1,27 +1,42 @@
 package data
 
 import (
+	"fmt"
+
 	"github.com/alfg/openencoder/api/types"
+	"github.com/lib/pq"
 )
 
 // Jobs represents the Jobs database operations.
 type Jobs interface {
-	GetJobs(offset, count int) *[]types.Job
+	GetJobs(filter JobFilter) *[]types.Job
 	GetJobByID(id int64) (*types.Job, error)
 	GetJobByGUID(id string) (*types.Job, error)
 	GetJobStatusByID(id int64) (string, error)
 	GetJobStatusByGUID(guid string) (string, error)
-	GetJobsCount() int
-	GetJobsStats() (*[]Stats, error)
-	CreateJob(job types.Job) *types.Job
+	GetJobsCount(filter JobFilter) int
+	GetJobsStats(orgID int64) (*[]Stats, error)
+	GetPresetThroughput(orgID int64, preset, workerSize string) (*Throughput, error)
+	GetExpiredJobs(orgID int64, status, before string, afterID int64, count int) (*[]types.Job, error)
+	GetCompletedJobBySource(orgID int64, source, sourceProfile, preset, etag string) (*types.Job, error)
+	GetJobsByIDs(ids []int64) (*[]types.Job, error)
+	GetJobsByWorkflowID(id int64) (*[]types.Job, error)
+	GetWaitingJobs() (*[]types.Job, error)
+	CreateJob(job types.Job) (*types.Job, error)
 	CreateEncode(ed types.Encode) *types.Encode
 	UpdateEncodeProbeByID(id int64, jsonString string) error
 	UpdateEncodeOptionsByID(id int64, options string) error
 	UpdateTransferProgressByID(id int64, progress float64) error
 	UpdateEncodeProgressByID(id int64, progress float64, speed string, fps float64) error
+	UpdateEncodeChecksumsByID(id int64, checksums string) error
+	UpdateEncodeStatsByID(id int64, duration, encodeTime float64, workerSize string) error
 	UpdateJobByID(id int, job types.Job) *types.Job
 	UpdateJobStatusByID(id int, status string) error
 	UpdateJobStatusByGUID(guid string, status string) error
+	UpdateJobOutputByGUID(guid, destination, output string) error
+	UpdateJobSourceETagByGUID(guid, etag string) error
+	UpdateWaitingJobByID(id int64, status, source, sourceProfile string) (bool, error)
+	BulkUpdateJobs(action string, ids []int64, orgID int64, owner string) ([]BulkResult, error)
 }
 
 // JobsOp represents a job operation.
@@ -31,8 +46,8 @@ type JobsOp struct {
 
 var _ Jobs = &JobsOp{}
 
-// GetJobs Gets all jobs.
-func (j JobsOp) GetJobs(offset, count int) *[]types.Job {
+// GetJobs Gets jobs matching a filter.
+func (j JobsOp) GetJobs(filter JobFilter) *[]types.Job {
 	const query = `
 	  SELECT
         jobs.*,
@@ -41,15 +56,23 @@ func (j JobsOp) GetJobs(offset, count int) *[]types.Job {
         encode.options "encode.options",
         encode.progress "encode.progress",
         encode.speed "encode.speed",
-        encode.fps "encode.fps"
+        encode.fps "encode.fps",
+        encode.checksums "encode.checksums"
 	  FROM jobs
       LEFT JOIN encode ON jobs.id = encode.job_id
-	  ORDER BY id DESC
-      LIMIT $1 OFFSET $2`
+      %s
+      ORDER BY %s
+      LIMIT %d OFFSET %d`
+
+	where, args := filter.where(true)
+	offset := filter.Offset
+	if filter.Cursor != nil {
+		offset = 0
+	}
 
 	db, _ := ConnectDB()
 	jobs := []types.Job{}
-	err := db.Select(&jobs, query, count, offset)
+	err := db.Select(&jobs, fmt.Sprintf(query, where, filter.orderBy(), filter.Count, offset), args...)
 	if err != nil {
 		log.Warn(err)
 	}
@@ -67,7 +90,8 @@ func (j JobsOp) GetJobByID(id int64) (*types.Job, error) {
         encode.options "encode.options",
         encode.progress "encode.progress",
         encode.speed "encode.speed",
-        encode.fps "encode.fps"
+        encode.fps "encode.fps",
+        encode.checksums "encode.checksums"
       FROM jobs
       LEFT JOIN encode ON jobs.id = encode.job_id
       WHERE jobs.id = $1`
@@ -93,7 +117,8 @@ func (j JobsOp) GetJobByGUID(id string) (*types.Job, error) {
         encode.options "encode.options",
         encode.progress "encode.progress",
         encode.speed "encode.speed",
-        encode.fps "encode.fps"
+        encode.fps "encode.fps",
+        encode.checksums "encode.checksums"
       FROM jobs
       LEFT JOIN encode ON jobs.id = encode.job_id
       WHERE jobs.guid = $1`
@@ -109,6 +134,121 @@ func (j JobsOp) GetJobByGUID(id string) (*types.Job, error) {
 	return &job, nil
 }
 
+// GetExpiredJobs Gets jobs of an organization with a status created before a
+// date, oldest first. Jobs are paged by ID, from after afterID.
+func (j JobsOp) GetExpiredJobs(orgID int64, status, before string, afterID int64, count int) (*[]types.Job, error) {
+	const query = `
+      SELECT
+        jobs.*,
+        encode.id "encode.id",
+        encode.checksums "encode.checksums"
+      FROM jobs
+      LEFT JOIN encode ON jobs.id = encode.job_id
+      WHERE jobs.org_id = $1 AND jobs.status = $2 AND jobs.created_date < $3
+        AND jobs.id > $4
+      ORDER BY jobs.id ASC
+      LIMIT $5`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	jobs := []types.Job{}
+	err := db.Select(&jobs, query, orgID, status, before, afterID, count)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// GetCompletedJobBySource Gets the latest completed job of an organization for
+// a source version and preset.
+func (j JobsOp) GetCompletedJobBySource(orgID int64, source, sourceProfile, preset, etag string) (*types.Job, error) {
+	const query = `
+      SELECT
+        jobs.*,
+        encode.id "encode.id",
+        encode.checksums "encode.checksums"
+      FROM jobs
+      LEFT JOIN encode ON jobs.id = encode.job_id
+      WHERE jobs.source = $1 AND jobs.source_profile = $2 AND jobs.preset = $3
+        AND jobs.source_etag = $4 AND jobs.status = $5 AND jobs.org_id = $6
+      ORDER BY jobs.id DESC
+      LIMIT 1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	job := types.Job{}
+	err := db.Get(&job, query, source, sourceProfile, preset, etag, types.JobCompleted, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJobsByIDs Gets jobs by ID.
+func (j JobsOp) GetJobsByIDs(ids []int64) (*[]types.Job, error) {
+	const query = `SELECT * FROM jobs WHERE id = ANY($1)`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	jobs := []types.Job{}
+	err := db.Select(&jobs, query, pq.Array(ids))
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// GetJobsByWorkflowID Gets the jobs of a workflow, in step order.
+func (j JobsOp) GetJobsByWorkflowID(id int64) (*[]types.Job, error) {
+	const query = `
+      SELECT
+        jobs.*,
+        encode.id "encode.id",
+        encode.progress "encode.progress",
+        encode.speed "encode.speed",
+        encode.fps "encode.fps",
+        encode.checksums "encode.checksums"
+      FROM jobs
+      LEFT JOIN encode ON jobs.id = encode.job_id
+      WHERE jobs.workflow_id = $1
+      ORDER BY jobs.id ASC`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	jobs := []types.Job{}
+	err := db.Select(&jobs, query, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// GetWaitingJobs Gets the jobs waiting on dependencies.
+func (j JobsOp) GetWaitingJobs() (*[]types.Job, error) {
+	const query = `
+      SELECT * FROM jobs
+      WHERE status = $1
+      ORDER BY id ASC`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	jobs := []types.Job{}
+	err := db.Select(&jobs, query, types.JobWaiting)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &jobs, nil
+}
+
 // GetJobStatusByID Gets a job status by GUID.
 func (j JobsOp) GetJobStatusByID(id int64) (string, error) {
 	var status string
@@ -145,13 +285,15 @@ func (j JobsOp) GetJobStatusByGUID(guid string) (string, error) {
 	return status, nil
 }
 
-// GetJobsCount Gets a count of all jobs.
-func (j JobsOp) GetJobsCount() int {
+// GetJobsCount Gets a count of jobs matching a filter, ignoring the cursor.
+func (j JobsOp) GetJobsCount(filter JobFilter) int {
 	var count int
-	const query = `SELECT COUNT(*) FROM jobs`
+	const query = `SELECT COUNT(*) FROM jobs %s`
+
+	where, args := filter.where(false)
 
 	db, _ := ConnectDB()
-	err := db.Get(&count, query)
+	err := db.Get(&count, fmt.Sprintf(query, where), args...)
 	if err != nil {
 		log.Error(err)
 	}
@@ -165,13 +307,46 @@ type Stats struct {
 	Count  int    `db:"count" json:"count"`
 }
 
-// GetJobsStats Gets a count of each status.
-func (j JobsOp) GetJobsStats() (*[]Stats, error) {
-	const query = `SELECT status, count(status) FROM jobs GROUP BY status, status;`
+// Throughput describes the historical encode speed of a preset, in source
+// seconds encoded per second.
+type Throughput struct {
+	Speed   float64 `db:"speed" json:"speed"`
+	Samples int     `db:"samples" json:"samples"`
+}
+
+// GetPresetThroughput Gets the encode speed of a preset of an organization
+// from its completed jobs, on a worker size if set.
+func (j JobsOp) GetPresetThroughput(orgID int64, preset, workerSize string) (*Throughput, error) {
+	const query = `
+      SELECT
+        COALESCE(SUM(encode.duration) / NULLIF(SUM(encode.encode_time), 0), 0) "speed",
+        COUNT(*) "samples"
+      FROM jobs
+      JOIN encode ON jobs.id = encode.job_id
+      WHERE jobs.preset = $1 AND jobs.status = $2
+        AND encode.encode_time > 0
+        AND ($3 = '' OR encode.worker_size = $3)
+        AND jobs.org_id = $4`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	t := Throughput{}
+	err := db.Get(&t, query, preset, types.JobCompleted, workerSize, orgID)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetJobsStats Gets a count of each status of the jobs of an organization.
+func (j JobsOp) GetJobsStats(orgID int64) (*[]Stats, error) {
+	const query = `SELECT status, count(status) FROM jobs WHERE org_id = $1 GROUP BY status, status;`
 
 	s := []Stats{}
 	db, _ := ConnectDB()
-	err := db.Select(&s, query)
+	err := db.Select(&s, query, orgID)
 	if err != nil {
 		log.Error(err)
 		return &s, err
@@ -196,32 +371,45 @@ func (j JobsOp) GetJobsStats() (*[]Stats, error) {
 }
 
 // CreateJob creates a job in database.
-func (j JobsOp) CreateJob(job types.Job) *types.Job {
+func (j JobsOp) CreateJob(job types.Job) (*types.Job, error) {
 	const query = `
       INSERT INTO
-        jobs (guid,preset,status,source,destination)
-      VALUES (:guid,:preset,:status,:source,:destination)
+        jobs (guid,preset,status,source,destination,source_profile,dest_profile,callback_url,created_by,source_etag,
+          workflow_id,workflow_step,depends_on,source_from,org_id)
+      VALUES (:guid,:preset,:status,:source,:destination,:source_profile,:dest_profile,:callback_url,:created_by,:source_etag,
+          :workflow_id,:workflow_step,:depends_on,:source_from,:org_id)
       RETURNING id`
 
+	if job.DependsOn == nil {
+		job.DependsOn = pq.Int64Array{}
+	}
+
 	db, _ := ConnectDB()
+	defer db.Close()
+
 	tx := db.MustBegin()
 	stmt, err := tx.PrepareNamed(query)
 	if err != nil {
-		log.Error(err.Error())
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
 	}
 
 	var id int64 // Returned ID.
 	err = stmt.QueryRowx(&job).Scan(&id)
 	if err != nil {
-		log.Error(err.Error())
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error(err)
+		return nil, err
 	}
-	tx.Commit()
 
 	// Set to Job type response.
 	job.ID = id
-
-	db.Close()
-	return &job
+	return &job, nil
 }
 
 // CreateEncode creates encode in database.
@@ -324,6 +512,42 @@ func (j JobsOp) UpdateEncodeProgressByID(id int64, progress float64, speed strin
 	return nil
 }
 
+// UpdateEncodeChecksumsByID Update output checksums by ID.
+func (j JobsOp) UpdateEncodeChecksumsByID(id int64, checksums string) error {
+	const query = `UPDATE encode SET checksums = $1 WHERE id = $2`
+
+	db, _ := ConnectDB()
+	tx := db.MustBegin()
+	_, err := tx.Exec(query, checksums, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	tx.Commit()
+
+	db.Close()
+	return nil
+}
+
+// UpdateEncodeStatsByID Update the source duration, encode time and worker
+// size by ID.
+func (j JobsOp) UpdateEncodeStatsByID(id int64, duration, encodeTime float64, workerSize string) error {
+	const query = `
+      UPDATE encode
+      SET duration = $1, encode_time = $2, worker_size = $3
+      WHERE id = $4`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, duration, encodeTime, workerSize, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
 // UpdateJobByID Update job by ID.
 func (j JobsOp) UpdateJobByID(id int, job types.Job) *types.Job {
 	const query = `UPDATE jobs SET status = :status WHERE id = :id`
@@ -373,3 +597,174 @@ func (j JobsOp) UpdateJobStatusByGUID(guid string, status string) error {
 	db.Close()
 	return nil
 }
+
+// UpdateJobOutputByGUID Update the resolved job destination and output name by GUID.
+func (j JobsOp) UpdateJobOutputByGUID(guid, destination, output string) error {
+	const query = `UPDATE jobs SET resolved_destination = $1, output = $2 WHERE guid = $3`
+
+	db, _ := ConnectDB()
+	tx := db.MustBegin()
+	_, err := tx.Exec(query, destination, output, guid)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	tx.Commit()
+
+	db.Close()
+	return nil
+}
+
+// UpdateJobSourceETagByGUID Update the source ETag by GUID.
+func (j JobsOp) UpdateJobSourceETagByGUID(guid, etag string) error {
+	const query = `UPDATE jobs SET source_etag = $1 WHERE guid = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, etag, guid)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UpdateWaitingJobByID Update the status, source and source profile of a
+// waiting job by ID. Returns false if the job is no longer waiting.
+func (j JobsOp) UpdateWaitingJobByID(id int64, status, source, sourceProfile string) (bool, error) {
+	const query = `
+      UPDATE jobs SET status = $1, source = $2, source_profile = $5
+      WHERE id = $3 AND status = $4`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec(query, status, source, id, types.JobWaiting, sourceProfile)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Bulk job actions.
+const (
+	BulkCancel  = "cancel"
+	BulkRestart = "restart"
+	BulkDelete  = "delete"
+)
+
+// Bulk job action results.
+const (
+	BulkOK       = "ok"
+	BulkSkipped  = "skipped"
+	BulkNotFound = "not_found"
+	BulkError    = "error"
+)
+
+// BulkResult describes the result of a bulk action on a job.
+type BulkResult struct {
+	ID      int64  `json:"id"`
+	GUID    string `json:"guid,omitempty"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// bulkAllowed lists the job statuses each bulk action applies to.
+var bulkAllowed = map[string][]string{
+	BulkCancel:  {types.JobQueued, types.JobDownloading, types.JobProbing, types.JobEncoding, types.JobUploading, types.JobRestarting, types.JobWaiting},
+	BulkRestart: {types.JobError, types.JobCancelled, types.JobCompleted},
+	BulkDelete:  {types.JobError, types.JobCancelled, types.JobCompleted},
+}
+
+// BulkUpdateJobs applies a bulk action to jobs by ID in a single transaction.
+// Jobs in a status the action does not apply to are skipped. If an
+// organization is set, jobs of other organizations are not found, and if an
+// owner is set, jobs created by other users.
+func (j JobsOp) BulkUpdateJobs(action string, ids []int64, orgID int64, owner string) ([]BulkResult, error) {
+	const selectQuery = `
+      SELECT id, guid, status FROM jobs
+      WHERE id = ANY($1) AND ($3 = 0 OR org_id = $3) AND ($2 = '' OR created_by = $2)
+      FOR UPDATE`
+
+	allowed, ok := bulkAllowed[action]
+	if !ok {
+		return nil, fmt.Errorf("invalid action: %s", action)
+	}
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	rows := []types.Job{}
+	if err := tx.Select(&rows, selectQuery, pq.Array(ids), owner, orgID); err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	found := map[int64]types.Job{}
+	for _, r := range rows {
+		found[r.ID] = r
+	}
+
+	results := []BulkResult{}
+	apply := []int64{}
+	for _, id := range ids {
+		job, ok := found[id]
+		if !ok {
+			results = append(results, BulkResult{ID: id, Result: BulkNotFound})
+			continue
+		}
+		if !containsString(allowed, job.Status) {
+			results = append(results, BulkResult{
+				ID:      id,
+				GUID:    job.GUID,
+				Result:  BulkSkipped,
+				Message: fmt.Sprintf("cannot %s a job with status %s", action, job.Status),
+			})
+			continue
+		}
+		results = append(results, BulkResult{ID: id, GUID: job.GUID, Result: BulkOK})
+		apply = append(apply, id)
+	}
+
+	var err error
+	switch action {
+	case BulkCancel:
+		_, err = tx.Exec(`UPDATE jobs SET status = $1 WHERE id = ANY($2)`, types.JobCancelled, pq.Array(apply))
+	case BulkRestart:
+		_, err = tx.Exec(`UPDATE jobs SET status = $1 WHERE id = ANY($2)`, types.JobRestarting, pq.Array(apply))
+	case BulkDelete:
+		if _, err = tx.Exec(`DELETE FROM encode WHERE job_id = ANY($1)`, pq.Array(apply)); err == nil {
+			_, err = tx.Exec(`DELETE FROM jobs WHERE id = ANY($1)`, pq.Array(apply))
+		}
+	}
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+
+	return results, nil
+}
+
+// BulkAllowed checks a bulk action applies to a job status.
+func BulkAllowed(action, status string) bool {
+	return containsString(bulkAllowed[action], status)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
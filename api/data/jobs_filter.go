@@ -0,0 +1,164 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alfg/openencoder/api/types"
+	"github.com/lib/pq"
+)
+
+// JobSortFields are the fields jobs can be sorted by, mapped to their columns.
+var JobSortFields = map[string]string{
+	"id":           "jobs.id",
+	"created_date": "jobs.created_date",
+	"preset":       "jobs.preset",
+	"status":       "jobs.status",
+}
+
+// JobFilter defines the filters, sort order and page for listing jobs.
+type JobFilter struct {
+	OrgID         int64 // Organization of the jobs, if set.
+	Status        []string
+	Preset        string
+	Source        string // Source substring.
+	Destination   string // Destination substring.
+	CreatedBy     string
+	CreatedAfter  string
+	CreatedBefore string
+
+	Sort      string     // Sort field in JobSortFields, defaults to id.
+	Ascending bool       // Sort order, defaults to descending.
+	Cursor    *JobCursor // Keyset position. Used instead of Offset if set.
+	Offset    int
+	Count     int
+}
+
+// JobCursor defines the keyset position after a job in a sorted job list.
+type JobCursor struct {
+	Value string `json:"v"` // Sort field value of the job.
+	ID    int64  `json:"id"`
+}
+
+// NewJobCursor creates the cursor positioned after a job.
+func NewJobCursor(sort string, job types.Job) *JobCursor {
+	c := &JobCursor{ID: job.ID}
+	switch sort {
+	case "created_date":
+		c.Value = job.CreatedDate
+	case "preset":
+		c.Value = job.Preset
+	case "status":
+		c.Value = job.Status
+	default:
+		c.Value = strconv.FormatInt(job.ID, 10)
+	}
+	return c
+}
+
+// Encode encodes the cursor as an opaque string.
+func (c *JobCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeJobCursor decodes a cursor from Encode.
+func DecodeJobCursor(s string) (*JobCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	c := &JobCursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+func (f JobFilter) sortColumn() string {
+	if col, ok := JobSortFields[f.Sort]; ok {
+		return col
+	}
+	return JobSortFields["id"]
+}
+
+func (f JobFilter) direction() string {
+	if f.Ascending {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// orderBy returns the ORDER BY clause, with the ID breaking ties.
+func (f JobFilter) orderBy() string {
+	col := f.sortColumn()
+	if col == JobSortFields["id"] {
+		return col + " " + f.direction()
+	}
+	return fmt.Sprintf("%s %s, jobs.id %s", col, f.direction(), f.direction())
+}
+
+// where returns the WHERE clause and its arguments. The cursor position is
+// included if withCursor is set.
+func (f JobFilter) where(withCursor bool) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.OrgID != 0 {
+		add("jobs.org_id = $%d", f.OrgID)
+	}
+	if len(f.Status) > 0 {
+		add("jobs.status = ANY($%d)", pq.Array(f.Status))
+	}
+	if f.Preset != "" {
+		add("jobs.preset = $%d", f.Preset)
+	}
+	if f.Source != "" {
+		add("jobs.source ILIKE $%d", "%"+escapeLike(f.Source)+"%")
+	}
+	if f.Destination != "" {
+		add("(jobs.destination ILIKE $%[1]d OR jobs.resolved_destination ILIKE $%[1]d)", "%"+escapeLike(f.Destination)+"%")
+	}
+	if f.CreatedBy != "" {
+		add("jobs.created_by = $%d", f.CreatedBy)
+	}
+	if f.CreatedAfter != "" {
+		add("jobs.created_date >= $%d", f.CreatedAfter)
+	}
+	if f.CreatedBefore != "" {
+		add("jobs.created_date < $%d", f.CreatedBefore)
+	}
+
+	// Seek past the cursor using a row comparison on the sort column and ID.
+	if withCursor && f.Cursor != nil {
+		op := "<"
+		if f.Ascending {
+			op = ">"
+		}
+		col := f.sortColumn()
+		if col == JobSortFields["id"] {
+			add("jobs.id "+op+" $%d", f.Cursor.ID)
+		} else {
+			args = append(args, f.Cursor.Value, f.Cursor.ID)
+			conds = append(conds, fmt.Sprintf("(%s, jobs.id) %s ($%d, $%d)", col, op, len(args)-1, len(args)))
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike escapes the LIKE pattern characters in a value.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
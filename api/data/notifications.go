@@ -0,0 +1,196 @@
+package data
+
+import (
+	"encoding/json"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Notifications represents the notification channels database operations.
+type Notifications interface {
+	GetNotificationChannels(offset, count int) *[]types.NotificationChannel
+	GetNotificationChannelsCount() int
+	GetNotificationChannelByID(id int64) (*types.NotificationChannel, error)
+	GetActiveNotificationChannelsByEvent(event string) (*[]types.NotificationChannel, error)
+	CreateNotificationChannel(channel types.NotificationChannel) (*types.NotificationChannel, error)
+	UpdateNotificationChannelByID(id int64, channel types.NotificationChannel) (*types.NotificationChannel, error)
+	DeleteNotificationChannelByID(id int64) error
+}
+
+// NotificationsOp represents the notification channels operations.
+type NotificationsOp struct {
+	n *Notifications
+}
+
+var _ Notifications = &NotificationsOp{}
+
+// GetNotificationChannels Gets all notification channels.
+func (n NotificationsOp) GetNotificationChannels(offset, count int) *[]types.NotificationChannel {
+	const query = `
+      SELECT * FROM notification_channels
+      ORDER BY id DESC
+      LIMIT $1 OFFSET $2`
+
+	db, _ := ConnectDB()
+	channels := []types.NotificationChannel{}
+	err := db.Select(&channels, query, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+
+	for i := range channels {
+		decryptNotificationConfig(&channels[i])
+	}
+	return &channels
+}
+
+// GetNotificationChannelsCount Gets a count of all notification channels.
+func (n NotificationsOp) GetNotificationChannelsCount() int {
+	var count int
+	const query = `SELECT COUNT(*) FROM notification_channels`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetNotificationChannelByID Gets a notification channel by ID.
+func (n NotificationsOp) GetNotificationChannelByID(id int64) (*types.NotificationChannel, error) {
+	const query = `
+      SELECT *
+      FROM notification_channels
+      WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	channel := types.NotificationChannel{}
+	err := db.Get(&channel, query, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	decryptNotificationConfig(&channel)
+	return &channel, nil
+}
+
+// GetActiveNotificationChannelsByEvent Gets the active channels routed to an event.
+func (n NotificationsOp) GetActiveNotificationChannelsByEvent(event string) (*[]types.NotificationChannel, error) {
+	const query = `
+      SELECT * FROM notification_channels
+      WHERE active = true AND $1 = ANY(events)
+      ORDER BY id ASC`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	channels := []types.NotificationChannel{}
+	err := db.Select(&channels, query, event)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	for i := range channels {
+		decryptNotificationConfig(&channels[i])
+	}
+	return &channels, nil
+}
+
+// CreateNotificationChannel creates a notification channel. The config is encrypted.
+func (n NotificationsOp) CreateNotificationChannel(channel types.NotificationChannel) (*types.NotificationChannel, error) {
+	const query = `
+      INSERT INTO
+        notification_channels (name,type,config,events,template,active)
+      VALUES (:name,:type,:config,:events,:template,:active)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	if err := encryptNotificationConfig(&channel); err != nil {
+		return nil, err
+	}
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&channel).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	channel.ID = id
+
+	return &channel, nil
+}
+
+// UpdateNotificationChannelByID Update notification channel by ID. The config is encrypted.
+func (n NotificationsOp) UpdateNotificationChannelByID(id int64, channel types.NotificationChannel) (*types.NotificationChannel, error) {
+	const query = `
+        UPDATE notification_channels
+        SET name = :name, type = :type, config = :config, events = :events,
+          template = :template, active = :active
+        WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	channel.ID = id
+	if err := encryptNotificationConfig(&channel); err != nil {
+		return nil, err
+	}
+
+	tx := db.MustBegin()
+	_, err := tx.NamedExec(query, &channel)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+
+	return &channel, nil
+}
+
+// DeleteNotificationChannelByID Deletes a notification channel by ID.
+func (n NotificationsOp) DeleteNotificationChannelByID(id int64) error {
+	const query = `DELETE FROM notification_channels WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func encryptNotificationConfig(c *types.NotificationChannel) error {
+	b, err := json.Marshal(c.Config)
+	if err != nil {
+		return err
+	}
+	c.ConfigData = encryptValue(string(b))
+	return nil
+}
+
+func decryptNotificationConfig(c *types.NotificationChannel) {
+	if err := json.Unmarshal([]byte(decryptValue(c.ConfigData)), &c.Config); err != nil {
+		log.Error(err)
+	}
+}
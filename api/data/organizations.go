@@ -0,0 +1,277 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Organizations represents the Organizations database operations.
+type Organizations interface {
+	GetOrganizations(offset, count int) *[]types.Organization
+	GetOrganizationsCount() int
+	GetOrganizationByID(id int64) (*types.Organization, error)
+	CreateOrganization(org types.Organization) (*types.Organization, error)
+	UpdateOrganizationByID(id int64, org types.Organization) (*types.Organization, error)
+	DeleteOrganizationByID(id int64) error
+	GetOrganizationJobsCount(id int64) int
+	GetMembers(orgID int64) *[]types.OrganizationMember
+	GetMember(orgID, userID int64) (*types.OrganizationMember, error)
+	GetUserMemberships(userID int64) *[]types.OrganizationMember
+	SetMember(orgID, userID int64, role string) error
+	DeleteMember(orgID, userID int64) error
+}
+
+// OrganizationsOp represents the organizations operations.
+type OrganizationsOp struct {
+	o *Organizations
+}
+
+var _ Organizations = &OrganizationsOp{}
+
+const membersQuery = `
+      SELECT
+        organization_members.org_id,
+        organizations.name "org_name",
+        organization_members.user_id,
+        users.username,
+        COALESCE(organization_members.role, '') "role",
+        users.role "user_role",
+        organization_members.created_date
+      FROM organization_members
+      JOIN organizations ON organizations.id = organization_members.org_id
+      JOIN users ON users.id = organization_members.user_id`
+
+// GetOrganizations Gets organizations with their count of members.
+func (o OrganizationsOp) GetOrganizations(offset, count int) *[]types.Organization {
+	const query = `
+      SELECT
+        organizations.*,
+        (SELECT COUNT(*) FROM organization_members
+          WHERE organization_members.org_id = organizations.id) "members_count"
+      FROM organizations
+      ORDER BY id
+      LIMIT $1 OFFSET $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	orgs := []types.Organization{}
+	err := db.Select(&orgs, query, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	return &orgs
+}
+
+// GetOrganizationsCount Gets a count of organizations.
+func (o OrganizationsOp) GetOrganizationsCount() int {
+	var count int
+	const query = `SELECT COUNT(*) FROM organizations`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	err := db.Get(&count, query)
+	if err != nil {
+		log.Error(err)
+	}
+	return count
+}
+
+// GetOrganizationByID Gets an organization by ID.
+func (o OrganizationsOp) GetOrganizationByID(id int64) (*types.Organization, error) {
+	const query = `
+      SELECT
+        organizations.*,
+        (SELECT COUNT(*) FROM organization_members
+          WHERE organization_members.org_id = organizations.id) "members_count"
+      FROM organizations
+      WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	org := types.Organization{}
+	err := db.Get(&org, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// CreateOrganization creates an organization with the default settings.
+func (o OrganizationsOp) CreateOrganization(org types.Organization) (*types.Organization, error) {
+	const query = `
+      INSERT INTO
+        organizations (name)
+      VALUES (:name)
+      RETURNING id`
+
+	const settingQuery = `
+      INSERT INTO
+        settings (settings_option_id, value, org_id)
+      SELECT id, $2, $3 FROM settings_option WHERE name = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&org).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	for name, value := range types.OrganizationDefaultSettings {
+		if _, err := tx.Exec(settingQuery, name, value, id); err != nil {
+			log.Error(err)
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	tx.Commit()
+	org.ID = id
+
+	return &org, nil
+}
+
+// UpdateOrganizationByID Updates the name of an organization by ID.
+func (o OrganizationsOp) UpdateOrganizationByID(id int64, org types.Organization) (*types.Organization, error) {
+	const query = `UPDATE organizations SET name = :name WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	org.ID = id
+	_, err := db.NamedExec(query, &org)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &org, nil
+}
+
+// DeleteOrganizationByID Deletes an organization by ID, with its members,
+// settings, presets, storage profiles, watchers, ingest rules, workflows and
+// API keys.
+func (o OrganizationsOp) DeleteOrganizationByID(id int64) error {
+	const query = `DELETE FROM organizations WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetOrganizationJobsCount Gets a count of the jobs of an organization.
+func (o OrganizationsOp) GetOrganizationJobsCount(id int64) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM jobs WHERE org_id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	err := db.Get(&count, query, id)
+	if err != nil {
+		log.Error(err)
+	}
+	return count
+}
+
+// GetMembers Gets the members of an organization.
+func (o OrganizationsOp) GetMembers(orgID int64) *[]types.OrganizationMember {
+	const query = membersQuery + `
+      WHERE organization_members.org_id = $1
+      ORDER BY users.username`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	members := []types.OrganizationMember{}
+	err := db.Select(&members, query, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	return &members
+}
+
+// GetMember Gets a user's membership of an organization.
+func (o OrganizationsOp) GetMember(orgID, userID int64) (*types.OrganizationMember, error) {
+	const query = membersQuery + `
+      WHERE organization_members.org_id = $1 AND organization_members.user_id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	member := types.OrganizationMember{}
+	err := db.Get(&member, query, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetUserMemberships Gets the organizations a user is a member of.
+func (o OrganizationsOp) GetUserMemberships(userID int64) *[]types.OrganizationMember {
+	const query = membersQuery + `
+      WHERE organization_members.user_id = $1
+      ORDER BY organization_members.org_id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	members := []types.OrganizationMember{}
+	err := db.Select(&members, query, userID)
+	if err != nil {
+		log.Error(err)
+	}
+	return &members
+}
+
+// SetMember Adds a user to an organization, or updates their role in it. An
+// empty role uses the user's role.
+func (o OrganizationsOp) SetMember(orgID, userID int64, role string) error {
+	const query = `
+      INSERT INTO
+        organization_members (org_id,user_id,role)
+      VALUES ($1,$2,NULLIF($3,''))
+      ON CONFLICT (org_id,user_id) DO UPDATE SET role = EXCLUDED.role`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, orgID, userID, role)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteMember Removes a user from an organization.
+func (o OrganizationsOp) DeleteMember(orgID, userID int64) error {
+	const query = `DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, orgID, userID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
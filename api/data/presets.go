@@ -6,10 +6,10 @@ import (
 
 // Presets represents the Presets database operations.
 type Presets interface {
-	GetPresets(offset, count int) *[]types.Preset
-	GetPresetByID(id int) (*types.Preset, error)
-	GetPresetByName(name string) (*types.Preset, error)
-	GetPresetsCount() int
+	GetPresets(orgID int64, offset, count int) *[]types.Preset
+	GetPresetByID(orgID int64, id int) (*types.Preset, error)
+	GetPresetByName(orgID int64, name string) (*types.Preset, error)
+	GetPresetsCount(orgID int64) int
 	CreatePreset(user types.Preset) (*types.Preset, error)
 	UpdatePresetByID(id int, preset types.Preset) *types.Preset
 	UpdatePresetStatusByID(id int, active bool) error
@@ -22,16 +22,17 @@ type PresetsOp struct {
 
 var _ Presets = &PresetsOp{}
 
-// GetPresets Gets all presets.
-func (p PresetsOp) GetPresets(offset, count int) *[]types.Preset {
+// GetPresets Gets the presets of an organization.
+func (p PresetsOp) GetPresets(orgID int64, offset, count int) *[]types.Preset {
 	const query = `
 	  SELECT * FROM presets
+	  WHERE org_id = $1
 	  ORDER BY id DESC
-      LIMIT $1 OFFSET $2`
+      LIMIT $2 OFFSET $3`
 
 	db, _ := ConnectDB()
 	presets := []types.Preset{}
-	err := db.Select(&presets, query, count, offset)
+	err := db.Select(&presets, query, orgID, count, offset)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -39,49 +40,51 @@ func (p PresetsOp) GetPresets(offset, count int) *[]types.Preset {
 	return &presets
 }
 
-// GetPresetByID Gets a preset by ID.
-func (p PresetsOp) GetPresetByID(id int) (*types.Preset, error) {
+// GetPresetByID Gets a preset of an organization by ID.
+func (p PresetsOp) GetPresetByID(orgID int64, id int) (*types.Preset, error) {
 	const query = `
       SELECT *
       FROM presets
-      WHERE id = $1`
+      WHERE org_id = $1 AND id = $2`
 
 	db, _ := ConnectDB()
 	preset := types.Preset{}
-	err := db.Get(&preset, query, id)
+	err := db.Get(&preset, query, orgID, id)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		db.Close()
 		return &preset, err
 	}
 	db.Close()
 	return &preset, nil
 }
 
-// GetPresetByName Gets a preset by name.
-func (p PresetsOp) GetPresetByName(name string) (*types.Preset, error) {
+// GetPresetByName Gets a preset of an organization by name.
+func (p PresetsOp) GetPresetByName(orgID int64, name string) (*types.Preset, error) {
 	const query = `
       SELECT *
       FROM presets
-      WHERE name = $1`
+      WHERE org_id = $1 AND name = $2`
 
 	db, _ := ConnectDB()
 	preset := types.Preset{}
-	err := db.Get(&preset, query, name)
+	err := db.Get(&preset, query, orgID, name)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		db.Close()
 		return &preset, err
 	}
 	db.Close()
 	return &preset, nil
 }
 
-// GetPresetsCount Gets a count of all presets.
-func (p PresetsOp) GetPresetsCount() int {
+// GetPresetsCount Gets a count of the presets of an organization.
+func (p PresetsOp) GetPresetsCount(orgID int64) int {
 	var count int
-	const query = `SELECT COUNT(*) FROM presets`
+	const query = `SELECT COUNT(*) FROM presets WHERE org_id = $1`
 
 	db, _ := ConnectDB()
-	err := db.Get(&count, query)
+	err := db.Get(&count, query, orgID)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -93,8 +96,8 @@ func (p PresetsOp) GetPresetsCount() int {
 func (p PresetsOp) CreatePreset(preset types.Preset) (*types.Preset, error) {
 	const query = `
 	  INSERT INTO
-	    presets (name,description,data,active,output)
-	  VALUES (:name,:description,:data,:active,:output)
+	    presets (name,description,data,active,output,org_id)
+	  VALUES (:name,:description,:data,:active,:output,:org_id)
 	  RETURNING id`
 
 	db, _ := ConnectDB()
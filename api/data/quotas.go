@@ -0,0 +1,103 @@
+package data
+
+import (
+	"database/sql"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Quotas represents the Quotas database operations.
+type Quotas interface {
+	GetQuota(orgID int64, username string) (*types.Quota, error)
+	SetQuota(quota types.Quota) (*types.Quota, error)
+	GetUsage(orgID int64, username string) (*types.Usage, error)
+}
+
+// QuotasOp represents the quotas operations.
+type QuotasOp struct {
+	q *Quotas
+}
+
+var _ Quotas = &QuotasOp{}
+
+// GetQuota Gets the quota of an organization, or of a user in it if the
+// username is set. A missing quota is unlimited.
+func (q QuotasOp) GetQuota(orgID int64, username string) (*types.Quota, error) {
+	const query = `SELECT * FROM quotas WHERE org_id = $1 AND username = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	quota := types.Quota{}
+	err := db.Get(&quota, query, orgID, username)
+	if err == sql.ErrNoRows {
+		return &types.Quota{OrgID: orgID, Username: username}, nil
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// SetQuota Creates or updates a quota.
+func (q QuotasOp) SetQuota(quota types.Quota) (*types.Quota, error) {
+	const query = `
+      INSERT INTO
+        quotas (org_id,username,max_concurrent_jobs,jobs_per_day,encoded_minutes_per_month,max_source_size)
+      VALUES (:org_id,:username,:max_concurrent_jobs,:jobs_per_day,:encoded_minutes_per_month,:max_source_size)
+      ON CONFLICT (org_id,username) DO UPDATE SET
+        max_concurrent_jobs = EXCLUDED.max_concurrent_jobs,
+        jobs_per_day = EXCLUDED.jobs_per_day,
+        encoded_minutes_per_month = EXCLUDED.encoded_minutes_per_month,
+        max_source_size = EXCLUDED.max_source_size,
+        updated_date = CURRENT_TIMESTAMP
+      RETURNING updated_date`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = stmt.QueryRowx(&quota).Scan(&quota.UpdatedDate)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+
+	return &quota, nil
+}
+
+// GetUsage Gets the usage of an organization, or of a user in it if the
+// username is set.
+func (q QuotasOp) GetUsage(orgID int64, username string) (*types.Usage, error) {
+	const query = `
+      SELECT
+        COUNT(*) FILTER (WHERE jobs.status NOT IN ($3,$4,$5)) "concurrent_jobs",
+        COUNT(*) FILTER (WHERE jobs.created_date >= date_trunc('day', now())) "jobs_today",
+        ROUND(COALESCE(SUM(encode.duration) FILTER (
+          WHERE jobs.status = $3 AND jobs.created_date >= date_trunc('month', now())), 0)::numeric / 60, 2) "encoded_minutes"
+      FROM jobs
+      LEFT JOIN encode ON jobs.id = encode.job_id
+      WHERE jobs.org_id = $1 AND ($2 = '' OR jobs.created_by = $2)
+        AND (jobs.status NOT IN ($3,$4,$5) OR jobs.created_date >= date_trunc('month', now()))`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	usage := types.Usage{}
+	err := db.Get(&usage, query, orgID, username, types.JobCompleted, types.JobError, types.JobCancelled)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Roles represents the Roles database operations.
+type Roles interface {
+	GetRoles() *[]types.Role
+	GetRoleByID(id int64) (*types.Role, error)
+	CreateRole(role types.Role) (*types.Role, error)
+	UpdateRoleByID(id int64, role types.Role) (*types.Role, error)
+	DeleteRoleByID(id int64) error
+	GetRoleUsersCount(name string) int
+}
+
+// RolesOp represents the roles operations.
+type RolesOp struct {
+	r *Roles
+}
+
+var _ Roles = &RolesOp{}
+
+// GetRoles Gets all roles.
+func (r RolesOp) GetRoles() *[]types.Role {
+	const query = `SELECT * FROM roles ORDER BY id`
+
+	db, _ := ConnectDB()
+	roles := []types.Role{}
+	err := db.Select(&roles, query)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &roles
+}
+
+// GetRoleByID Gets a role by ID.
+func (r RolesOp) GetRoleByID(id int64) (*types.Role, error) {
+	const query = `SELECT * FROM roles WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	role := types.Role{}
+	err := db.Get(&role, query, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CreateRole creates a role.
+func (r RolesOp) CreateRole(role types.Role) (*types.Role, error) {
+	const query = `
+      INSERT INTO
+        roles (name,description,permissions)
+      VALUES (:name,:description,:permissions)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&role).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	role.ID = id
+
+	return &role, nil
+}
+
+// UpdateRoleByID Updates the description and permissions of a role by ID.
+func (r RolesOp) UpdateRoleByID(id int64, role types.Role) (*types.Role, error) {
+	const query = `
+      UPDATE roles
+      SET description = :description, permissions = :permissions
+      WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	role.ID = id
+	_, err := db.NamedExec(query, &role)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &role, nil
+}
+
+// DeleteRoleByID Deletes a role by ID.
+func (r RolesOp) DeleteRoleByID(id int64) error {
+	const query = `DELETE FROM roles WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetRoleUsersCount Gets a count of the users assigned a role, in or out of
+// organizations.
+func (r RolesOp) GetRoleUsersCount(name string) int {
+	var count int
+	const query = `
+      SELECT
+        (SELECT COUNT(*) FROM users WHERE role = $1) +
+        (SELECT COUNT(*) FROM organization_members WHERE role = $1)`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, name)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
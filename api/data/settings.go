@@ -11,14 +11,14 @@ import (
 
 // Settings represents the Settings database operations.
 type Settings interface {
-	GetSetting(key string) (*types.Setting, error)
-	GetSettings() []types.Setting
+	GetSetting(orgID int64, key string) (*types.Setting, error)
+	GetSettings(orgID int64) []types.Setting
 	GetSettingsOptions() []types.SettingsOption
 	CreateSetting(setting types.Setting) *types.Setting
-	CreateOrUpdateSetting(key, value string)
-	UpdateSettings(setting map[string]string) error
+	CreateOrUpdateSetting(orgID int64, key, value string)
+	UpdateSettings(orgID int64, setting map[string]string) error
 	UpdateSetting(setting types.Setting) *types.Setting
-	SettingExists(optionID int64) bool
+	SettingExists(orgID, optionID int64) bool
 }
 
 // SettingsOp represents the settings.
@@ -28,8 +28,8 @@ type SettingsOp struct {
 
 var _ Settings = &SettingsOp{}
 
-// GetSetting Gets a setting.
-func (s SettingsOp) GetSetting(key string) (*types.Setting, error) {
+// GetSetting Gets a setting of an organization.
+func (s SettingsOp) GetSetting(orgID int64, key string) (*types.Setting, error) {
 	const query = `
 	  SELECT
         settings.*,
@@ -40,12 +40,12 @@ func (s SettingsOp) GetSetting(key string) (*types.Setting, error) {
         settings_option.secure "settings_option.secure"
 	  FROM settings
       JOIN settings_option ON settings.settings_option_id = settings_option.id
-      WHERE settings_option.name = $1
+      WHERE settings.org_id = $1 AND settings_option.name = $2
       ORDER BY id DESC`
 
 	db, _ := ConnectDB()
 	setting := types.Setting{}
-	err := db.Get(&setting, query, key)
+	err := db.Get(&setting, query, orgID, key)
 	if err != nil {
 		log.Error(err)
 		return nil, err
@@ -60,8 +60,8 @@ func (s SettingsOp) GetSetting(key string) (*types.Setting, error) {
 	return &setting, nil
 }
 
-// GetSettings Gets settings.
-func (s SettingsOp) GetSettings() []types.Setting {
+// GetSettings Gets the settings of an organization.
+func (s SettingsOp) GetSettings(orgID int64) []types.Setting {
 	const query = `
 	  SELECT
         settings.*,
@@ -72,11 +72,12 @@ func (s SettingsOp) GetSettings() []types.Setting {
         settings_option.secure "settings_option.secure"
 	  FROM settings
       JOIN settings_option ON settings.settings_option_id = settings_option.id
+      WHERE settings.org_id = $1
       ORDER BY id DESC`
 
 	db, _ := ConnectDB()
 	settings := []types.Setting{}
-	err := db.Select(&settings, query)
+	err := db.Select(&settings, query, orgID)
 	if err != nil {
 		log.Error(err)
 	}
@@ -110,8 +111,8 @@ func (s SettingsOp) GetSettingsOptions() []types.SettingsOption {
 func (s SettingsOp) CreateSetting(setting types.Setting) *types.Setting {
 	const query = `
       INSERT INTO
-        settings (settings_option_id,value,encrypted)
-      VALUES (:settings_option_id,:value,:encrypted)
+        settings (settings_option_id,value,encrypted,org_id)
+      VALUES (:settings_option_id,:value,:encrypted,:org_id)
       RETURNING id`
 
 	db, _ := ConnectDB()
@@ -135,14 +136,16 @@ func (s SettingsOp) CreateSetting(setting types.Setting) *types.Setting {
 	return &setting
 }
 
-// CreateOrUpdateSetting Runs an "upsert"-like transaction for a setting.
-func (s SettingsOp) CreateOrUpdateSetting(key, value string) {
+// CreateOrUpdateSetting Runs an "upsert"-like transaction for a setting of an
+// organization.
+func (s SettingsOp) CreateOrUpdateSetting(orgID int64, key, value string) {
 	availableSettings := s.GetSettingsOptions()
 	k := getOptionKeyID(availableSettings, key)
 	isSecure := isSecure(availableSettings, key)
-	exists := s.SettingExists(k)
+	exists := s.SettingExists(orgID, k)
 
 	se := types.Setting{
+		OrgID:            orgID,
 		SettingsOptionID: k,
 		Value:            value,
 		Encrypted:        false,
@@ -161,12 +164,12 @@ func (s SettingsOp) CreateOrUpdateSetting(key, value string) {
 	}
 }
 
-// UpdateSettings Updates settings.
-func (s SettingsOp) UpdateSettings(setting map[string]string) error {
+// UpdateSettings Updates the settings of an organization.
+func (s SettingsOp) UpdateSettings(orgID int64, setting map[string]string) error {
 
 	// Run insert or update for each setting.
 	for k, v := range setting {
-		s.CreateOrUpdateSetting(k, v)
+		s.CreateOrUpdateSetting(orgID, k, v)
 	}
 	return nil
 }
@@ -176,7 +179,7 @@ func (s SettingsOp) UpdateSetting(setting types.Setting) *types.Setting {
 	const query = `
         UPDATE settings
         SET value = :value, encrypted = :encrypted
-        WHERE settings_option_id = :settings_option_id`
+        WHERE org_id = :org_id AND settings_option_id = :settings_option_id`
 
 	db, _ := ConnectDB()
 	tx := db.MustBegin()
@@ -190,17 +193,17 @@ func (s SettingsOp) UpdateSetting(setting types.Setting) *types.Setting {
 	return &setting
 }
 
-// SettingExists Queries a setting exists.
-func (s SettingsOp) SettingExists(optionID int64) bool {
+// SettingExists Queries a setting of an organization exists.
+func (s SettingsOp) SettingExists(orgID, optionID int64) bool {
 	const query = `
         SELECT EXISTS
         (SELECT id
         FROM settings
-        WHERE settings_option_id = $1)`
+        WHERE org_id = $1 AND settings_option_id = $2)`
 
 	var exists bool
 	db, _ := ConnectDB()
-	err := db.QueryRow(query, optionID).Scan(&exists)
+	err := db.QueryRow(query, orgID, optionID).Scan(&exists)
 	if err != nil {
 		log.Error(err)
 	}
@@ -0,0 +1,230 @@
+package data
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/helpers"
+	"github.com/alfg/openencoder/api/types"
+)
+
+// StorageProfiles represents the StorageProfiles database operations.
+type StorageProfiles interface {
+	GetStorageProfiles(orgID int64, offset, count int) *[]types.StorageProfile
+	GetStorageProfileByID(orgID int64, id int) (*types.StorageProfile, error)
+	GetStorageProfileByName(orgID int64, name string) (*types.StorageProfile, error)
+	GetStorageProfilesCount(orgID int64) int
+	CreateStorageProfile(profile types.StorageProfile) (*types.StorageProfile, error)
+	UpdateStorageProfileByID(id int, profile types.StorageProfile) (*types.StorageProfile, error)
+	DeleteStorageProfileByID(id int) error
+}
+
+// StorageProfilesOp represents the storage profiles operations.
+type StorageProfilesOp struct {
+	s *StorageProfiles
+}
+
+var _ StorageProfiles = &StorageProfilesOp{}
+
+// GetStorageProfiles Gets the storage profiles of an organization.
+func (s StorageProfilesOp) GetStorageProfiles(orgID int64, offset, count int) *[]types.StorageProfile {
+	const query = `
+      SELECT * FROM storage_profiles
+      WHERE org_id = $1
+      ORDER BY id DESC
+      LIMIT $2 OFFSET $3`
+
+	db, _ := ConnectDB()
+	profiles := []types.StorageProfile{}
+	err := db.Select(&profiles, query, orgID, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+
+	for i := range profiles {
+		decryptStorageProfile(&profiles[i])
+	}
+	return &profiles
+}
+
+// GetStorageProfileByID Gets a storage profile of an organization by ID.
+func (s StorageProfilesOp) GetStorageProfileByID(orgID int64, id int) (*types.StorageProfile, error) {
+	const query = `
+      SELECT *
+      FROM storage_profiles
+      WHERE org_id = $1 AND id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	profile := types.StorageProfile{}
+	err := db.Get(&profile, query, orgID, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	decryptStorageProfile(&profile)
+	return &profile, nil
+}
+
+// GetStorageProfileByName Gets a storage profile of an organization by name.
+func (s StorageProfilesOp) GetStorageProfileByName(orgID int64, name string) (*types.StorageProfile, error) {
+	const query = `
+      SELECT *
+      FROM storage_profiles
+      WHERE org_id = $1 AND name = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	profile := types.StorageProfile{}
+	err := db.Get(&profile, query, orgID, name)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	decryptStorageProfile(&profile)
+	return &profile, nil
+}
+
+// GetStorageProfilesCount Gets a count of the storage profiles of an
+// organization.
+func (s StorageProfilesOp) GetStorageProfilesCount(orgID int64) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM storage_profiles WHERE org_id = $1`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// CreateStorageProfile creates a storage profile with its credentials encrypted.
+func (s StorageProfilesOp) CreateStorageProfile(profile types.StorageProfile) (*types.StorageProfile, error) {
+	const query = `
+      INSERT INTO
+        storage_profiles (name,driver,provider,endpoint,region,access_key,secret_key,
+          inbound_bucket,outbound_bucket,ftp_addr,ftp_username,ftp_password,org_id)
+      VALUES (:name,:driver,:provider,:endpoint,:region,:access_key,:secret_key,
+          :inbound_bucket,:outbound_bucket,:ftp_addr,:ftp_username,:ftp_password,:org_id)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	encrypted := profile
+	encryptStorageProfile(&encrypted)
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&encrypted).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	profile.ID = id
+
+	return &profile, nil
+}
+
+// UpdateStorageProfileByID Update storage profile by ID.
+func (s StorageProfilesOp) UpdateStorageProfileByID(id int, profile types.StorageProfile) (*types.StorageProfile, error) {
+	const query = `
+        UPDATE storage_profiles
+        SET name = :name, driver = :driver, provider = :provider, endpoint = :endpoint,
+          region = :region, access_key = :access_key, secret_key = :secret_key,
+          inbound_bucket = :inbound_bucket, outbound_bucket = :outbound_bucket,
+          ftp_addr = :ftp_addr, ftp_username = :ftp_username, ftp_password = :ftp_password
+        WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	profile.ID = int64(id)
+	encrypted := profile
+	encryptStorageProfile(&encrypted)
+
+	tx := db.MustBegin()
+	_, err := tx.NamedExec(query, &encrypted)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+
+	return &profile, nil
+}
+
+// DeleteStorageProfileByID Deletes a storage profile by ID.
+func (s StorageProfilesOp) DeleteStorageProfileByID(id int) error {
+	const query = `DELETE FROM storage_profiles WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	_, err := tx.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func encryptStorageProfile(p *types.StorageProfile) {
+	p.AccessKey = encryptValue(p.AccessKey)
+	p.SecretKey = encryptValue(p.SecretKey)
+	p.FTPUsername = encryptValue(p.FTPUsername)
+	p.FTPPassword = encryptValue(p.FTPPassword)
+}
+
+func decryptStorageProfile(p *types.StorageProfile) {
+	p.AccessKey = decryptValue(p.AccessKey)
+	p.SecretKey = decryptValue(p.SecretKey)
+	p.FTPUsername = decryptValue(p.FTPUsername)
+	p.FTPPassword = decryptValue(p.FTPPassword)
+}
+
+// encryptValue encrypts a value with the keyseed and hex encodes it.
+func encryptValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	ciphertext, err := helpers.Encrypt([]byte(value), config.Keyseed())
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+	return fmt.Sprintf("%x", ciphertext)
+}
+
+// decryptValue decodes and decrypts a value encrypted with encryptValue.
+func decryptValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	enc, _ := hex.DecodeString(value)
+	plaintext, err := helpers.Decrypt(enc, config.Keyseed())
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+	return string(plaintext)
+}
@@ -2,6 +2,8 @@ package data
 
 import (
 	"github.com/alfg/openencoder/api/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Users represents the Users database operations.
@@ -10,10 +12,14 @@ type Users interface {
 	GetUserByID(id int) (*types.User, error)
 	GetUsersCount() int
 	GetUserByUsername(username string) (*types.User, error)
+	GetUserByOIDCSubject(issuer, subject string) (*types.User, error)
 	GetUserID(username string) int64
 	CreateUser(user types.User) (*types.User, error)
 	UpdateUserByID(id int, user *types.User) (*types.User, error)
 	UpdateUserPasswordByID(id int64, user *types.User) (*types.User, error)
+	UpdateUserPasswordHashByID(id int64, hash string) error
+	UpdateUserTOTPByID(id int64, secret string, recoveryCodes []string) error
+	UseUserRecoveryCode(id int64, hash string) (bool, error)
 }
 
 // UsersOp represents the users operations.
@@ -35,7 +41,7 @@ func (u UsersOp) GetUsers(offset, count int) *[]types.User {
 	users := []types.User{}
 	err := db.Select(&users, query, count, offset)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 	}
 	db.Close()
 	return &users
@@ -54,6 +60,7 @@ func (u UsersOp) GetUserByID(id int) (*types.User, error) {
 	if err != nil {
 		return &user, err
 	}
+	user.TOTPSecret = decryptValue(user.TOTPSecret)
 	db.Close()
 	return &user, nil
 }
@@ -66,7 +73,7 @@ func (u UsersOp) GetUsersCount() int {
 	db, _ := ConnectDB()
 	err := db.Get(&count, query)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 	}
 	db.Close()
 	return count
@@ -84,13 +91,38 @@ func (u UsersOp) GetUserByUsername(username string) (*types.User, error) {
 	user := types.User{}
 	err := db.Get(&user, query, username)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		db.Close()
 		return &user, err
 	}
+	user.TOTPSecret = decryptValue(user.TOTPSecret)
 	db.Close()
 	return &user, nil
 }
 
+// GetUserByOIDCSubject Gets a user created by an OIDC login by the issuer and
+// subject of their provider account.
+func (u UsersOp) GetUserByOIDCSubject(issuer, subject string) (*types.User, error) {
+	const query = `
+      SELECT
+        users.*
+      FROM users
+      WHERE users.oidc_issuer = $1
+        AND users.oidc_subject = $2
+        AND users.oidc_subject <> ''`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	user := types.User{}
+	err := db.Get(&user, query, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = decryptValue(user.TOTPSecret)
+	return &user, nil
+}
+
 // GetUserID Gets a user ID by username.
 func (u UsersOp) GetUserID(username string) int64 {
 	const query = "SELECT id FROM users WHERE username = $1"
@@ -100,51 +132,81 @@ func (u UsersOp) GetUserID(username string) int64 {
 	db, _ := ConnectDB()
 	err := db.QueryRow(query, username).Scan(&id)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 	}
+	db.Close()
 	return id
 }
 
 // CreateUser creates a user.
 func (u UsersOp) CreateUser(user types.User) (*types.User, error) {
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	if err := insertUser(tx, &user); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	return &user, nil
+}
+
+// insertUser inserts a user in a transaction and adds them to their
+// organization, or the default organization. Pending users are inactive
+// until approved.
+func insertUser(tx *sqlx.Tx, user *types.User) error {
 	const query = `
 	  INSERT INTO
-	    users (username,password,role)
-	  VALUES (:username,:password,:role)
+	    users (username,password,role,service_account,pending,active,oidc_issuer,oidc_subject)
+	  VALUES (:username,:password,:role,:service_account,:pending,NOT :pending,:oidc_issuer,:oidc_subject)
 	  RETURNING id`
+	const memberQuery = `
+	  INSERT INTO
+	    organization_members (org_id,user_id)
+	  VALUES ($1,$2)`
 
-	db, _ := ConnectDB()
-	tx := db.MustBegin()
 	stmt, err := tx.PrepareNamed(query)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		return err
 	}
 
 	var id int64 // Returned ID.
-	err = stmt.QueryRowx(&user).Scan(&id)
+	err = stmt.QueryRowx(user).Scan(&id)
 	if err != nil {
-		log.Fatal(err.Error())
-		return nil, err
+		log.Error(err)
+		return err
 	}
-	tx.Commit()
-	user.ID = id
 
-	db.Close()
-	return &user, nil
+	if user.OrgID == 0 {
+		user.OrgID = types.DefaultOrgID
+	}
+	_, err = tx.Exec(memberQuery, user.OrgID, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	user.ID = id
+	user.Active = !user.Pending
+	return nil
 }
 
 // UpdateUserByID Update user by ID.
 func (u UsersOp) UpdateUserByID(id int, user *types.User) (*types.User, error) {
 	const query = `
         UPDATE users
-        SET username = :username, password = :password, active = :active, role = :role
+        SET username = :username, password = :password, active = :active, role = :role,
+          pending = :pending
         WHERE id = :id`
 
 	db, _ := ConnectDB()
 	tx := db.MustBegin()
 	_, err := tx.NamedExec(query, &user)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		tx.Rollback()
+		db.Close()
 		return user, err
 	}
 	tx.Commit()
@@ -164,7 +226,9 @@ func (u UsersOp) UpdateUserPasswordByID(id int64, user *types.User) (*types.User
 	tx := db.MustBegin()
 	_, err := tx.NamedExec(query, &user)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
+		tx.Rollback()
+		db.Close()
 		return user, err
 	}
 	tx.Commit()
@@ -172,3 +236,65 @@ func (u UsersOp) UpdateUserPasswordByID(id int64, user *types.User) (*types.User
 	db.Close()
 	return user, nil
 }
+
+// UpdateUserPasswordHashByID Replaces the password hash of a user, such as to
+// rehash the same password with a higher cost.
+func (u UsersOp) UpdateUserPasswordHashByID(id int64, hash string) error {
+	const query = `
+        UPDATE users
+        SET password = $2
+        WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id, hash)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UpdateUserTOTPByID Sets the TOTP secret and recovery code hashes of a user.
+// An empty secret disables two-factor authentication.
+func (u UsersOp) UpdateUserTOTPByID(id int64, secret string, recoveryCodes []string) error {
+	const query = `
+        UPDATE users
+        SET totp_secret = $2, totp_enabled = $3, recovery_codes = $4
+        WHERE id = $1`
+
+	if recoveryCodes == nil {
+		recoveryCodes = []string{}
+	}
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id, encryptValue(secret), secret != "", pq.StringArray(recoveryCodes))
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// UseUserRecoveryCode Removes a recovery code hash from a user, returning
+// false if the user doesn't have it. Each code can only be used once.
+func (u UsersOp) UseUserRecoveryCode(id int64, hash string) (bool, error) {
+	const query = `
+        UPDATE users
+        SET recovery_codes = array_remove(recovery_codes, $2)
+        WHERE id = $1 AND $2 = ANY(recovery_codes)`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec(query, id, hash)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Watchers represents the Watchers database operations.
+type Watchers interface {
+	GetWatchers(orgID int64, offset, count int) *[]types.Watcher
+	GetWatchersCount(orgID int64) int
+	GetActiveWatchers() (*[]types.Watcher, error)
+	GetWatcherByID(orgID int64, id int) (*types.Watcher, error)
+	CreateWatcher(watcher types.Watcher) (*types.Watcher, error)
+	UpdateWatcherByID(id int, watcher types.Watcher) (*types.Watcher, error)
+	UpdateWatcherScanByID(id int64, status, scanError string, jobs int) error
+	DeleteWatcherByID(id int) error
+	CreateWatcherObject(watcherID int64, key, etag, preset string) (bool, error)
+	DeleteWatcherObject(watcherID int64, key, etag, preset string) error
+}
+
+// WatchersOp represents the watchers operations.
+type WatchersOp struct {
+	w *Watchers
+}
+
+var _ Watchers = &WatchersOp{}
+
+// GetWatchers Gets the watchers of an organization.
+func (w WatchersOp) GetWatchers(orgID int64, offset, count int) *[]types.Watcher {
+	const query = `
+      SELECT * FROM watchers
+      WHERE org_id = $1
+      ORDER BY id DESC
+      LIMIT $2 OFFSET $3`
+
+	db, _ := ConnectDB()
+	watchers := []types.Watcher{}
+	err := db.Select(&watchers, query, orgID, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &watchers
+}
+
+// GetWatchersCount Gets a count of the watchers of an organization.
+func (w WatchersOp) GetWatchersCount(orgID int64) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM watchers WHERE org_id = $1`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetActiveWatchers Gets the active watchers of all organizations.
+func (w WatchersOp) GetActiveWatchers() (*[]types.Watcher, error) {
+	const query = `
+      SELECT * FROM watchers
+      WHERE active = true
+      ORDER BY id ASC`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	watchers := []types.Watcher{}
+	err := db.Select(&watchers, query)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &watchers, nil
+}
+
+// GetWatcherByID Gets a watcher of an organization by ID.
+func (w WatchersOp) GetWatcherByID(orgID int64, id int) (*types.Watcher, error) {
+	const query = `
+      SELECT *
+      FROM watchers
+      WHERE org_id = $1 AND id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	watcher := types.Watcher{}
+	err := db.Get(&watcher, query, orgID, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &watcher, nil
+}
+
+// CreateWatcher creates a watcher.
+func (w WatchersOp) CreateWatcher(watcher types.Watcher) (*types.Watcher, error) {
+	const query = `
+      INSERT INTO
+        watchers (name,profile,prefix,glob,presets,destination,dest_profile,active,org_id)
+      VALUES (:name,:profile,:prefix,:glob,:presets,:destination,:dest_profile,:active,:org_id)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&watcher).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	watcher.ID = id
+
+	return &watcher, nil
+}
+
+// UpdateWatcherByID Update watcher by ID.
+func (w WatchersOp) UpdateWatcherByID(id int, watcher types.Watcher) (*types.Watcher, error) {
+	const query = `
+        UPDATE watchers
+        SET name = :name, profile = :profile, prefix = :prefix, glob = :glob,
+          presets = :presets, destination = :destination, dest_profile = :dest_profile,
+          active = :active
+        WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	watcher.ID = int64(id)
+	tx := db.MustBegin()
+	_, err := tx.NamedExec(query, &watcher)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+
+	return &watcher, nil
+}
+
+// UpdateWatcherScanByID Update the last scan status of a watcher by ID.
+func (w WatchersOp) UpdateWatcherScanByID(id int64, status, scanError string, jobs int) error {
+	const query = `
+        UPDATE watchers
+        SET last_scan_date = CURRENT_TIMESTAMP, last_scan_status = $1,
+          last_scan_error = $2, last_scan_jobs = $3
+        WHERE id = $4`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, status, scanError, jobs, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteWatcherByID Deletes a watcher and its tracked objects by ID.
+func (w WatchersOp) DeleteWatcherByID(id int) error {
+	const query = `DELETE FROM watchers WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// CreateWatcherObject records a job for a preset of an object as created by a
+// watcher. Returns false if it was already recorded for the same ETag.
+func (w WatchersOp) CreateWatcherObject(watcherID int64, key, etag, preset string) (bool, error) {
+	const query = `
+      INSERT INTO
+        watcher_objects (watcher_id,key,etag,preset)
+      VALUES ($1,$2,$3,$4)
+      ON CONFLICT DO NOTHING`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	res, err := db.Exec(query, watcherID, key, etag, preset)
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteWatcherObject removes a recorded job for a preset of an object so it
+// is created again.
+func (w WatchersOp) DeleteWatcherObject(watcherID int64, key, etag, preset string) error {
+	const query = `
+      DELETE FROM watcher_objects
+      WHERE watcher_id = $1 AND key = $2 AND etag = $3 AND preset = $4`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, watcherID, key, etag, preset)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Webhooks represents the Webhooks database operations.
+type Webhooks interface {
+	GetWebhooks(offset, count int) *[]types.Webhook
+	GetWebhooksCount() int
+	GetWebhookByID(id int64) (*types.Webhook, error)
+	GetActiveWebhooksByEvent(event string) (*[]types.Webhook, error)
+	CreateWebhook(webhook types.Webhook) (*types.Webhook, error)
+	UpdateWebhookByID(id int64, webhook types.Webhook) (*types.Webhook, error)
+	DeleteWebhookByID(id int64) error
+	GetWebhookDeliveries(webhookID int64, offset, count int) *[]types.WebhookDelivery
+	GetWebhookDeliveriesCount(webhookID int64) int
+	GetWebhookDeliveryByID(id int64) (*types.WebhookDelivery, error)
+	CreateWebhookDelivery(delivery types.WebhookDelivery) (*types.WebhookDelivery, error)
+	UpdateWebhookDeliveryByID(id int64, status string, responseCode int, deliveryError string) error
+}
+
+// WebhooksOp represents the webhooks operations.
+type WebhooksOp struct {
+	w *Webhooks
+}
+
+var _ Webhooks = &WebhooksOp{}
+
+// GetWebhooks Gets all webhooks.
+func (w WebhooksOp) GetWebhooks(offset, count int) *[]types.Webhook {
+	const query = `
+      SELECT * FROM webhooks
+      ORDER BY id DESC
+      LIMIT $1 OFFSET $2`
+
+	db, _ := ConnectDB()
+	webhooks := []types.Webhook{}
+	err := db.Select(&webhooks, query, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &webhooks
+}
+
+// GetWebhooksCount Gets a count of all webhooks.
+func (w WebhooksOp) GetWebhooksCount() int {
+	var count int
+	const query = `SELECT COUNT(*) FROM webhooks`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetWebhookByID Gets a webhook by ID.
+func (w WebhooksOp) GetWebhookByID(id int64) (*types.Webhook, error) {
+	const query = `
+      SELECT *
+      FROM webhooks
+      WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	webhook := types.Webhook{}
+	err := db.Get(&webhook, query, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	webhook.Secret = decryptValue(webhook.Secret)
+	return &webhook, nil
+}
+
+// GetActiveWebhooksByEvent Gets the active webhooks subscribed to an event.
+func (w WebhooksOp) GetActiveWebhooksByEvent(event string) (*[]types.Webhook, error) {
+	const query = `
+      SELECT * FROM webhooks
+      WHERE active = true AND $1 = ANY(events)
+      ORDER BY id ASC`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	webhooks := []types.Webhook{}
+	err := db.Select(&webhooks, query, event)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = decryptValue(webhooks[i].Secret)
+	}
+	return &webhooks, nil
+}
+
+// CreateWebhook creates a webhook. The secret is encrypted.
+func (w WebhooksOp) CreateWebhook(webhook types.Webhook) (*types.Webhook, error) {
+	const query = `
+      INSERT INTO
+        webhooks (url,secret,events,active)
+      VALUES (:url,:secret,:events,:active)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	secret := webhook.Secret
+	webhook.Secret = encryptValue(secret)
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&webhook).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	webhook.ID = id
+	webhook.Secret = secret
+
+	return &webhook, nil
+}
+
+// UpdateWebhookByID Update webhook by ID. The secret is encrypted.
+func (w WebhooksOp) UpdateWebhookByID(id int64, webhook types.Webhook) (*types.Webhook, error) {
+	const query = `
+        UPDATE webhooks
+        SET url = :url, secret = :secret, events = :events, active = :active
+        WHERE id = :id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	secret := webhook.Secret
+	webhook.ID = id
+	webhook.Secret = encryptValue(secret)
+
+	tx := db.MustBegin()
+	_, err := tx.NamedExec(query, &webhook)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	webhook.Secret = secret
+
+	return &webhook, nil
+}
+
+// DeleteWebhookByID Deletes a webhook and its deliveries by ID.
+func (w WebhooksOp) DeleteWebhookByID(id int64) error {
+	const query = `DELETE FROM webhooks WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetWebhookDeliveries Gets the deliveries of a webhook.
+func (w WebhooksOp) GetWebhookDeliveries(webhookID int64, offset, count int) *[]types.WebhookDelivery {
+	const query = `
+      SELECT * FROM webhook_deliveries
+      WHERE webhook_id = $1
+      ORDER BY id DESC
+      LIMIT $2 OFFSET $3`
+
+	db, _ := ConnectDB()
+	deliveries := []types.WebhookDelivery{}
+	err := db.Select(&deliveries, query, webhookID, count, offset)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &deliveries
+}
+
+// GetWebhookDeliveriesCount Gets a count of the deliveries of a webhook.
+func (w WebhooksOp) GetWebhookDeliveriesCount(webhookID int64) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, webhookID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetWebhookDeliveryByID Gets a webhook delivery by ID.
+func (w WebhooksOp) GetWebhookDeliveryByID(id int64) (*types.WebhookDelivery, error) {
+	const query = `
+      SELECT *
+      FROM webhook_deliveries
+      WHERE id = $1`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	delivery := types.WebhookDelivery{}
+	err := db.Get(&delivery, query, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// CreateWebhookDelivery creates a pending webhook delivery.
+func (w WebhooksOp) CreateWebhookDelivery(delivery types.WebhookDelivery) (*types.WebhookDelivery, error) {
+	const query = `
+      INSERT INTO
+        webhook_deliveries (webhook_id,job_id,url,event,payload,status)
+      VALUES (:webhook_id,:job_id,:url,:event,:payload,:status)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&delivery).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	delivery.ID = id
+
+	return &delivery, nil
+}
+
+// UpdateWebhookDeliveryByID Records a delivery attempt by ID.
+func (w WebhooksOp) UpdateWebhookDeliveryByID(id int64, status string, responseCode int, deliveryError string) error {
+	const query = `
+        UPDATE webhook_deliveries
+        SET status = $1, response_code = $2, error = $3,
+          attempts = attempts + 1, updated_date = CURRENT_TIMESTAMP
+        WHERE id = $4`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	_, err := db.Exec(query, status, responseCode, deliveryError, id)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
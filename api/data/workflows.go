@@ -0,0 +1,102 @@
+package data
+
+import (
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Workflows represents the Workflows database operations.
+type Workflows interface {
+	GetWorkflows(orgID int64, offset, count int, owner string) *[]types.Workflow
+	GetWorkflowsCount(orgID int64, owner string) int
+	GetWorkflowByID(orgID, id int64) (*types.Workflow, error)
+	CreateWorkflow(workflow types.Workflow) (*types.Workflow, error)
+}
+
+// WorkflowsOp represents the workflows operations.
+type WorkflowsOp struct {
+	w *Workflows
+}
+
+var _ Workflows = &WorkflowsOp{}
+
+// GetWorkflows Gets the workflows of an organization, or those created by the
+// owner if set.
+func (w WorkflowsOp) GetWorkflows(orgID int64, offset, count int, owner string) *[]types.Workflow {
+	const query = `
+      SELECT * FROM workflows
+      WHERE org_id = $4 AND ($3 = '' OR created_by = $3)
+      ORDER BY id DESC
+      LIMIT $1 OFFSET $2`
+
+	db, _ := ConnectDB()
+	workflows := []types.Workflow{}
+	err := db.Select(&workflows, query, count, offset, owner, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return &workflows
+}
+
+// GetWorkflowsCount Gets a count of the workflows of an organization, or
+// those created by the owner if set.
+func (w WorkflowsOp) GetWorkflowsCount(orgID int64, owner string) int {
+	var count int
+	const query = `SELECT COUNT(*) FROM workflows WHERE org_id = $2 AND ($1 = '' OR created_by = $1)`
+
+	db, _ := ConnectDB()
+	err := db.Get(&count, query, owner, orgID)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Close()
+	return count
+}
+
+// GetWorkflowByID Gets a workflow of an organization by ID.
+func (w WorkflowsOp) GetWorkflowByID(orgID, id int64) (*types.Workflow, error) {
+	const query = `SELECT * FROM workflows WHERE org_id = $1 AND id = $2`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	workflow := types.Workflow{}
+	err := db.Get(&workflow, query, orgID, id)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// CreateWorkflow creates a workflow.
+func (w WorkflowsOp) CreateWorkflow(workflow types.Workflow) (*types.Workflow, error) {
+	const query = `
+      INSERT INTO
+        workflows (name,created_by,org_id)
+      VALUES (:name,:created_by,:org_id)
+      RETURNING id`
+
+	db, _ := ConnectDB()
+	defer db.Close()
+
+	tx := db.MustBegin()
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	var id int64 // Returned ID.
+	err = stmt.QueryRowx(&workflow).Scan(&id)
+	if err != nil {
+		log.Error(err)
+		tx.Rollback()
+		return nil, err
+	}
+	tx.Commit()
+	workflow.ID = id
+
+	return &workflow, nil
+}
@@ -3,6 +3,7 @@ package encoder
 import (
 	"encoding/json"
 	"os/exec"
+	"strconv"
 )
 
 const ffprobeCmd = "ffprobe"
@@ -12,6 +13,16 @@ type FFProbe struct{}
 
 // Run runs an FFProbe command.
 func (f FFProbe) Run(input string) *FFProbeResponse {
+	dat, err := f.Probe(input)
+	if err != nil {
+		panic(err)
+	}
+	return dat
+}
+
+// Probe runs an FFProbe command and returns an error if the input could not
+// be probed.
+func (f FFProbe) Probe(input string) (*FFProbeResponse, error) {
 	args := []string{
 		"-i", input,
 		"-show_streams",
@@ -30,9 +41,20 @@ func (f FFProbe) Run(input string) *FFProbeResponse {
 
 	dat := &FFProbeResponse{}
 	if err := json.Unmarshal([]byte(stdout), &dat); err != nil {
-		panic(err)
+		return nil, err
 	}
-	return dat
+	return dat, nil
+}
+
+// Duration gets the longest stream duration in seconds.
+func (r *FFProbeResponse) Duration() float64 {
+	var duration float64
+	for _, s := range r.Streams {
+		if d, err := strconv.ParseFloat(s.Duration, 64); err == nil && d > duration {
+			duration = d
+		}
+	}
+	return duration
 }
 
 // FFProbeResponse defines the response from ffprobe.
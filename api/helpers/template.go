@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TemplateVariables are the variables available to destination and output
+// name templates, e.g. "dst/{source_basename}/{preset}_{width}x{height}.mp4".
+var TemplateVariables = []string{
+	"guid",            // Job GUID.
+	"job_id",          // Job ID.
+	"preset",          // Preset name.
+	"source_basename", // Source file name without extension.
+	"source_filename", // Source file name with extension.
+	"source_ext",      // Source file extension without the dot.
+	"source_dir",      // Source directory.
+	"date",            // Date the output is written, as YYYY-MM-DD.
+	"time",            // Time the output is written, as HHMMSS.
+	"timestamp",       // Unix timestamp the output is written.
+	"width",           // Source video width from the probe.
+	"height",          // Source video height from the probe.
+}
+
+var templateVar = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ValidateTemplate checks a template for unbalanced braces and unknown variables.
+func ValidateTemplate(tmpl string) error {
+	stripped := templateVar.ReplaceAllString(tmpl, "")
+	if strings.ContainsAny(stripped, "{}") {
+		return fmt.Errorf("invalid template %q: unbalanced braces", tmpl)
+	}
+
+	for _, m := range templateVar.FindAllStringSubmatch(tmpl, -1) {
+		if !isTemplateVariable(m[1]) {
+			return fmt.Errorf("invalid template %q: unknown variable {%s}", tmpl, m[1])
+		}
+	}
+	return nil
+}
+
+// ExpandTemplate replaces the template variables in a template with values.
+func ExpandTemplate(tmpl string, vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(tmpl, func(m string) string {
+		if v, ok := vars[m[1:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// MissingTemplateVariables gets the variables of a template without a value.
+func MissingTemplateVariables(tmpl string, vars map[string]string) []string {
+	missing := []string{}
+	for _, m := range templateVar.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := vars[m[1]]; !ok {
+			missing = append(missing, m[1])
+		}
+	}
+	return missing
+}
+
+func isTemplateVariable(name string) bool {
+	for _, v := range TemplateVariables {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
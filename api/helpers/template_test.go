@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl  string
+		valid bool
+	}{
+		{"s3:///dst/", true},
+		{"s3:///dst/{source_basename}/{width}x{height}/", true},
+		{"s3:///dst/{nope}/", false},
+		{"s3:///dst/{source_basename/", false},
+		{"s3:///dst/source_basename}/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			if err := ValidateTemplate(tt.tmpl); (err == nil) != tt.valid {
+				t.Errorf("ValidateTemplate() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{"preset": "h264", "width": "1280"}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"out.mp4", "out.mp4"},
+		{"{preset}_{width}.mp4", "h264_1280.mp4"},
+		{"{preset}_{height}.mp4", "h264_{height}.mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			if got := ExpandTemplate(tt.tmpl, vars); got != tt.want {
+				t.Errorf("ExpandTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingTemplateVariables(t *testing.T) {
+	vars := map[string]string{"preset": "h264", "width": ""}
+
+	tests := []struct {
+		tmpl string
+		want []string
+	}{
+		{"out.mp4", []string{}},
+		{"{preset}_{width}.mp4", []string{}},
+		{"{width}x{height}_{guid}.mp4", []string{"height", "guid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			if got := MissingTemplateVariables(tt.tmpl, vars); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingTemplateVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
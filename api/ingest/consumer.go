@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Config describes a configuration for consuming an SQS compatible queue.
+type Config struct {
+	QueueURL  string
+	Region    string
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+}
+
+// Handler handles an event. An event is only deleted from the queue when
+// the handler returns nil for every event in the message.
+type Handler func(Event) error
+
+// Consumer consumes S3 event notifications from a queue.
+type Consumer struct {
+	Config Config
+	client *sqs.SQS
+}
+
+// NewConsumer creates a new queue consumer.
+func NewConsumer(config Config) (*Consumer, error) {
+	awsConfig := &aws.Config{
+		Region:      aws.String(config.Region),
+		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
+	}
+
+	// Use a custom endpoint for compatible queues such as ElasticMQ or LocalStack.
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Consumer{
+		Config: config,
+		client: sqs.New(sess),
+	}, nil
+}
+
+// Run polls the queue and passes each event to the handler.
+func (c *Consumer) Run(handler Handler) {
+	log.Info("consuming ingest queue: ", c.Config.QueueURL)
+
+	for {
+		resp, err := c.client.ReceiveMessage(&sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(c.Config.QueueURL),
+			MaxNumberOfMessages: aws.Int64(MaxMessages),
+			WaitTimeSeconds:     aws.Int64(WaitTimeSeconds),
+		})
+		if err != nil {
+			log.Error(err)
+			time.Sleep(WaitTimeSeconds * time.Second)
+			continue
+		}
+
+		for _, msg := range resp.Messages {
+			if err := c.handleMessage(msg, handler); err != nil {
+				// Leave the message to be redelivered after the visibility timeout.
+				log.Error(err)
+				continue
+			}
+
+			_, err := c.client.DeleteMessage(&sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(c.Config.QueueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+func (c *Consumer) handleMessage(msg *sqs.Message, handler Handler) error {
+	events, err := parseEvents(aws.StringValue(msg.Body))
+	if err != nil {
+		// Malformed messages are never handled, so drop them.
+		log.Warnf("dropping malformed message %s: %s", aws.StringValue(msg.MessageId), err)
+		return nil
+	}
+
+	for _, e := range events {
+		if err := handler(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// S3 event names.
+const (
+	EventObjectCreated = "ObjectCreated:"
+	EventTest          = "s3:TestEvent"
+)
+
+// Event describes an object event from an S3 event notification.
+type Event struct {
+	Name      string
+	Bucket    string
+	Key       string
+	ETag      string
+	Size      int64
+	Sequencer string
+}
+
+// IsObjectCreated returns true for any of the ObjectCreated event types.
+func (e Event) IsObjectCreated() bool {
+	return strings.HasPrefix(e.Name, EventObjectCreated)
+}
+
+// ID returns an identifier that is the same for redelivered events.
+func (e Event) ID() string {
+	if e.Sequencer != "" {
+		return e.Sequencer
+	}
+	return e.ETag
+}
+
+// notification defines an S3 event notification message.
+type notification struct {
+	Event   string   `json:"Event"`
+	Records []record `json:"Records"`
+}
+
+type record struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			ETag      string `json:"eTag"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// snsMessage defines an SNS envelope for notifications delivered via SNS.
+type snsMessage struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// parseEvents parses the events from a queue message body.
+func parseEvents(body string) ([]Event, error) {
+	// Unwrap notifications fanned out through SNS.
+	var sns snsMessage
+	if err := json.Unmarshal([]byte(body), &sns); err == nil && sns.Type == "Notification" {
+		body = sns.Message
+	}
+
+	var n notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil, err
+	}
+
+	// Test events are sent when notifications are configured.
+	if n.Event == EventTest {
+		return nil, nil
+	}
+
+	events := []Event{}
+	for _, r := range n.Records {
+		// Keys are URL encoded in event notifications.
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, Event{
+			Name:      r.EventName,
+			Bucket:    r.S3.Bucket.Name,
+			Key:       key,
+			ETag:      strings.Trim(r.S3.Object.ETag, `"`),
+			Size:      r.S3.Object.Size,
+			Sequencer: r.S3.Object.Sequencer,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testRecord = `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"uploads"},` +
+	`"object":{"key":"inbound/my+video%2Ffinal.mp4","size":1024,"eTag":"\"abc123\"","sequencer":"0055AED6DCD90281E5"}}}]}`
+
+func TestParseEvents(t *testing.T) {
+	sns, err := json.Marshal(snsMessage{Type: "Notification", Message: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snsTest, err := json.Marshal(snsMessage{Type: "Notification", Message: `{"Event":"s3:TestEvent","Bucket":"uploads"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := Event{
+		Name:      "ObjectCreated:Put",
+		Bucket:    "uploads",
+		Key:       "inbound/my video/final.mp4",
+		ETag:      "abc123",
+		Size:      1024,
+		Sequencer: "0055AED6DCD90281E5",
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []Event
+		wantErr bool
+	}{
+		{"record", testRecord, []Event{event}, false},
+		{"sns", string(sns), []Event{event}, false},
+		{"test event", `{"Event":"s3:TestEvent","Bucket":"uploads"}`, nil, false},
+		{"sns test event", string(snsTest), nil, false},
+		{"no records", `{"Records":[]}`, []Event{}, false},
+		{"multiple records", `{"Records":[` +
+			`{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"a"},"object":{"key":"x.mp4"}}},` +
+			`{"eventName":"ObjectRemoved:Delete","s3":{"bucket":{"name":"b"},"object":{"key":"y%20z.mp4"}}}]}`,
+			[]Event{
+				{Name: "ObjectCreated:Put", Bucket: "a", Key: "x.mp4"},
+				{Name: "ObjectRemoved:Delete", Bucket: "b", Key: "y z.mp4"},
+			}, false},
+		{"unquoted etag", `{"Records":[{"s3":{"object":{"key":"a","eTag":"abc"}}}]}`,
+			[]Event{{Key: "a", ETag: "abc"}}, false},
+		{"invalid key", `{"Records":[{"s3":{"object":{"key":"bad%zz"}}}]}`, nil, true},
+		{"invalid json", `not json`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEvents(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEvents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEvents() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   Event
+		created bool
+		id      string
+	}{
+		{"put", Event{Name: "ObjectCreated:Put", ETag: "e", Sequencer: "s"}, true, "s"},
+		{"multipart", Event{Name: "ObjectCreated:CompleteMultipartUpload", ETag: "e"}, true, "e"},
+		{"removed", Event{Name: "ObjectRemoved:Delete", Sequencer: "s"}, false, "s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.IsObjectCreated(); got != tt.created {
+				t.Errorf("IsObjectCreated() = %v, want %v", got, tt.created)
+			}
+			if got := tt.event.ID(); got != tt.id {
+				t.Errorf("ID() = %s, want %s", got, tt.id)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package ingest
+
+import "github.com/alfg/openencoder/api/logging"
+
+var log = logging.Log
+
+// Ingest settings.
+const (
+	MaxMessages     = 10 // Messages received per poll.
+	WaitTimeSeconds = 20 // Long poll duration.
+)
@@ -20,6 +20,7 @@ write_files:
         DATABASE_USER={{.CloudinitDatabaseUser}}
         DATABASE_PASSWORD={{.CloudinitDatabasePassword}}
         DATABASE_NAME={{.CloudinitDatabaseName}}
+        WORKER_SIZE={{.WorkerSize}}
 runcmd:
   - docker run -d --env-file /opt/.env --rm {{.CloudinitWorkerImage}} worker
 `
@@ -34,9 +35,10 @@ type UserData struct {
 	CloudinitDatabasePassword string
 	CloudinitDatabaseName     string
 	CloudinitWorkerImage      string
+	WorkerSize                string
 }
 
-func createUserData() string {
+func createUserData(size string) string {
 	data := &UserData{
 		CloudinitRedisHost:        config.Get().CloudinitRedisHost,
 		CloudinitRedisPort:        config.Get().CloudinitRedisPort,
@@ -46,6 +48,7 @@ func createUserData() string {
 		CloudinitDatabasePassword: config.Get().CloudinitDatabasePassword,
 		CloudinitDatabaseName:     config.Get().CloudinitDatabaseName,
 		CloudinitWorkerImage:      config.Get().CloudinitWorkerImage,
+		WorkerSize:                size,
 	}
 
 	var tpl bytes.Buffer
@@ -76,12 +76,12 @@ func (do *DigitalOcean) ListDropletByTag(ctx context.Context, tag string) ([]Mac
 	return list, nil
 }
 
-// CreateDroplets creates a new DigitalOcean droplet.
-func (do *DigitalOcean) CreateDroplets(ctx context.Context, region, size, vpc string, count int) ([]CreatedResponse, error) {
+// CreateDroplets creates a new DigitalOcean droplet, tagged with the worker tag.
+func (do *DigitalOcean) CreateDroplets(ctx context.Context, region, size, vpc, workerTag string, count int) ([]CreatedResponse, error) {
 
 	var (
 		ipv6              = true
-		tags              = []string{tagName, workerTagName}
+		tags              = []string{tagName, workerTag}
 		monitoring        = true
 		privateNetworking = true
 	)
@@ -101,7 +101,7 @@ func (do *DigitalOcean) CreateDroplets(ctx context.Context, region, size, vpc st
 		// SSHKeys: []godo.DropletCreateSSHKey{
 		// 	godo.DropletCreateSSHKey{ID: 107149},
 		// },
-		UserData:          createUserData(),
+		UserData:          createUserData(size),
 		Tags:              tags,
 		Monitoring:        monitoring,
 		IPv6:              ipv6,
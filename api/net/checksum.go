@@ -0,0 +1,85 @@
+package net
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileChecksum holds the checksums of a local file used to verify transfers.
+type fileChecksum struct {
+	Size     int64
+	SHA256   string
+	MD5      []byte
+	PartMD5s [][]byte
+	PartSize int64
+}
+
+// checksumFile reads a file once and calculates its SHA-256, MD5 and the MD5
+// of each part for the given part size. Parts are streamed, so the part size
+// doesn't change the memory used.
+func checksumFile(path string, partSize int64) (*fileChecksum, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	c := &fileChecksum{PartSize: partSize}
+	whole := md5.New()
+	sum := sha256.New()
+
+	for {
+		part := md5.New()
+		n, err := io.CopyN(io.MultiWriter(part, whole, sum), file, partSize)
+		if n > 0 {
+			c.PartMD5s = append(c.PartMD5s, part.Sum(nil))
+			c.Size += n
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.MD5 = whole.Sum(nil)
+	c.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return c, nil
+}
+
+// ETag returns the ETag S3 reports for the file when uploaded with PartSize.
+func (c *fileChecksum) ETag() string {
+	if len(c.PartMD5s) <= 1 {
+		return hex.EncodeToString(c.MD5)
+	}
+	return multipartETag(c.PartMD5s)
+}
+
+// multipartETag calculates the ETag of a multipart upload from its part MD5s.
+func multipartETag(partMD5s [][]byte) string {
+	h := md5.New()
+	for _, p := range partMD5s {
+		h.Write(p)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(partMD5s))
+}
+
+// sha256File calculates the SHA-256 checksum of a file.
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
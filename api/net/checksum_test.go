@@ -0,0 +1,90 @@
+package net
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestChecksumFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		partSize int64
+		parts    int
+		etag     string
+		sha256   string
+	}{
+		{"empty", "", 4, 0,
+			"d41d8cd98f00b204e9800998ecf8427e",
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"under part size", "abc", 4, 1,
+			"900150983cd24fb0d6963f7d28e17f72",
+			"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"exactly part size", "abcd", 4, 1,
+			"e2fc714c4727ee9395f324cd2e7f331f",
+			"88d4266fd4e6338d13b845fcf289579d209c897823b9217da3e161936f031589"},
+		{"whole parts", "abcdefgh", 4, 2,
+			"cb93ad6c9c920e2602b79a11ded63ddb-2",
+			"9c56cc51b374c3ba189210d5b6d4bf57790d351c96c47c02190ecf1e430635ab"},
+		{"last part short", "abcdefghij", 4, 3,
+			"446feba4c1b5cc7ad93bf4d44a0e36ac-3",
+			"72399361da6a7754fec986dca5b7cbaf1c810a28ded4abaf56b2106d06cb78b0"},
+		{"part size over file size", "abcdefghij", 1 << 40, 1,
+			"a925576942e94b2ef57a066101b48876",
+			"72399361da6a7754fec986dca5b7cbaf1c810a28ded4abaf56b2106d06cb78b0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTempFile(t, tt.content)
+
+			c, err := checksumFile(path, tt.partSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Size != int64(len(tt.content)) {
+				t.Errorf("Size = %d, want %d", c.Size, len(tt.content))
+			}
+			if len(c.PartMD5s) != tt.parts {
+				t.Errorf("parts = %d, want %d", len(c.PartMD5s), tt.parts)
+			}
+			if got := c.ETag(); got != tt.etag {
+				t.Errorf("ETag() = %s, want %s", got, tt.etag)
+			}
+			if c.SHA256 != tt.sha256 {
+				t.Errorf("SHA256 = %s, want %s", c.SHA256, tt.sha256)
+			}
+
+			sum, err := sha256File(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum != tt.sha256 {
+				t.Errorf("sha256File() = %s, want %s", sum, tt.sha256)
+			}
+		})
+	}
+}
+
+func TestChecksumFileMissing(t *testing.T) {
+	if _, err := checksumFile("/nonexistent/file", 4); err == nil {
+		t.Error("checksumFile() error = nil, want error")
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "checksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
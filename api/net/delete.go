@@ -0,0 +1,51 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// DeleteOutputs deletes the output files of a job from the destination
+// storage. The files are those recorded with checksums on upload, so jobs
+// that never uploaded have nothing to delete.
+func DeleteOutputs(job types.Job) error {
+	if !job.Checksums.Valid || job.Checksums.String == "" {
+		return nil
+	}
+
+	checksums := map[string]string{}
+	if err := json.Unmarshal([]byte(job.Checksums.String), &checksums); err != nil {
+		return err
+	}
+	if len(checksums) == 0 {
+		return nil
+	}
+
+	// Set the keys as they were uploaded.
+	parsedURL, err := url.Parse(job.OutputDestination())
+	if err != nil {
+		return err
+	}
+	keys := []string{}
+	for name := range checksums {
+		keys = append(keys, parsedURL.Path+name)
+	}
+	sort.Strings(keys)
+
+	storage, err := GetStorage(job.OrgID, job.DestProfile)
+	if err != nil {
+		return err
+	}
+
+	if storage.Driver == types.StorageS3 {
+		return NewS3(storage.S3).DeleteObjects(keys)
+	} else if storage.Driver == types.StorageFTP {
+		f := NewFTP(storage.FTP.Addr, storage.FTP.Username, storage.FTP.Password)
+		return f.Delete(keys)
+	}
+	return errors.New("no driver set")
+}
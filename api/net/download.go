@@ -7,22 +7,20 @@ import (
 	"github.com/alfg/openencoder/api/types"
 )
 
-// Download downloads a job source based on the driver setting.
+// Download downloads a job source based on the source storage profile.
 func Download(job types.Job) error {
-	db := data.New()
-	setting, err := db.Settings.GetSetting(types.StorageDriver)
+	storage, err := GetSourceStorage(job)
 	if err != nil {
 		return err
 	}
-	driver := setting.Value
 
-	if driver == "s3" {
-		if err := s3Download(job); err != nil {
+	if storage.Driver == types.StorageS3 {
+		if err := s3Download(job, storage.S3); err != nil {
 			return err
 		}
 		return nil
-	} else if driver == "ftp" {
-		if err := ftpDownload(job); err != nil {
+	} else if storage.Driver == types.StorageFTP {
+		if err := ftpDownload(job, storage.FTP); err != nil {
 			return err
 		}
 		return nil
@@ -32,18 +30,12 @@ func Download(job types.Job) error {
 
 // GetPresignedURL gets a presigned URL from S3.
 func GetPresignedURL(job types.Job) (string, error) {
-	db := data.New()
-	settings := db.Settings.GetSettings()
-
-	config := S3Config{
-		AccessKey:      types.GetSetting(types.S3AccessKey, settings),
-		SecretKey:      types.GetSetting(types.S3SecretKey, settings),
-		Provider:       types.GetSetting(types.S3Provider, settings),
-		Region:         types.GetSetting(types.S3OutboundBucketRegion, settings),
-		InboundBucket:  types.GetSetting(types.S3InboundBucket, settings),
-		OutboundBucket: types.GetSetting(types.S3OutboundBucket, settings),
+	storage, err := GetSourceStorage(job)
+	if err != nil {
+		return "", err
 	}
-	s3 := NewS3(config)
+
+	s3 := NewS3(storage.S3)
 	str, err := s3.GetPresignedURL(job)
 	if err != nil {
 		return str, err
@@ -52,9 +44,8 @@ func GetPresignedURL(job types.Job) (string, error) {
 }
 
 // S3Download sets the download function.
-func s3Download(job types.Job) error {
+func s3Download(job types.Job, config S3Config) error {
 	db := data.New()
-	settings := db.Settings.GetSettings()
 
 	// Get job data.
 	j, err := db.Jobs.GetJobByGUID(job.GUID)
@@ -64,18 +55,11 @@ func s3Download(job types.Job) error {
 	}
 	encodeID := j.EncodeID
 
-	config := S3Config{
-		AccessKey:      types.GetSetting(types.S3AccessKey, settings),
-		SecretKey:      types.GetSetting(types.S3SecretKey, settings),
-		Provider:       types.GetSetting(types.S3Provider, settings),
-		Region:         types.GetSetting(types.S3OutboundBucketRegion, settings),
-		InboundBucket:  types.GetSetting(types.S3InboundBucket, settings),
-		OutboundBucket: types.GetSetting(types.S3OutboundBucket, settings),
-	}
 	s3 := NewS3(config)
 
 	// Download with progress updates.
-	go trackTransferProgress(encodeID, s3)
+	progressCh = make(chan struct{})
+	go trackTransferProgress(job.GUID, encodeID, s3)
 	err = s3.Download(job)
 	close(progressCh)
 
@@ -83,15 +67,8 @@ func s3Download(job types.Job) error {
 }
 
 // FTPDownload sets the FTP download function.
-func ftpDownload(job types.Job) error {
-	db := data.New()
-	settings := db.Settings.GetSettings()
-
-	addr := types.GetSetting(types.FTPAddr, settings)
-	user := types.GetSetting(types.FTPUsername, settings)
-	pass := types.GetSetting(types.FTPPassword, settings)
-
-	f := NewFTP(addr, user, pass)
+func ftpDownload(job types.Job, config FTPConfig) error {
+	f := NewFTP(config.Addr, config.Username, config.Password)
 	err := f.Download(job)
 	return err
 }
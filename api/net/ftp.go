@@ -2,6 +2,8 @@ package net
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"net/textproto"
 	"net/url"
@@ -84,9 +86,10 @@ func (f *FTP) Download(job types.Job) error {
 	return err
 }
 
-// Upload uploads a file to FTP.
-func (f *FTP) Upload(job types.Job) error {
-	log.Info("uploading files to FTP: ", job.Destination)
+// Upload uploads the output files to FTP and returns the SHA-256 checksum of
+// each uploaded file by file name.
+func (f *FTP) Upload(job types.Job) (map[string]string, error) {
+	log.Info("uploading files to FTP: ", job.OutputDestination())
 	defer log.Info("upload complete")
 
 	// Get list of files in output dir.
@@ -99,55 +102,95 @@ func (f *FTP) Upload(job types.Job) error {
 		return nil
 	})
 
-	f.uploadDir(filelist, job)
-	return nil
+	return f.uploadDir(filelist, job)
 }
 
-func (f *FTP) uploadDir(filelist []string, job types.Job) {
+func (f *FTP) uploadDir(filelist []string, job types.Job) (map[string]string, error) {
+	checksums := map[string]string{}
 	for _, file := range filelist {
-		f.uploadFile(file, job)
+		checksum, err := f.uploadFile(file, job)
+		if err != nil {
+			return nil, err
+		}
+		checksums[filepath.Base(file)] = checksum
 	}
+	return checksums, nil
 }
 
 // UploadFile uploads a file from an FTP connection.
-func (f *FTP) uploadFile(path string, job types.Job) error {
+func (f *FTP) uploadFile(path string, job types.Job) (string, error) {
 	// Create FTP connection.
 	c, err := ftp.Dial(f.Addr, ftp.DialWithTimeout(f.Timeout*time.Second))
 	if err != nil {
 		log.Error(err)
-		return err
+		return "", err
 	}
+	defer c.Quit()
 
 	// Login.
 	err = c.Login(f.Username, f.Password)
 	if err != nil {
 		log.Error(err)
-		return err
+		return "", err
 	}
 
 	file, err := os.Open(path)
-	defer file.Close()
 	if err != nil {
-		return err
+		return "", err
 	}
-	reader := bufio.NewReader(file)
+	defer file.Close()
+
+	// Checksum the file as it is read for upload.
+	hash := sha256.New()
+	reader := io.TeeReader(bufio.NewReader(file), hash)
 
 	// Set destination path.
-	parsedURL, _ := url.Parse(job.Destination)
+	parsedURL, _ := url.Parse(job.OutputDestination())
 	key := parsedURL.Path + filepath.Base(path)
 
 	// Create directory.
 	err = c.MakeDir(parsedURL.Path)
 	if err != nil && err.(*textproto.Error).Msg != ErrorFileExists {
 		log.Error(err)
-		return err
+		return "", err
 	}
 
 	err = c.Stor(key, reader)
+	if err != nil {
+		log.Error(err)
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Delete deletes files by path from an FTP connection. Files that no longer
+// exist are ignored.
+func (f *FTP) Delete(paths []string) error {
+	c, err := ftp.Dial(f.Addr, ftp.DialWithTimeout(f.Timeout*time.Second))
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	defer c.Quit()
+
+	// Login.
+	err = c.Login(f.Username, f.Password)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	for _, p := range paths {
+		log.Info("deleting from FTP: ", p)
+		err := c.Delete(p)
+		if e, ok := err.(*textproto.Error); ok && e.Code == ftp.StatusFileUnavailable {
+			continue
+		}
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -162,10 +205,16 @@ func (f *FTP) ListFiles(prefix string) ([]*ftp.Entry, error) {
 	err = c.Login(f.Username, f.Password)
 	if err != nil {
 		log.Error(err)
+		c.Quit()
 		return nil, err
 	}
 
 	entries, err := c.List(prefix)
+	if err != nil {
+		log.Error(err)
+		c.Quit()
+		return nil, err
+	}
 
 	if err := c.Quit(); err != nil {
 		log.Error(err)
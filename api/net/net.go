@@ -14,6 +14,7 @@ const (
 	EndpointDigitalOceanSpaces = ".digitaloceanspaces.com"
 	PresignedDuration          = 72 * time.Hour // 3 days.
 	ProgressInterval           = time.Second * 5
+	MetadataSHA256             = "sha256" // Object metadata key for the SHA-256 checksum.
 )
 
 // S3 Provider Endpoints with region.
@@ -59,7 +59,9 @@ type ProgressReader struct {
 }
 
 func (r *ProgressReader) Read(p []byte) (int, error) {
-	return r.fp.Read(p)
+	n, err := r.fp.Read(p)
+	atomic.AddInt64(&r.read, int64(n))
+	return n, err
 }
 
 func (r *ProgressReader) ReadAt(p []byte, off int64) (int, error) {
@@ -72,6 +74,16 @@ func (r *ProgressReader) ReadAt(p []byte, off int64) (int, error) {
 	return n, err
 }
 
+func (r *ProgressReader) add(n int64) {
+	atomic.AddInt64(&r.read, n)
+}
+
+// Seek sets the progress to the new offset, so a body read again after
+// signing or a retry isn't counted twice.
 func (r *ProgressReader) Seek(offset int64, whence int) (int64, error) {
-	return r.fp.Seek(offset, whence)
+	pos, err := r.fp.Seek(offset, whence)
+	if err == nil {
+		atomic.StoreInt64(&r.read, pos)
+	}
+	return pos, err
 }
@@ -1,15 +1,23 @@
 package net
 
 import (
+	"encoding/base64"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/alfg/openencoder/api/config"
 	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/stream"
 	"github.com/alfg/openencoder/api/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -35,7 +43,9 @@ type S3 struct {
 	Writer   *ProgressWriter
 	Reader   *ProgressReader
 
-	Config S3Config
+	Config      S3Config
+	PartSize    int64
+	Concurrency int
 }
 
 type progress struct {
@@ -45,14 +55,17 @@ type progress struct {
 
 // NewS3 creates a new S3 instance.
 func NewS3(config S3Config) *S3 {
-	config.Endpoint = getEndpoint(config.Provider, config.Region)
+	config.Endpoint = getEndpoint(config)
 
 	return &S3{
-		Config: config,
+		Config:      config,
+		PartSize:    getPartSize(),
+		Concurrency: getConcurrency(),
 	}
 }
 
-// Download downloads source files from S3.
+// Download downloads source files from S3 and verifies the downloaded file
+// against the object checksum.
 func (s *S3) Download(job types.Job) error {
 	log.Info("downloading from S3: ", job.Source)
 
@@ -61,25 +74,25 @@ func (s *S3) Download(job types.Job) error {
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
 	// Create session and client.
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:    aws.String(s.Config.Endpoint),
-		Region:      aws.String(s.Config.Region),
-		Credentials: credentials.NewStaticCredentials(s.Config.AccessKey, s.Config.SecretKey, ""),
-	})
+	sess, err := s.session()
 	if err != nil {
-		panic(err)
+		return err
 	}
 	s3Client := s3.New(sess)
-	downloader := s3manager.NewDownloader(sess)
+	downloader := s3manager.NewDownloader(sess, func(d *s3manager.Downloader) {
+		d.PartSize = s.PartSize
+		d.Concurrency = s.Concurrency
+	})
 
 	parsedURL, _ := url.Parse(job.Source)
 	key := parsedURL.Path
 
 	size, err := getFileSize(s3Client, s.Config.InboundBucket, key)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	log.Println("starting download, size: ", byteCountDecimal(size))
 
@@ -91,20 +104,29 @@ func (s *S3) Download(job types.Job) error {
 	}
 
 	// Download file to local.
-	go s.trackProgress("download")
+	s.startProgress("download")
+	defer s.finish()
 	if _, err = downloader.Download(s.Writer, &objInput); err != nil {
 		log.Printf("download failed! deleting file: %s", file.Name())
 		os.Remove(file.Name())
-		panic(err)
+		return err
 	}
-	file.Close()
 
-	s.finish()
-	return err
+	// Verify the download.
+	if err := verifyObject(s3Client, s.Config.InboundBucket, key, file.Name()); err != nil {
+		log.Printf("download verification failed! deleting file: %s", file.Name())
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
 }
 
-func (s *S3) trackProgress(t string) {
+func (s *S3) startProgress(t string) {
 	s.Progress.quit = make(chan struct{})
+	go s.trackProgress(t)
+}
+
+func (s *S3) trackProgress(t string) {
 	ticker := time.NewTicker(1 * time.Second)
 
 	for {
@@ -115,10 +137,10 @@ func (s *S3) trackProgress(t string) {
 		case <-ticker.C:
 			if t == "download" {
 				// Download progress.
-				s.Progress.Progress = float32(s.Writer.written*100) / float32(s.Writer.size)
+				s.Progress.Progress = float32(atomic.LoadInt64(&s.Writer.written)*100) / float32(s.Writer.size)
 			} else if t == "upload" {
 				// Upload progress.
-				s.Progress.Progress = float32(s.Reader.read*100/2) / float32(s.Reader.size) // Upload.
+				s.Progress.Progress = float32(atomic.LoadInt64(&s.Reader.read)*100) / float32(s.Reader.size)
 			}
 
 		}
@@ -129,9 +151,10 @@ func (s *S3) finish() {
 	close(s.Progress.quit)
 }
 
-// Upload uploads a file to S3.
-func (s *S3) Upload(job types.Job) error {
-	log.Info("uploading files to S3: ", job.Destination)
+// Upload uploads the output files to S3 and returns the SHA-256 checksum of
+// each uploaded file by file name.
+func (s *S3) Upload(job types.Job) (map[string]string, error) {
+	log.Info("uploading files to S3: ", job.OutputDestination())
 	defer log.Info("upload complete")
 
 	// Get list of files in output dir.
@@ -144,71 +167,328 @@ func (s *S3) Upload(job types.Job) error {
 		return nil
 	})
 
-	s.uploadDir(filelist, job)
-	return nil
+	return s.uploadDir(filelist, job)
 }
 
-func (s *S3) uploadDir(filelist []string, job types.Job) {
+func (s *S3) uploadDir(filelist []string, job types.Job) (map[string]string, error) {
+	checksums := map[string]string{}
 	for _, file := range filelist {
-		s.uploadFile(file, job)
+		checksum, err := s.uploadFile(file, job)
+		if err != nil {
+			return nil, err
+		}
+		checksums[filepath.Base(file)] = checksum
 	}
+	return checksums, nil
 }
 
-func (s *S3) uploadFile(path string, job types.Job) error {
-	log.Info("uploading file to S3.", job.Destination)
+func (s *S3) uploadFile(path string, job types.Job) (string, error) {
+	log.Info("uploading file to S3.", job.OutputDestination())
 
-	// Open source path file.
-	file, err := os.Open(path)
-	if err != nil {
-		return err
-	}
+	// Set key.
+	parsedURL, _ := url.Parse(job.OutputDestination())
+	key := parsedURL.Path + filepath.Base(path)
 
-	fileInfo, err := file.Stat()
+	// Checksum the file so each part and the final object can be verified.
+	checksum, err := checksumFile(path, s.PartSize)
 	if err != nil {
 		log.Println("upload error: ", err)
-		return err
+		return "", err
 	}
 
-	// Set key.
-	parsedURL, _ := url.Parse(job.Destination)
-	key := parsedURL.Path + filepath.Base(path)
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
 
 	s.Reader = &ProgressReader{
 		fp:   file,
-		size: fileInfo.Size(),
+		size: checksum.Size,
 	}
 
-	go s.trackProgress("upload")
+	s.startProgress("upload")
+	defer s.finish()
 
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:    aws.String(s.Config.Endpoint),
-		Region:      aws.String(s.Config.Region),
-		Credentials: credentials.NewStaticCredentials(s.Config.AccessKey, s.Config.SecretKey, ""),
+	sess, err := s.session()
+	if err != nil {
+		return "", err
+	}
+	svc := s3.New(sess)
+
+	var etag, sse string
+	if len(checksum.PartMD5s) <= 1 {
+		etag, sse, err = s.putObject(svc, key, checksum)
+	} else {
+		etag, sse, err = s.multipartUpload(svc, file, key, checksum)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Verify the uploaded object matches the local file. ETags of KMS
+	// encrypted objects are not an MD5 of the content, but the content MD5
+	// sent with each request was still checked on upload.
+	if sse == s3.ServerSideEncryptionAwsKms {
+		log.Warn("skipping checksum verification for KMS encrypted object: ", key)
+		return checksum.SHA256, nil
+	}
+	if etag != checksum.ETag() {
+		return "", fmt.Errorf("checksum mismatch for %s: expected etag %s, got %s", key, checksum.ETag(), etag)
+	}
+	return checksum.SHA256, nil
+}
+
+// putObject uploads the file of the progress reader in a single request, and
+// returns the ETag and server side encryption of the object.
+func (s *S3) putObject(svc *s3.S3, key string, checksum *fileChecksum) (string, string, error) {
+	resp, err := svc.PutObject(&s3.PutObjectInput{
+		Body:       s.Reader,
+		Bucket:     aws.String(s.Config.OutboundBucket),
+		Key:        aws.String(key),
+		ContentMD5: aws.String(base64.StdEncoding.EncodeToString(checksum.MD5)),
+		Metadata:   map[string]*string{MetadataSHA256: aws.String(checksum.SHA256)},
 	})
-	uploader := s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
-		u.PartSize = 5 * 1024 * 1024
-		u.LeavePartsOnError = true
+	if err != nil {
+		return "", "", err
+	}
+	return strings.Trim(aws.StringValue(resp.ETag), `"`), aws.StringValue(resp.ServerSideEncryption), nil
+}
+
+// multipartUpload uploads a file in parts concurrently, and returns the ETag
+// and server side encryption of the object. An interrupted upload for the
+// same key is resumed, skipping parts that were already uploaded.
+func (s *S3) multipartUpload(svc *s3.S3, file *os.File, key string, checksum *fileChecksum) (string, string, error) {
+	bucket := aws.String(s.Config.OutboundBucket)
+
+	uploadID, uploaded, err := s.findUpload(svc, key, checksum)
+	if err != nil {
+		return "", "", err
+	}
+
+	if uploadID == "" {
+		created, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+			Bucket:   bucket,
+			Key:      aws.String(key),
+			Metadata: map[string]*string{MetadataSHA256: aws.String(checksum.SHA256)},
+		})
+		if err != nil {
+			return "", "", err
+		}
+		uploadID = aws.StringValue(created.UploadId)
+	} else {
+		log.Infof("resuming upload %s with %d of %d parts", key, len(uploaded), len(checksum.PartMD5s))
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		parts = []*s3.CompletedPart{}
+		errs  = []error{}
+		sem   = make(chan struct{}, s.Concurrency)
+	)
+
+	for i, partMD5 := range checksum.PartMD5s {
+		partNumber := int64(i + 1)
+		offset := int64(i) * s.PartSize
+		size := s.PartSize
+		if offset+size > checksum.Size {
+			size = checksum.Size - offset
+		}
+
+		// Skip parts already uploaded by an interrupted upload.
+		if etag, ok := uploaded[partNumber]; ok {
+			parts = append(parts, &s3.CompletedPart{ETag: aws.String(etag), PartNumber: aws.Int64(partNumber)})
+			s.Reader.add(size)
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(partNumber, offset, size int64, partMD5 []byte) {
+			defer func() { <-sem; wg.Done() }()
+
+			resp, err := svc.UploadPart(&s3.UploadPartInput{
+				Body:       io.NewSectionReader(file, offset, size),
+				Bucket:     bucket,
+				Key:        aws.String(key),
+				PartNumber: aws.Int64(partNumber),
+				UploadId:   aws.String(uploadID),
+				ContentMD5: aws.String(base64.StdEncoding.EncodeToString(partMD5)),
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			parts = append(parts, &s3.CompletedPart{ETag: resp.ETag, PartNumber: aws.Int64(partNumber)})
+			s.Reader.add(size)
+		}(partNumber, offset, size, partMD5)
+	}
+	wg.Wait()
+
+	// Leave the upload in place so it can be resumed on retry.
+	if len(errs) > 0 {
+		return "", "", errs[0]
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+
+	resp, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          bucket,
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return strings.Trim(aws.StringValue(resp.ETag), `"`), aws.StringValue(resp.ServerSideEncryption), nil
+}
+
+// findUpload finds an interrupted multipart upload for a key and returns its
+// ID with the ETags of the parts already uploaded. Uploads with parts that do
+// not match the local file are aborted.
+func (s *S3) findUpload(svc *s3.S3, key string, checksum *fileChecksum) (string, map[int64]string, error) {
+	bucket := aws.String(s.Config.OutboundBucket)
+
+	uploads, err := svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{
+		Bucket: bucket,
+		Prefix: aws.String(key),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	var latest *s3.MultipartUpload
+	for _, u := range uploads.Uploads {
+		if aws.StringValue(u.Key) != key {
+			continue
+		}
+		if latest == nil || aws.TimeValue(u.Initiated).After(aws.TimeValue(latest.Initiated)) {
+			latest = u
+		}
+	}
+	if latest == nil {
+		return "", nil, nil
+	}
+	uploadID := aws.StringValue(latest.UploadId)
+
+	uploaded := map[int64]string{}
+	matches := true
+	err = svc.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   bucket,
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		for _, p := range page.Parts {
+			n := aws.Int64Value(p.PartNumber)
+			etag := strings.Trim(aws.StringValue(p.ETag), `"`)
+			if n < 1 || n > int64(len(checksum.PartMD5s)) ||
+				etag != fmt.Sprintf("%x", checksum.PartMD5s[n-1]) {
+				matches = false
+				return false
+			}
+			uploaded[n] = aws.StringValue(p.ETag)
+		}
+		return true
 	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The local file changed since the upload started, so start over.
+	if !matches {
+		log.Warnf("aborting stale upload %s for %s", uploadID, key)
+		_, err := svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   bucket,
+			Key:      aws.String(key),
+			UploadId: aws.String(uploadID),
+		})
+		return "", nil, err
+	}
+	return uploadID, uploaded, nil
+}
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Body:   s.Reader,
-		Bucket: aws.String(s.Config.OutboundBucket),
+// verifyObject verifies a local file against the checksum of an S3 object.
+// The SHA-256 metadata is used if present, otherwise the ETag.
+func verifyObject(svc *s3.S3, bucket, key, path string) error {
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
 		return err
 	}
-	file.Close()
+
+	for k, v := range head.Metadata {
+		if strings.EqualFold(k, MetadataSHA256) {
+			sum, err := sha256File(path)
+			if err != nil {
+				return err
+			}
+			if sum != aws.StringValue(v) {
+				return fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", key, aws.StringValue(v), sum)
+			}
+			return nil
+		}
+	}
+
+	// ETags of KMS encrypted objects are not an MD5 of the content.
+	if aws.StringValue(head.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms {
+		log.Warn("skipping checksum verification for KMS encrypted object: ", key)
+		return nil
+	}
+
+	etag := strings.Trim(aws.StringValue(head.ETag), `"`)
+	partSize := aws.Int64Value(head.ContentLength)
+	parts := 1
+	if i := strings.Index(etag, "-"); i != -1 {
+		parts, err = strconv.Atoi(etag[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid etag for %s: %s", key, etag)
+		}
+
+		// The size of the first part is the part size the object was uploaded with.
+		part, err := svc.HeadObject(&s3.HeadObjectInput{
+			Bucket:     aws.String(bucket),
+			Key:        aws.String(key),
+			PartNumber: aws.Int64(1),
+		})
+		if err != nil {
+			return err
+		}
+		partSize = aws.Int64Value(part.ContentLength)
+	}
+	if partSize <= 0 {
+		partSize = 1
+	}
+
+	checksum, err := checksumFile(path, partSize)
+	if err != nil {
+		return err
+	}
+
+	expected := fmt.Sprintf("%x", checksum.MD5)
+	if parts > 1 || strings.Contains(etag, "-") {
+		expected = multipartETag(checksum.PartMD5s)
+	}
+	if etag != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected etag %s, got %s", key, etag, expected)
+	}
 	return nil
 }
 
 // S3ListFiles lists s3 objects for a given prefix.
 func (s *S3) S3ListFiles(prefix string) (*s3.ListObjectsV2Output, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:    aws.String(s.Config.Endpoint),
-		Region:      aws.String(s.Config.Region),
-		Credentials: credentials.NewStaticCredentials(s.Config.AccessKey, s.Config.SecretKey, ""),
-	})
+	sess, err := s.session()
+	if err != nil {
+		return nil, err
+	}
 	svc := s3.New(sess)
 
 	resp, err := svc.ListObjectsV2(
@@ -221,13 +501,55 @@ func (s *S3) S3ListFiles(prefix string) (*s3.ListObjectsV2Output, error) {
 	return resp, err
 }
 
+// ListObjects lists all s3 objects under a prefix of the inbound bucket.
+func (s *S3) ListObjects(prefix string) ([]*s3.Object, error) {
+	sess, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	svc := s3.New(sess)
+
+	objects := []*s3.Object{}
+	err = svc.ListObjectsV2Pages(
+		&s3.ListObjectsV2Input{
+			Bucket: aws.String(s.Config.InboundBucket),
+			Prefix: aws.String(prefix),
+		},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			objects = append(objects, page.Contents...)
+			return true
+		},
+	)
+	return objects, err
+}
+
+// DeleteObjects deletes objects by key from the outbound bucket.
+func (s *S3) DeleteObjects(keys []string) error {
+	sess, err := s.session()
+	if err != nil {
+		return err
+	}
+	svc := s3.New(sess)
+
+	for _, key := range keys {
+		log.Info("deleting from S3: ", key)
+		_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.Config.OutboundBucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetPresignedURL generates a presigned URL from S3.
 func (s *S3) GetPresignedURL(job types.Job) (string, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Endpoint:    aws.String(s.Config.Endpoint),
-		Region:      aws.String(s.Config.Region),
-		Credentials: credentials.NewStaticCredentials(s.Config.AccessKey, s.Config.SecretKey, ""),
-	})
+	sess, err := s.session()
+	if err != nil {
+		return "", err
+	}
 	svc := s3.New(sess)
 
 	parsedURL, _ := url.Parse(job.Source)
@@ -244,6 +566,14 @@ func (s *S3) GetPresignedURL(job types.Job) (string, error) {
 	return urlStr, err
 }
 
+func (s *S3) session() (*session.Session, error) {
+	return session.NewSession(&aws.Config{
+		Endpoint:    aws.String(s.Config.Endpoint),
+		Region:      aws.String(s.Config.Region),
+		Credentials: credentials.NewStaticCredentials(s.Config.AccessKey, s.Config.SecretKey, ""),
+	})
+}
+
 func isDirectory(path string) bool {
 	fd, err := os.Stat(path)
 	if err != nil {
@@ -259,23 +589,32 @@ func isDirectory(path string) bool {
 	return false
 }
 
-func getEndpoint(provider, region string) string {
-	if strings.ToUpper(provider) == types.Custom {
-		db := data.New()
-		endpoint, err := db.Settings.GetSetting(types.S3Endpoint)
-		if err != nil {
-			return ""
-		}
-		return endpoint.Value
-	} else if strings.ToUpper(provider) == types.DigitalOceanSpaces {
-		return EndpointDigitalOceanSpacesRegion(region)
+func getEndpoint(config S3Config) string {
+	if strings.ToUpper(config.Provider) == types.Custom {
+		return config.Endpoint
+	} else if strings.ToUpper(config.Provider) == types.DigitalOceanSpaces {
+		return EndpointDigitalOceanSpacesRegion(config.Region)
+	}
+	return EndpointAmazonAWSRegion(config.Region)
+}
+
+func getPartSize() int64 {
+	size := config.Get().S3PartSize * 1024 * 1024
+	if size < s3manager.MinUploadPartSize {
+		return s3manager.MinUploadPartSize
+	}
+	return size
+}
+
+func getConcurrency() int {
+	if config.Get().S3Concurrency < 1 {
+		return s3manager.DefaultUploadConcurrency
 	}
-	return EndpointAmazonAWSRegion(region)
+	return config.Get().S3Concurrency
 }
 
-func trackTransferProgress(encodeID int64, s3 *S3) {
+func trackTransferProgress(guid string, encodeID int64, s3 *S3) {
 	db := data.New()
-	progressCh = make(chan struct{})
 	ticker := time.NewTicker(ProgressInterval)
 
 	for {
@@ -286,10 +625,10 @@ func trackTransferProgress(encodeID int64, s3 *S3) {
 		case <-ticker.C:
 			log.Info("transfer progress: ", s3.Progress.Progress)
 			err := db.Jobs.UpdateTransferProgressByID(encodeID, float64(s3.Progress.Progress))
-			fmt.Println(float64(s3.Progress.Progress))
 			if err != nil {
 				log.Error(err)
 			}
+			stream.Transfer(guid, float64(s3.Progress.Progress))
 		}
 	}
 }
@@ -0,0 +1,125 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/alfg/openencoder/api/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jlaffaye/ftp"
+)
+
+// GetSourceETag gets the ETag of a job source from the source storage. FTP
+// has no ETag, so the size and modified time are used.
+func GetSourceETag(job types.Job) (string, error) {
+	storage, err := GetSourceStorage(job)
+	if err != nil {
+		return "", err
+	}
+
+	if storage.Driver == types.StorageS3 {
+		return NewS3(storage.S3).ETag(job.Source)
+	} else if storage.Driver == types.StorageFTP {
+		f := NewFTP(storage.FTP.Addr, storage.FTP.Username, storage.FTP.Password)
+		entries, err := f.ListFiles(job.Source)
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			if e.Type == ftp.EntryTypeFile && (e.Name == path.Base(job.Source) || e.Name == job.Source) {
+				return fmt.Sprintf("%d-%d", e.Size, e.Time.Unix()), nil
+			}
+		}
+		return "", fmt.Errorf("source not found: %s", job.Source)
+	}
+	return "", errors.New("no driver set")
+}
+
+// GetSourceSize gets the size in bytes of a job source from the source storage.
+func GetSourceSize(job types.Job) (int64, error) {
+	storage, err := GetSourceStorage(job)
+	if err != nil {
+		return 0, err
+	}
+
+	if storage.Driver == types.StorageS3 {
+		return NewS3(storage.S3).Size(job.Source)
+	} else if storage.Driver == types.StorageFTP {
+		f := NewFTP(storage.FTP.Addr, storage.FTP.Username, storage.FTP.Password)
+		entries, err := f.ListFiles(job.Source)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if e.Type == ftp.EntryTypeFile && (e.Name == path.Base(job.Source) || e.Name == job.Source) {
+				return int64(e.Size), nil
+			}
+		}
+		return 0, fmt.Errorf("source not found: %s", job.Source)
+	}
+	return 0, errors.New("no driver set")
+}
+
+// GetProbeURL gets a URL FFProbe can read a job source from without
+// downloading it.
+func GetProbeURL(job types.Job) (string, error) {
+	storage, err := GetSourceStorage(job)
+	if err != nil {
+		return "", err
+	}
+
+	if storage.Driver == types.StorageS3 {
+		return NewS3(storage.S3).GetPresignedURL(job)
+	} else if storage.Driver == types.StorageFTP {
+		u := url.URL{
+			Scheme: "ftp",
+			User:   url.UserPassword(storage.FTP.Username, storage.FTP.Password),
+			Host:   storage.FTP.Addr,
+			Path:   "/" + strings.TrimPrefix(job.Source, "/"),
+		}
+		return u.String(), nil
+	}
+	return "", errors.New("no driver set")
+}
+
+// ETag gets the ETag of an object in the inbound bucket.
+func (s *S3) ETag(source string) (string, error) {
+	sess, err := s.session()
+	if err != nil {
+		return "", err
+	}
+	svc := s3.New(sess)
+
+	parsedURL, _ := url.Parse(source)
+	resp, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.Config.InboundBucket),
+		Key:    aws.String(parsedURL.Path),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(resp.ETag), `"`), nil
+}
+
+// Size gets the size in bytes of an object in the inbound bucket.
+func (s *S3) Size(source string) (int64, error) {
+	sess, err := s.session()
+	if err != nil {
+		return 0, err
+	}
+	svc := s3.New(sess)
+
+	parsedURL, _ := url.Parse(source)
+	resp, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.Config.InboundBucket),
+		Key:    aws.String(parsedURL.Path),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return aws.Int64Value(resp.ContentLength), nil
+}
@@ -0,0 +1,87 @@
+package net
+
+import (
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Storage describes a resolved storage driver and its connection settings.
+type Storage struct {
+	Driver string
+	S3     S3Config
+	FTP    FTPConfig
+}
+
+// FTPConfig describes a configuration for connecting to FTP.
+type FTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+// GetStorage resolves the storage for a named profile of an organization. If
+// no profile is given, the organization's storage settings are used.
+func GetStorage(orgID int64, profile string) (*Storage, error) {
+	db := data.New()
+
+	if profile != "" {
+		p, err := db.Storage.GetStorageProfileByName(orgID, profile)
+		if err != nil {
+			return nil, err
+		}
+		return storageFromProfile(p), nil
+	}
+
+	settings := db.Settings.GetSettings(orgID)
+	return &Storage{
+		Driver: types.GetSetting(types.StorageDriver, settings),
+		S3: S3Config{
+			AccessKey:      types.GetSetting(types.S3AccessKey, settings),
+			SecretKey:      types.GetSetting(types.S3SecretKey, settings),
+			Provider:       types.GetSetting(types.S3Provider, settings),
+			Endpoint:       types.GetSetting(types.S3Endpoint, settings),
+			Region:         types.GetSetting(types.S3OutboundBucketRegion, settings),
+			InboundBucket:  types.GetSetting(types.S3InboundBucket, settings),
+			OutboundBucket: types.GetSetting(types.S3OutboundBucket, settings),
+		},
+		FTP: FTPConfig{
+			Addr:     types.GetSetting(types.FTPAddr, settings),
+			Username: types.GetSetting(types.FTPUsername, settings),
+			Password: types.GetSetting(types.FTPPassword, settings),
+		},
+	}, nil
+}
+
+// GetSourceStorage resolves the storage a job's source is read from. Jobs
+// using the output of another job as their source read it from the outbound
+// bucket of that job's destination storage, set as their source profile.
+func GetSourceStorage(job types.Job) (*Storage, error) {
+	storage, err := GetStorage(job.OrgID, job.SourceProfile)
+	if err != nil {
+		return nil, err
+	}
+	if job.SourceFrom != 0 {
+		storage.S3.InboundBucket = storage.S3.OutboundBucket
+	}
+	return storage, nil
+}
+
+func storageFromProfile(p *types.StorageProfile) *Storage {
+	return &Storage{
+		Driver: p.Driver,
+		S3: S3Config{
+			AccessKey:      p.AccessKey,
+			SecretKey:      p.SecretKey,
+			Provider:       p.Provider,
+			Endpoint:       p.Endpoint,
+			Region:         p.Region,
+			InboundBucket:  p.InboundBucket,
+			OutboundBucket: p.OutboundBucket,
+		},
+		FTP: FTPConfig{
+			Addr:     p.FTPAddr,
+			Username: p.FTPUsername,
+			Password: p.FTPPassword,
+		},
+	}
+}
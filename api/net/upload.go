@@ -7,69 +7,45 @@ import (
 	"github.com/alfg/openencoder/api/types"
 )
 
-// Upload uploads a job based on the driver setting.
-func Upload(job types.Job) error {
-	db := data.New()
-	driver, err := db.Settings.GetSetting(types.StorageDriver)
+// Upload uploads a job based on the destination storage profile and returns
+// the SHA-256 checksum of each uploaded file by file name.
+func Upload(job types.Job) (map[string]string, error) {
+	storage, err := GetStorage(job.OrgID, job.DestProfile)
 	if err != nil {
-		return errors.New("no driver set")
+		return nil, err
 	}
 
-	if driver.Value == "s3" {
-		if err := s3Upload(job); err != nil {
-			return err
-		}
-		return nil
-	} else if driver.Value == "ftp" {
-		if err := ftpUpload(job); err != nil {
-			return err
-		}
-		return nil
+	if storage.Driver == types.StorageS3 {
+		return s3Upload(job, storage.S3)
+	} else if storage.Driver == types.StorageFTP {
+		return ftpUpload(job, storage.FTP)
 	}
-	return errors.New("no driver set")
+	return nil, errors.New("no driver set")
 }
 
 // GetUploader gets the upload function.
-func s3Upload(job types.Job) error {
-	// Get credentials from settings.
+func s3Upload(job types.Job, config S3Config) (map[string]string, error) {
 	db := data.New()
-	settings := db.Settings.GetSettings()
-
-	config := S3Config{
-		AccessKey:      types.GetSetting(types.S3AccessKey, settings),
-		SecretKey:      types.GetSetting(types.S3SecretKey, settings),
-		Provider:       types.GetSetting(types.S3Provider, settings),
-		Region:         types.GetSetting(types.S3OutboundBucketRegion, settings),
-		InboundBucket:  types.GetSetting(types.S3InboundBucket, settings),
-		OutboundBucket: types.GetSetting(types.S3OutboundBucket, settings),
-	}
 
 	// Get job data.
 	j, err := db.Jobs.GetJobByGUID(job.GUID)
 	if err != nil {
 		log.Error(err)
-		return err
+		return nil, err
 	}
 	encodeID := j.EncodeID
 
 	s3 := NewS3(config)
-	go trackTransferProgress(encodeID, s3)
-	err = s3.Upload(job)
+	progressCh = make(chan struct{})
+	go trackTransferProgress(job.GUID, encodeID, s3)
+	checksums, err := s3.Upload(job)
 	close(progressCh)
 
-	return err
+	return checksums, err
 }
 
 // GetFTPUploader sets the FTP upload function.
-func ftpUpload(job types.Job) error {
-	db := data.New()
-	settings := db.Settings.GetSettings()
-
-	addr := types.GetSetting(types.FTPAddr, settings)
-	user := types.GetSetting(types.FTPUsername, settings)
-	pass := types.GetSetting(types.FTPPassword, settings)
-
-	f := NewFTP(addr, user, pass)
-	err := f.Upload(job)
-	return err
+func ftpUpload(job types.Job, config FTPConfig) (map[string]string, error) {
+	f := NewFTP(config.Addr, config.Username, config.Password)
+	return f.Upload(job)
 }
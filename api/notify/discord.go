@@ -0,0 +1,25 @@
+package notify
+
+// Discord sends messages to a Discord webhook.
+type Discord struct {
+	WebhookURL string
+}
+
+var discordColors = map[string]int{
+	LevelInfo:    0x439fe0,
+	LevelSuccess: 0x2eb886,
+	LevelError:   0xa30200,
+}
+
+// Send sends a message as a Discord embed.
+func (d *Discord) Send(msg Message) error {
+	return postJSON(d.WebhookURL, map[string]interface{}{
+		"embeds": []map[string]interface{}{
+			map[string]interface{}{
+				"title":       msg.Title,
+				"description": msg.Text,
+				"color":       discordColors[msg.Level],
+			},
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Dispatch notifies the active channels routed to a job event. The legacy
+// SLACK_WEBHOOK setting is notified of completed and failed jobs.
+func Dispatch(event string, job types.Job) {
+	db := data.New()
+	channels, err := db.Notifications.GetActiveNotificationChannelsByEvent(event)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, c := range *channels {
+		if err := send(c, event, job); err != nil {
+			log.Errorf("notification channel %s: %s", c.Name, err)
+		}
+	}
+
+	if event != types.EventJobCompleted && event != types.EventJobFailed {
+		return
+	}
+	webhook, err := db.Settings.GetSetting(types.DefaultOrgID, types.SlackWebhook)
+	if err != nil || webhook.Value == "" {
+		return
+	}
+	legacy := types.NotificationChannel{
+		Type:   types.NotifySlack,
+		Config: types.NotificationConfig{URL: webhook.Value},
+	}
+	if err := send(legacy, event, job); err != nil {
+		log.Errorf("slack webhook: %s", err)
+	}
+}
+
+// Test sends a test message to a channel.
+func Test(c types.NotificationChannel) error {
+	job := types.Job{
+		GUID:        "test",
+		Preset:      "test",
+		Source:      "s3:///src/test.mp4",
+		Destination: "s3:///dst/test/",
+		Status:      types.JobCompleted,
+	}
+	return send(c, types.EventJobCompleted, job)
+}
+
+func send(c types.NotificationChannel, event string, job types.Job) error {
+	n, err := New(c)
+	if err != nil {
+		return err
+	}
+	msg, err := NewMessage(c.Template, event, job)
+	if err != nil {
+		return err
+	}
+	return n.Send(msg)
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// DefaultSMTPPort is used when no SMTP port is set.
+const DefaultSMTPPort = 25
+
+// Email sends messages by SMTP.
+type Email struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Send sends a message as a plain text email.
+func (e *Email) Send(msg Message) error {
+	port := e.Port
+	if port == 0 {
+		port = DefaultSMTPPort
+	}
+	addr := fmt.Sprintf("%s:%d", e.Host, port)
+
+	// Authenticate only if credentials are set, e.g. not for a local sink.
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Title)
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.Replace(msg.Text, "\n", "\r\n", -1))
+
+	return smtp.SendMail(addr, auth, e.From, e.To, b.Bytes())
+}
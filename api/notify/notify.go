@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/alfg/openencoder/api/logging"
+	"github.com/alfg/openencoder/api/types"
+)
+
+var log = logging.Log
+
+// Message levels.
+const (
+	LevelInfo    = "info"
+	LevelSuccess = "success"
+	LevelError   = "error"
+)
+
+// Timeout for sending a notification.
+const Timeout = 10 * time.Second
+
+// Message defines a notification message.
+type Message struct {
+	Event string
+	Level string
+	Title string
+	Text  string
+}
+
+// Notifier sends notification messages to a channel.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// New creates the Notifier for a notification channel.
+func New(c types.NotificationChannel) (Notifier, error) {
+	switch c.Type {
+	case types.NotifySlack:
+		return &Slack{WebhookURL: c.Config.URL}, nil
+	case types.NotifyWebhook:
+		return &Webhook{URL: c.Config.URL}, nil
+	case types.NotifyTeams:
+		return &Teams{WebhookURL: c.Config.URL}, nil
+	case types.NotifyDiscord:
+		return &Discord{WebhookURL: c.Config.URL}, nil
+	case types.NotifyEmail:
+		return &Email{
+			Host:     c.Config.SMTPHost,
+			Port:     c.Config.SMTPPort,
+			Username: c.Config.SMTPUsername,
+			Password: c.Config.SMTPPassword,
+			From:     c.Config.From,
+			To:       c.Config.To,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown notification channel type: %s", c.Type)
+}
+
+// Validate checks a notification channel has the settings its type needs.
+func Validate(c types.NotificationChannel) error {
+	if _, err := New(c); err != nil {
+		return err
+	}
+	if c.Type == types.NotifyEmail {
+		if c.Config.SMTPHost == "" || c.Config.From == "" || len(c.Config.To) == 0 {
+			return errors.New("email requires smtp_host, from and to")
+		}
+	} else if c.Config.URL == "" {
+		return fmt.Errorf("%s requires url", c.Type)
+	}
+	return ParseTemplate(c.Template)
+}
+
+var client = &http.Client{Timeout: Timeout}
+
+// postJSON posts a JSON payload and checks for a successful response.
+func postJSON(url string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -1,33 +1,32 @@
 package notify
 
-import (
-	"bytes"
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
-)
+// Slack sends messages to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+}
 
-// SendSlackMessage sends a slack webhook post with a message.
-// url is the webhook.
-func SendSlackMessage(url string, message string) error {
-	payload, _ := json.Marshal(map[string]interface{}{
+var slackColors = map[string]string{
+	LevelInfo:    "#439fe0",
+	LevelSuccess: "good",
+	LevelError:   "danger",
+}
+
+// Send sends a message as a Slack attachment.
+func (s *Slack) Send(msg Message) error {
+	return postJSON(s.WebhookURL, map[string]interface{}{
 		"attachments": []map[string]string{
 			map[string]string{
-				"text":  message,
-				"color": "good",
+				"title": msg.Title,
+				"text":  msg.Text,
+				"color": slackColors[msg.Level],
 			},
 		},
 	})
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+}
 
-	_, err = ioutil.ReadAll(resp.Body)
-	return err
+// SendSlackMessage sends a slack webhook post with a message.
+// url is the webhook.
+func SendSlackMessage(url string, message string) error {
+	s := &Slack{WebhookURL: url}
+	return s.Send(Message{Level: LevelSuccess, Text: message})
 }
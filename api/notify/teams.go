@@ -0,0 +1,24 @@
+package notify
+
+// Teams sends messages to a Microsoft Teams incoming webhook.
+type Teams struct {
+	WebhookURL string
+}
+
+var teamsColors = map[string]string{
+	LevelInfo:    "439FE0",
+	LevelSuccess: "2EB886",
+	LevelError:   "A30200",
+}
+
+// Send sends a message as a Teams message card.
+func (t *Teams) Send(msg Message) error {
+	return postJSON(t.WebhookURL, map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    msg.Title,
+		"title":      msg.Title,
+		"text":       msg.Text,
+		"themeColor": teamsColors[msg.Level],
+	})
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// DefaultTemplate is the message body used when a channel has no template.
+const DefaultTemplate = `Job ID: {{.Job.GUID}}
+Preset: {{.Job.Preset}}
+Source: {{.Job.Source}}
+Destination: {{.Job.OutputDestination}}{{.Job.Output}}
+Status: {{.Job.Status}}`
+
+// TemplateData defines the data available to message templates.
+type TemplateData struct {
+	Event string
+	Job   types.Job
+}
+
+var titles = map[string]string{
+	types.EventJobQueued:    "Encode queued",
+	types.EventJobStarted:   "Encode started",
+	types.EventJobProgress:  "Encode in progress",
+	types.EventJobCompleted: "Encode successful!",
+	types.EventJobFailed:    "Encode failed!",
+	types.EventJobCancelled: "Encode cancelled",
+}
+
+var levels = map[string]string{
+	types.EventJobCompleted: LevelSuccess,
+	types.EventJobFailed:    LevelError,
+}
+
+// ParseTemplate checks a message template is valid.
+func ParseTemplate(tmpl string) error {
+	_, err := template.New("message").Parse(tmpl)
+	return err
+}
+
+// Render renders a message template, or the DefaultTemplate if empty.
+func Render(tmpl string, data TemplateData) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+
+	t, err := template.New("message").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// NewMessage renders the message for a job event with a template.
+func NewMessage(tmpl string, event string, job types.Job) (Message, error) {
+	text, err := Render(tmpl, TemplateData{Event: event, Job: job})
+	if err != nil {
+		return Message{}, err
+	}
+
+	level, ok := levels[event]
+	if !ok {
+		level = LevelInfo
+	}
+	return Message{
+		Event: event,
+		Level: level,
+		Title: titles[event],
+		Text:  text,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+var testJob = types.Job{
+	GUID:        "abc",
+	Preset:      "h264",
+	Source:      "s3:///src/video.mp4",
+	Destination: "s3:///dst/",
+	Output:      "video.mp4",
+	Status:      types.JobCompleted,
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", "Job ID: abc\nPreset: h264\nSource: s3:///src/video.mp4\n" +
+			"Destination: s3:///dst/video.mp4\nStatus: completed", false},
+		{"custom", "{{.Event}}: {{.Job.GUID}} is {{.Job.Status}}", "job.completed: abc is completed", false},
+		{"plain", "done", "done", false},
+		{"invalid", "{{.Job.GUID", "", true},
+		{"unknown field", "{{.Job.Nope}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.tmpl, TemplateData{Event: types.EventJobCompleted, Job: testJob})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	if err := ParseTemplate(DefaultTemplate); err != nil {
+		t.Errorf("ParseTemplate(DefaultTemplate) error = %v", err)
+	}
+	if err := ParseTemplate("{{.Job.GUID"); err == nil {
+		t.Error("ParseTemplate() error = nil, want error")
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	tests := []struct {
+		event string
+		level string
+		title string
+	}{
+		{types.EventJobQueued, LevelInfo, "Encode queued"},
+		{types.EventJobStarted, LevelInfo, "Encode started"},
+		{types.EventJobProgress, LevelInfo, "Encode in progress"},
+		{types.EventJobCompleted, LevelSuccess, "Encode successful!"},
+		{types.EventJobFailed, LevelError, "Encode failed!"},
+		{types.EventJobCancelled, LevelInfo, "Encode cancelled"},
+		{"job.unknown", LevelInfo, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			m, err := NewMessage("{{.Event}}", tt.event, testJob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Event != tt.event || m.Text != tt.event {
+				t.Errorf("Event, Text = %s, %s, want %s", m.Event, m.Text, tt.event)
+			}
+			if m.Level != tt.level {
+				t.Errorf("Level = %s, want %s", m.Level, tt.level)
+			}
+			if m.Title != tt.title {
+				t.Errorf("Title = %q, want %q", m.Title, tt.title)
+			}
+		})
+	}
+
+	if _, err := NewMessage("{{.Job.GUID", types.EventJobQueued, testJob); err == nil {
+		t.Error("NewMessage() error = nil, want error")
+	}
+}
@@ -0,0 +1,16 @@
+package notify
+
+// Webhook sends messages as JSON to a generic HTTP endpoint.
+type Webhook struct {
+	URL string
+}
+
+// Send posts a message as JSON.
+func (w *Webhook) Send(msg Message) error {
+	return postJSON(w.URL, map[string]string{
+		"event": msg.Event,
+		"level": msg.Level,
+		"title": msg.Title,
+		"text":  msg.Text,
+	})
+}
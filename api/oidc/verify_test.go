@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{
+		Issuer: "https://idp.example.com/",
+		keys:   &keySet{keys: map[string]*rsa.PublicKey{"k1": &key.PublicKey}},
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://idp.example.com",
+			"sub":   "user-1",
+			"aud":   "client",
+			"nonce": "nonce",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		kid    string
+		valid  bool
+	}{
+		{"valid", func(c jwt.MapClaims) {}, "k1", true},
+		{"audience list", func(c jwt.MapClaims) { c["aud"] = []string{"other", "client"} }, "k1", true},
+		{"no key ID with one key", func(c jwt.MapClaims) {}, "", true},
+		{"missing exp", func(c jwt.MapClaims) { delete(c, "exp") }, "k1", false},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "k1", false},
+		{"missing sub", func(c jwt.MapClaims) { delete(c, "sub") }, "k1", false},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "k1", false},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other" }, "k1", false},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "other" }, "k1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			if tt.kid != "" {
+				token.Header["kid"] = tt.kid
+			}
+			raw, err := token.SignedString(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.Verify(raw, "client", "nonce")
+			if (err == nil) != tt.valid {
+				t.Errorf("Verify() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	claims := Claims{
+		"preferred_username": "alice",
+		"groups":             []interface{}{"admins", 1, "ops"},
+		"role":               "admin",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"operator"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		claim   string
+		str     string
+		strings []string
+	}{
+		{"string", "preferred_username", "alice", []string{"alice"}},
+		{"list", "groups", "", []string{"admins", "ops"}},
+		{"single string list", "role", "admin", []string{"admin"}},
+		{"nested", "realm_access.roles", "", []string{"operator"}},
+		{"missing", "email", "", nil},
+		{"missing nested", "realm_access.groups.x", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := claims.String(tt.claim); got != tt.str {
+				t.Errorf("String(%q) = %q, want %q", tt.claim, got, tt.str)
+			}
+			got := claims.Strings(tt.claim)
+			if len(got) != len(tt.strings) {
+				t.Fatalf("Strings(%q) = %v, want %v", tt.claim, got, tt.strings)
+			}
+			for i := range got {
+				if got[i] != tt.strings[i] {
+					t.Errorf("Strings(%q) = %v, want %v", tt.claim, got, tt.strings)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// API key authentication. Keys are sent as "Authorization: ApiKey <key>" or
+// with the X-API-Key header.
+const (
+	APIKeyHeader     = "X-API-Key"
+	APIKeyAuthScheme = "ApiKey"
+	APIKeyLength     = 32 // Random bytes in a key.
+	APIKeyPrefixSize = 8  // Characters of the key stored for display.
+)
+
+type apiKeyRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Username    string `json:"username" binding:"required"`
+	Role        string `json:"role"`
+	ExpiresDays int    `json:"expires_days" binding:"min=0"`
+	RateLimit   int    `json:"rate_limit" binding:"min=0"`
+}
+
+// authMiddleware authenticates requests with an API key if one is sent,
+// otherwise with the JWT middleware.
+func authMiddleware(jwtMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	jwtAuth := jwtMiddleware.MiddlewareFunc()
+
+	return func(c *gin.Context) {
+		key := apiKeyFromRequest(c)
+		if key == "" {
+			jwtAuth(c)
+			return
+		}
+
+		db := data.New()
+		k, err := db.APIKeys.GetAPIKeyByHash(hashAPIKey(key))
+		if err != nil || !k.Active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid API key",
+			})
+			return
+		}
+
+		// Keys act in their organization, with the service account's role
+		// in it.
+		account, err := db.Users.GetUserByID(int(k.UserID))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid API key",
+			})
+			return
+		}
+		member, err := db.Organizations.GetMember(k.OrgID, k.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid API key",
+			})
+			return
+		}
+		if _, ok := getRole(member.EffectiveRole()); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid API key",
+			})
+			return
+		}
+
+		if !checkRateLimit(c, k) {
+			return
+		}
+
+		go db.APIKeys.UpdateAPIKeyLastUsedByID(k.ID)
+
+		c.Set(JwtIdentityKey, &types.User{
+			Username: k.Username,
+			Role:     account.Role,
+			OrgID:    k.OrgID,
+			OrgRole:  member.EffectiveRole(),
+		})
+		c.Next()
+	}
+}
+
+// apiKeyFromRequest gets the API key sent with a request, if any.
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) == 2 && parts[0] == APIKeyAuthScheme {
+		return strings.TrimSpace(parts[1])
+	}
+	return ""
+}
+
+// generateAPIKey returns a new random API key.
+func generateAPIKey() (string, error) {
+	b := make([]byte, APIKeyLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return types.APIKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the hex encoded SHA-256 hash of a key, as stored.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func getAPIKeysHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	orgID := contextOrgID(c)
+
+	var wg sync.WaitGroup
+	var keys *[]types.APIKey
+	var keysCount int
+
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		keys = db.APIKeys.GetAPIKeys(orgID, (pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		keysCount = db.APIKeys.GetAPIKeysCount(orgID)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":   keysCount,
+		"apikeys": keys,
+	})
+}
+
+func getAPIKeyByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	k, err := db.APIKeys.GetAPIKeyByID(contextOrgID(c), int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "API key does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"apikey": k,
+	})
+}
+
+func createAPIKeyHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+
+	// Decode json.
+	var json apiKeyRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if json.Role != "" {
+		if _, ok := getRole(json.Role); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "role does not exist",
+			})
+			return
+		}
+	}
+
+	// Use the service account, or create it with the role if it doesn't exist.
+	orgID := contextOrgID(c)
+	db := data.New()
+	account, err := db.Users.GetUserByUsername(json.Username)
+	if err != nil {
+		if json.Role == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "role is required to create a service account",
+			})
+			return
+		}
+
+		if account, err = createServiceAccount(json.Username, json.Role, orgID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "error creating service account",
+			})
+			return
+		}
+	} else if !account.ServiceAccount {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "user is not a service account",
+		})
+		return
+	} else if !serviceAccountInOrg(account.ID, orgID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "service account belongs to another organization",
+		})
+		return
+	}
+
+	// Add the service account to the organization if it isn't a member.
+	member, err := db.Organizations.GetMember(orgID, account.ID)
+	if err != nil {
+		if json.Role == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "role is required to add the service account to the organization",
+			})
+			return
+		}
+		if err := db.Organizations.SetMember(orgID, account.ID, json.Role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "error adding service account to the organization",
+			})
+			return
+		}
+		member = &types.OrganizationMember{Role: json.Role}
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error creating API key",
+		})
+		return
+	}
+
+	k := types.APIKey{
+		UserID:    account.ID,
+		Name:      json.Name,
+		Prefix:    key[:len(types.APIKeyPrefix)+APIKeyPrefixSize],
+		KeyHash:   hashAPIKey(key),
+		CreatedBy: user.(*types.User).Username,
+		RateLimit: json.RateLimit,
+		OrgID:     orgID,
+	}
+	if json.ExpiresDays > 0 {
+		expires := time.Now().AddDate(0, 0, json.ExpiresDays)
+		k.ExpiresDate.String = expires.UTC().Format(time.RFC3339)
+		k.ExpiresDate.Valid = true
+	}
+
+	created, err := db.APIKeys.CreateAPIKey(k)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating API key",
+		})
+		return
+	}
+	created.Username = account.Username
+	created.Role = member.EffectiveRole()
+	created.Active = account.Active
+
+	// The key is only returned once.
+	c.JSON(http.StatusCreated, gin.H{
+		"status": http.StatusCreated,
+		"apikey": created,
+		"key":    key,
+	})
+}
+
+func deleteAPIKeyByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if _, err := db.APIKeys.GetAPIKeyByID(contextOrgID(c), int64(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "API key does not exist",
+		})
+		return
+	}
+
+	if err := db.APIKeys.RevokeAPIKeyByID(int64(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error revoking API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "API key revoked",
+	})
+}
+
+// createServiceAccount creates a service account user as a member of an
+// organization with a role. Its system role is always guest, so the role only
+// applies in the organization. Service accounts get a random password and
+// can't log in; they use API keys.
+func createServiceAccount(username, role string, orgID int64) (*types.User, error) {
+	hash, err := randomPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	db := data.New()
+	account, err := db.Users.CreateUser(types.User{
+		Username:       username,
+		Password:       hash,
+		Role:           RoleGuest,
+		ServiceAccount: true,
+		OrgID:          orgID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Organizations.SetMember(orgID, account.ID, role); err != nil {
+		return nil, err
+	}
+	account.Active = true
+	return account, nil
+}
+
+// serviceAccountInOrg checks if a service account is only a member of an
+// organization, so it can't be pulled in from, or keyed for, another one.
+func serviceAccountInOrg(userID, orgID int64) bool {
+	db := data.New()
+	for _, m := range *db.Organizations.GetUserMemberships(userID) {
+		if m.OrgID != orgID {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// Login methods recorded in the audit log.
+const (
+	LoginMethodPassword = "password"
+	LoginMethodTOTP     = "totp"
+	LoginMethodOIDC     = "oidc"
+)
+
+// Max lengths of the audit log fields set from login attempts.
+const (
+	auditActorMaxLength   = 128
+	auditMessageMaxLength = 256
+)
+
+// recordAudit appends an action of the request's user to the audit log.
+func recordAudit(c *gin.Context, action, targetType string, targetID interface{}, changes types.AuditChanges) {
+	entry := types.AuditEntry{
+		IP:         c.ClientIP(),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Success:    true,
+		Changes:    changes,
+	}
+	if user, ok := c.Get(JwtIdentityKey); ok {
+		if u, ok := user.(*types.User); ok {
+			entry.Actor = u.Username
+			entry.OrgID.Int64 = u.OrgID
+			entry.OrgID.Valid = u.OrgID != 0
+		}
+	}
+
+	db := data.New()
+	if err := db.Audit.CreateAuditEntry(entry); err != nil {
+		log.Errorf("audit %s: %s", action, err)
+	}
+}
+
+// recordLogin appends a login attempt to the audit log.
+func recordLogin(c *gin.Context, username, method string, success bool, message string) {
+	entry := types.AuditEntry{
+		Actor:      username,
+		IP:         c.ClientIP(),
+		Action:     types.AuditLogin,
+		TargetType: "user",
+		TargetID:   username,
+		Success:    success,
+		Message:    method,
+	}
+	if message != "" {
+		entry.Message = method + ": " + message
+	}
+	entry.Actor = truncate(entry.Actor, auditActorMaxLength)
+	entry.TargetID = truncate(entry.TargetID, auditActorMaxLength)
+	entry.Message = truncate(entry.Message, auditMessageMaxLength)
+
+	db := data.New()
+	if err := db.Audit.CreateAuditEntry(entry); err != nil {
+		log.Errorf("audit %s: %s", types.AuditLogin, err)
+	}
+}
+
+// auditDiff gets the changed fields between two sets of values. The values of
+// secret fields are redacted, unless empty.
+func auditDiff(before, after map[string]interface{}, secrets map[string]bool) types.AuditChanges {
+	changes := types.AuditChanges{}
+	for k, a := range after {
+		b, ok := before[k]
+		if ok && fmt.Sprint(b) == fmt.Sprint(a) {
+			continue
+		}
+		if secrets[k] {
+			b, a = redact(b), redact(a)
+		}
+		changes[k] = types.AuditChange{Before: b, After: a}
+	}
+	return changes
+}
+
+// redact replaces a secret value, unless empty.
+func redact(v interface{}) interface{} {
+	if v == nil || fmt.Sprint(v) == "" {
+		return v
+	}
+	return types.AuditRedacted
+}
+
+// truncate shortens a string to a max length in bytes, dropping a split
+// character at the end.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
+
+// presetAuditValues gets the audited fields of a preset.
+func presetAuditValues(p *types.Preset) map[string]interface{} {
+	v := map[string]interface{}{
+		"name":        p.Name,
+		"description": p.Description,
+		"output":      p.Output,
+		"data":        p.Data,
+	}
+	if p.Active != nil {
+		v["active"] = *p.Active
+	}
+	return v
+}
+
+// storageProfileSecrets are the secret fields of storage profiles.
+var storageProfileSecrets = map[string]bool{
+	"access_key":   true,
+	"secret_key":   true,
+	"ftp_username": true,
+	"ftp_password": true,
+}
+
+// storageProfileAuditValues gets the audited fields of a storage profile.
+func storageProfileAuditValues(p *types.StorageProfile) map[string]interface{} {
+	return map[string]interface{}{
+		"name":            p.Name,
+		"driver":          p.Driver,
+		"provider":        p.Provider,
+		"endpoint":        p.Endpoint,
+		"region":          p.Region,
+		"access_key":      p.AccessKey,
+		"secret_key":      p.SecretKey,
+		"inbound_bucket":  p.InboundBucket,
+		"outbound_bucket": p.OutboundBucket,
+		"ftp_addr":        p.FTPAddr,
+		"ftp_username":    p.FTPUsername,
+		"ftp_password":    p.FTPPassword,
+	}
+}
+
+func getAuditHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	filter, err := auditFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	filter.Offset = (pageInt - 1) * countInt
+	filter.Count = countInt
+
+	db := data.New()
+	entries := db.Audit.GetAuditEntries(filter)
+	entriesCount := db.Audit.GetAuditEntriesCount(filter)
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":   entriesCount,
+		"entries": entries,
+	})
+}
+
+// auditFilterFromQuery sets an audit log filter from the query parameters.
+func auditFilterFromQuery(c *gin.Context) (data.AuditFilter, error) {
+	filter := data.AuditFilter{
+		Actor:      c.Query("actor"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+
+	if action := c.Query("action"); action != "" {
+		filter.Action = strings.Split(action, ",")
+	}
+
+	if org := c.Query("org_id"); org != "" {
+		id, err := strconv.ParseInt(org, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid org_id: %s", org)
+		}
+		filter.OrgID = id
+	}
+
+	if success := c.Query("success"); success != "" {
+		b, err := strconv.ParseBool(success)
+		if err != nil {
+			return filter, fmt.Errorf("invalid success: %s", success)
+		}
+		filter.Success = &b
+	}
+
+	var err error
+	if filter.CreatedAfter, err = filterDate("created_after", c.Query("created_after")); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = filterDate("created_before", c.Query("created_before")); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/helpers"
+	"github.com/alfg/openencoder/api/ingest"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/xid"
+)
+
+type ingestRuleRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Bucket        string   `json:"bucket" binding:"required"`
+	Prefix        string   `json:"prefix"`
+	Glob          string   `json:"glob"`
+	Presets       []string `json:"presets" binding:"required,min=1"`
+	SourceProfile string   `json:"source_profile"`
+	Destination   string   `json:"dest" binding:"required"`
+	DestProfile   string   `json:"dest_profile"`
+	Active        *bool    `json:"active" binding:"required"`
+}
+
+func getIngestRulesHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var rules *[]types.IngestRule
+	var rulesCount int
+
+	orgID := contextOrgID(c)
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		rules = db.Ingest.GetIngestRules(orgID, (pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		rulesCount = db.Ingest.GetIngestRulesCount(orgID)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count": rulesCount,
+		"rules": rules,
+	})
+}
+
+func getIngestRuleByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	rule, err := db.Ingest.GetIngestRuleByID(contextOrgID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Ingest rule does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"rule":   rule,
+	})
+}
+
+func createIngestRuleHandler(c *gin.Context) {
+	// Decode json.
+	var json ingestRuleRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := ingestRuleFromRequest(json)
+	rule.OrgID = contextOrgID(c)
+	if err := validateIngestRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	db := data.New()
+	created, err := db.Ingest.CreateIngestRule(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating ingest rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": http.StatusCreated,
+		"rule":   created,
+	})
+}
+
+func updateIngestRuleByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json ingestRuleRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	if _, err := db.Ingest.GetIngestRuleByID(contextOrgID(c), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Ingest rule does not exist",
+		})
+		return
+	}
+
+	rule := ingestRuleFromRequest(json)
+	rule.OrgID = contextOrgID(c)
+	if err := validateIngestRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	updated, err := db.Ingest.UpdateIngestRuleByID(id, rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating ingest rule",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteIngestRuleByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if _, err := db.Ingest.GetIngestRuleByID(contextOrgID(c), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Ingest rule does not exist",
+		})
+		return
+	}
+	if err := db.Ingest.DeleteIngestRuleByID(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error deleting ingest rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"deleted": true,
+	})
+}
+
+func ingestRuleFromRequest(json ingestRuleRequest) types.IngestRule {
+	glob := json.Glob
+	if glob == "" {
+		glob = "*"
+	}
+
+	return types.IngestRule{
+		Name:          json.Name,
+		Bucket:        json.Bucket,
+		Prefix:        json.Prefix,
+		Glob:          glob,
+		Presets:       json.Presets,
+		SourceProfile: json.SourceProfile,
+		Destination:   json.Destination,
+		DestProfile:   json.DestProfile,
+		Active:        *json.Active,
+	}
+}
+
+func validateIngestRule(r types.IngestRule) error {
+	if _, err := path.Match(r.Glob, ""); err != nil {
+		return fmt.Errorf("invalid glob: %s", r.Glob)
+	}
+	if err := helpers.ValidateTemplate(r.Destination); err != nil {
+		return err
+	}
+	if err := validateStorageProfiles(r.OrgID, r.SourceProfile, r.DestProfile); err != nil {
+		return err
+	}
+
+	// Jobs download the object from the source storage, so the rule only
+	// applies to events of its inbound bucket.
+	bucket, err := ingestRuleBucket(r)
+	if err != nil {
+		return err
+	}
+	if r.Bucket != bucket {
+		return fmt.Errorf("bucket must be the inbound bucket of the source storage: %s", bucket)
+	}
+	return nil
+}
+
+// ingestRuleBucket gets the inbound bucket of the source storage of a rule.
+func ingestRuleBucket(r types.IngestRule) (string, error) {
+	storage, err := net.GetStorage(r.OrgID, r.SourceProfile)
+	if err != nil {
+		return "", err
+	}
+	if storage.Driver != types.StorageS3 {
+		return "", fmt.Errorf("source storage must use the %s driver", types.StorageS3)
+	}
+	return storage.S3.InboundBucket, nil
+}
+
+// startIngest consumes S3 event notifications from the configured queue.
+func startIngest() {
+	cfg := config.Get()
+	if cfg.IngestQueueURL == "" {
+		log.Info("ingest queue disabled")
+		return
+	}
+
+	consumer, err := ingest.NewConsumer(ingest.Config{
+		QueueURL:  cfg.IngestQueueURL,
+		Region:    cfg.IngestRegion,
+		Endpoint:  cfg.IngestEndpoint,
+		AccessKey: cfg.IngestAccessKey,
+		SecretKey: cfg.IngestSecretKey,
+	})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	consumer.Run(handleIngestEvent)
+}
+
+// handleIngestEvent creates jobs for an object created event matching the
+// active ingest rules of its bucket. Events are recorded per preset of each
+// rule before its job is created, so redelivered events create each job
+// exactly once.
+func handleIngestEvent(e ingest.Event) error {
+	if !e.IsObjectCreated() || strings.HasSuffix(e.Key, "/") {
+		return nil
+	}
+
+	db := data.New()
+	rules, err := db.Ingest.GetActiveIngestRulesByBucket(e.Bucket)
+	if err != nil {
+		return err
+	}
+
+	matched := []types.IngestRule{}
+	for _, r := range *rules {
+		if !strings.HasPrefix(e.Key, r.Prefix) {
+			continue
+		}
+		if match, _ := path.Match(r.Glob, path.Base(e.Key)); !match {
+			continue
+		}
+
+		// Skip rules whose source storage changed to another bucket.
+		if bucket, err := ingestRuleBucket(r); err != nil || bucket != e.Bucket {
+			log.Warnf("ingest: rule %s doesn't read from %s", r.Name, e.Bucket)
+			continue
+		}
+		matched = append(matched, r)
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	log.Infof("ingest: received %s/%s", e.Bucket, e.Key)
+	for _, r := range matched {
+		for _, preset := range r.Presets {
+			isNew, err := db.Ingest.CreateIngestEvent(e.Bucket, e.Key, e.ID(), r.ID, preset)
+			if err != nil {
+				return err
+			}
+			if !isNew {
+				log.Infof("ingest: skipping duplicate event for %s/%s and %s", e.Bucket, e.Key, preset)
+				continue
+			}
+
+			job := types.Job{
+				GUID:          xid.New().String(),
+				OrgID:         r.OrgID,
+				Preset:        preset,
+				Source:        e.Key,
+				Destination:   r.Destination,
+				SourceProfile: r.SourceProfile,
+				DestProfile:   r.DestProfile,
+				SourceETag:    e.ETag,
+				Status:        types.JobQueued,
+			}
+
+			// Refuse events over the quotas of the organization, forgetting
+			// the preset so the redelivery is handled again.
+			exceeded, err := checkQuotas(r.OrgID, "", 1, []types.Job{job})
+			if exceeded != nil || err != nil {
+				db.Ingest.DeleteIngestEvent(e.Bucket, e.Key, e.ID(), r.ID, preset)
+				if err != nil {
+					return err
+				}
+				return exceeded
+			}
+
+			if j, err := createJob(job); err != nil {
+				// Fail the job that wasn't queued, and forget the preset so
+				// the redelivery is handled again.
+				if j != nil {
+					db.Jobs.UpdateJobStatusByGUID(j.GUID, types.JobError)
+				}
+				db.Ingest.DeleteIngestEvent(e.Bucket, e.Key, e.ID(), r.ID, preset)
+				return err
+			}
+		}
+	}
+	return nil
+}
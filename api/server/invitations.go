@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// Invitation settings.
+const (
+	InvitationTokenLength = 32 // Random bytes in a token.
+	InvitationExpiresDays = 7  // Default days until an invitation expires.
+)
+
+type invitationRequest struct {
+	Email       string `json:"email"`
+	Role        string `json:"role" binding:"required"`
+	ExpiresDays int    `json:"expires_days" binding:"min=0"`
+}
+
+// registrationMode gets who can register. Defaults to invite-only.
+func registrationMode() string {
+	db := data.New()
+	setting, err := db.Settings.GetSetting(types.DefaultOrgID, types.RegistrationMode)
+	if err != nil || setting.Value == "" {
+		return types.RegistrationInvite
+	}
+	return setting.Value
+}
+
+// registrationApproval checks if users registering without an invitation
+// must be approved. Defaults to required.
+func registrationApproval() bool {
+	db := data.New()
+	setting, err := db.Settings.GetSetting(types.DefaultOrgID, types.RegistrationApproval)
+	return err != nil || setting.Value != "disabled"
+}
+
+// hashInvitationToken returns the hex encoded SHA-256 hash of a token, as
+// stored.
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func getInvitationsHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var invitations *[]types.Invitation
+	var invitationsCount int
+
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		invitations = db.Invitations.GetInvitations((pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		invitationsCount = db.Invitations.GetInvitationsCount()
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":       invitationsCount,
+		"invitations": invitations,
+	})
+}
+
+// createInvitationHandler handles the request to invite a user with a role in
+// the active organization. The token is only returned once.
+func createInvitationHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+
+	// Decode json.
+	var json invitationRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := getRole(json.Role); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "role does not exist",
+		})
+		return
+	}
+
+	token, err := generateToken(InvitationTokenLength)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error creating invitation",
+		})
+		return
+	}
+
+	days := json.ExpiresDays
+	if days == 0 {
+		days = InvitationExpiresDays
+	}
+
+	db := data.New()
+	created, err := db.Invitations.CreateInvitation(types.Invitation{
+		TokenHash:   hashInvitationToken(token),
+		Email:       json.Email,
+		Role:        json.Role,
+		OrgID:       contextOrgID(c),
+		CreatedBy:   user.(*types.User).Username,
+		ExpiresDate: time.Now().AddDate(0, 0, days).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating invitation",
+		})
+		return
+	}
+	recordAudit(c, types.AuditInvitationCreate, "invitation", created.ID,
+		auditDiff(nil, map[string]interface{}{
+			"email":        created.Email,
+			"role":         created.Role,
+			"org_id":       created.OrgID,
+			"expires_date": created.ExpiresDate,
+		}, nil))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":     http.StatusCreated,
+		"invitation": created,
+		"token":      token,
+	})
+}
+
+func deleteInvitationByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if _, err := db.Invitations.GetInvitationByID(int64(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Invitation does not exist",
+		})
+		return
+	}
+
+	if err := db.Invitations.RevokeInvitationByID(int64(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error revoking invitation",
+		})
+		return
+	}
+	recordAudit(c, types.AuditInvitationRevoke, "invitation", id, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "Invitation revoked",
+	})
+}
@@ -2,12 +2,20 @@ package server
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/alfg/openencoder/api/config"
 	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/helpers"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/notify"
+	"github.com/alfg/openencoder/api/stream"
 	"github.com/alfg/openencoder/api/types"
 	"github.com/gin-gonic/gin"
 	"github.com/gocraft/work"
@@ -15,9 +23,15 @@ import (
 )
 
 type request struct {
-	Preset      string `json:"preset" binding:"required"`
-	Source      string `json:"source" binding:"required"`
-	Destination string `json:"dest" binding:"required"`
+	Preset        string  `json:"preset" binding:"required"`
+	Source        string  `json:"source" binding:"required_without=SourceFrom"`
+	Destination   string  `json:"dest" binding:"required"`
+	SourceProfile string  `json:"source_profile"`
+	DestProfile   string  `json:"dest_profile"`
+	CallbackURL   string  `json:"callback_url"`
+	Dedupe        string  `json:"dedupe" binding:"eq=refuse|eq=link|eq="`
+	DependsOn     []int64 `json:"depends_on"`
+	SourceFrom    int64   `json:"source_from"`
 }
 
 type updateRequest struct {
@@ -33,12 +47,6 @@ type response struct {
 func createJobHandler(c *gin.Context) {
 	user, _ := c.Get(JwtIdentityKey)
 
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	// Decode json.
 	var json request
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -46,53 +54,200 @@ func createJobHandler(c *gin.Context) {
 		return
 	}
 
+	// Validate the destination template.
+	if err := helpers.ValidateTemplate(json.Destination); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Validate storage profiles if provided.
+	if err := validateStorageProfiles(userOrgID(user), json.SourceProfile, json.DestProfile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Validate the callback URL if provided.
+	if err := validateCallbackURL(json.CallbackURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Validate the dependencies if provided.
+	dependsOn, err := jobDependencies(user, json.DependsOn, json.SourceFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	if json.SourceFrom != 0 && json.SourceProfile != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "source_profile can't be set with source_from",
+		})
+		return
+	}
+	if len(dependsOn) > 0 && json.Dedupe != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "dedupe cannot be used with dependencies",
+		})
+		return
+	}
+
+	// Return the original job for a repeated Idempotency-Key.
+	hash := requestHash(json)
+	var idemKey string
+	if k := c.GetHeader(IdempotencyKeyHeader); k != "" && config.Get().IdempotencyWindow > 0 {
+		if len(k) > IdempotencyKeyMaxLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Idempotency-Key is too long",
+			})
+			return
+		}
+
+		idemKey = idempotencyKey(userOrgID(user), user.(*types.User).Username, k)
+		record, err := reserveIdempotencyKey(idemKey, hash)
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error checking Idempotency-Key",
+			})
+			return
+		}
+		if record != nil {
+			replayIdempotentRequest(c, record, hash)
+			return
+		}
+	}
+
 	// Create Job and push the work to work queue.
 	job := types.Job{
-		GUID:        xid.New().String(),
-		Preset:      json.Preset,
-		Source:      json.Source,
-		Destination: json.Destination,
-		Status:      types.JobQueued, // Status queued.
+		GUID:          xid.New().String(),
+		OrgID:         userOrgID(user),
+		Preset:        json.Preset,
+		Source:        json.Source,
+		Destination:   json.Destination,
+		SourceProfile: json.SourceProfile,
+		DestProfile:   json.DestProfile,
+		CallbackURL:   json.CallbackURL,
+		CreatedBy:     user.(*types.User).Username,
+		DependsOn:     dependsOn,
+		SourceFrom:    json.SourceFrom,
+		Status:        types.JobQueued, // Status queued.
 	}
 
-	// Send to work queue.
-	_, err := enqueuer.Enqueue(config.Get().WorkerJobName, work.Q{
-		"guid":        job.GUID,
-		"preset":      job.Preset,
-		"source":      job.Source,
-		"destination": job.Destination,
-	})
+	// Wait for the dependencies to complete.
+	if len(dependsOn) > 0 {
+		job.Status = types.JobWaiting
+	}
+
+	// Refuse or link a duplicate of a completed job.
+	if json.Dedupe != "" {
+		dup, err := findDuplicateJob(&job)
+		if err != nil {
+			log.Error(err)
+			if idemKey != "" {
+				releaseIdempotencyKey(idemKey)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "error reading source: " + err.Error(),
+			})
+			return
+		}
+
+		if dup != nil && json.Dedupe == DedupeRefuse {
+			if idemKey != "" {
+				releaseIdempotencyKey(idemKey)
+			}
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  http.StatusConflict,
+				"message": fmt.Sprintf("duplicate of completed job %d", dup.ID),
+				"job":     dup,
+			})
+			return
+		}
+
+		if dup != nil {
+			if idemKey != "" {
+				completeIdempotencyKey(idemKey, hash, dup.ID)
+			}
+			c.JSON(http.StatusOK, response{
+				Message: "Duplicate of a completed job",
+				Status:  200,
+				Job:     dup,
+			})
+			return
+		}
+	}
+
+	// Refuse jobs over the quotas of the organization or user.
+	sources := []types.Job{}
+	if job.SourceFrom == 0 {
+		sources = append(sources, job)
+	}
+	exceeded, err := checkQuotas(job.OrgID, job.CreatedBy, 1, sources)
+	if err != nil || exceeded != nil {
+		if idemKey != "" {
+			releaseIdempotencyKey(idemKey)
+		}
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "error checking quotas: " + err.Error(),
+			})
+			return
+		}
+		respondQuotaExceeded(c, exceeded)
+		return
+	}
+
+	// Fail the job if it wasn't queued.
+	created, err := createJob(job)
 	if err != nil {
-		log.Info(err)
+		log.Error(err)
+		if created != nil && created.ID != 0 {
+			data.New().Jobs.UpdateJobStatusByGUID(created.GUID, types.JobError)
+			stream.Status(created.GUID, types.JobError)
+		}
+		if idemKey != "" {
+			releaseIdempotencyKey(idemKey)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error creating job",
+		})
+		return
 	}
 
-	db := data.New()
-	created := db.Jobs.CreateJob(job)
+	// Queue the job now if its dependencies already completed.
+	if created.Status == types.JobWaiting {
+		resolveWaitingJobs()
+		if j, err := data.New().Jobs.GetJobByID(created.ID); err == nil {
+			created = j
+		}
+	}
 
-	// Create the encode relationship.
-	ed := types.Encode{
-		JobID: created.ID,
-		Progress: types.NullFloat64{
-			NullFloat64: sql.NullFloat64{
-				Float64: 0,
-				Valid:   true,
-			},
-		},
-		Probe: types.NullString{
-			NullString: sql.NullString{
-				String: "{}",
-				Valid:  true,
-			},
-		},
-		Options: types.NullString{
-			NullString: sql.NullString{
-				String: "{}",
-				Valid:  true,
-			},
-		},
+	// Record the job for the Idempotency-Key.
+	if idemKey != "" {
+		if err := completeIdempotencyKey(idemKey, hash, created.ID); err != nil {
+			log.Error(err)
+		}
 	}
-	edCreated := db.Jobs.CreateEncode(ed)
-	created.EncodeID = edCreated.EncodeID
 
 	// Create response.
 	resp := response{
@@ -103,6 +258,24 @@ func createJobHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
+// postJobHandler routes POST /api/jobs/:id, as static routes such as
+// /jobs/bulk conflict with the :id parameter.
+func postJobHandler(c *gin.Context) {
+	switch c.Param("id") {
+	case "bulk":
+		bulkJobsHandler(c)
+	case "estimate":
+		if checkPermission(c, types.PermJobsCreate) {
+			estimateJobsHandler(c)
+		}
+	default:
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "not found",
+		})
+	}
+}
+
 func getJobsHandler(c *gin.Context) {
 	page := c.DefaultQuery("page", "1")
 	count := c.DefaultQuery("count", "10")
@@ -113,6 +286,24 @@ func getJobsHandler(c *gin.Context) {
 		pageInt = 1
 	}
 
+	filter, err := jobFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	filter.Offset = (pageInt - 1) * countInt
+	filter.Count = countInt
+
+	// Only list the user's own jobs if restricted.
+	user, _ := c.Get(JwtIdentityKey)
+	filter.OrgID = userOrgID(user)
+	if owner := jobOwner(user); owner != "" {
+		filter.CreatedBy = owner
+	}
+
 	var wg sync.WaitGroup
 	var jobs *[]types.Job
 	var jobsCount int
@@ -120,34 +311,95 @@ func getJobsHandler(c *gin.Context) {
 	db := data.New()
 	wg.Add(1)
 	go func() {
-		jobs = db.Jobs.GetJobs((pageInt-1)*countInt, countInt)
+		jobs = db.Jobs.GetJobs(filter)
 		wg.Done()
 	}()
 
 	wg.Add(1)
 	go func() {
-		jobsCount = db.Jobs.GetJobsCount()
+		jobsCount = db.Jobs.GetJobsCount(filter)
 		wg.Done()
 	}()
 	wg.Wait()
 
+	// Set the cursor to the next page if this page is full.
+	var nextCursor string
+	if n := len(*jobs); n > 0 && n == countInt {
+		nextCursor = data.NewJobCursor(filter.Sort, (*jobs)[n-1]).Encode()
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"count": jobsCount,
-		"items": jobs,
+		"count":       jobsCount,
+		"items":       jobs,
+		"next_cursor": nextCursor,
 	})
 }
 
+// jobFilterFromQuery sets a job filter from the query parameters.
+func jobFilterFromQuery(c *gin.Context) (data.JobFilter, error) {
+	filter := data.JobFilter{
+		Preset:      c.Query("preset"),
+		Source:      c.Query("source"),
+		Destination: c.Query("dest"),
+		CreatedBy:   c.Query("created_by"),
+		Sort:        c.DefaultQuery("sort", "id"),
+	}
+
+	if status := c.Query("status"); status != "" {
+		filter.Status = strings.Split(status, ",")
+	}
+
+	if _, ok := data.JobSortFields[filter.Sort]; !ok {
+		return filter, fmt.Errorf("invalid sort: %s", filter.Sort)
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		return filter, fmt.Errorf("invalid order: %s", c.Query("order"))
+	}
+
+	var err error
+	if filter.CreatedAfter, err = filterDate("created_after", c.Query("created_after")); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = filterDate("created_before", c.Query("created_before")); err != nil {
+		return filter, err
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		cur, err := data.DecodeJobCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = cur
+	}
+	return filter, nil
+}
+
+// filterDate normalizes an RFC 3339 timestamp or a YYYY-MM-DD date to UTC.
+func filterDate(param, date string) (string, error) {
+	if date == "" {
+		return "", nil
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", date); err != nil {
+			return "", fmt.Errorf("invalid %s: %s", param, date)
+		}
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
 func getJobsByIDHandler(c *gin.Context) {
 	id := c.Param("id")
 	jobInt, _ := strconv.Atoi(id)
+	user, _ := c.Get(JwtIdentityKey)
 
-	db := data.New()
-	job, err := db.Jobs.GetJobByID(int64(jobInt))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"status":  http.StatusNotFound,
-			"message": "Job does not exist",
-		})
+	job, ok := getVisibleJob(c, user, int64(jobInt))
+	if !ok {
 		return
 	}
 
@@ -161,12 +413,6 @@ func updateJobByIDHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	user, _ := c.Get(JwtIdentityKey)
 
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	// Decode json.
 	var json updateRequest
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -174,13 +420,8 @@ func updateJobByIDHandler(c *gin.Context) {
 		return
 	}
 
-	db := data.New()
-	job, err := db.Jobs.GetJobByID(int64(id))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"status":  http.StatusNotFound,
-			"message": "Job does not exist",
-		})
+	job, ok := getVisibleJob(c, user, int64(id))
+	if !ok {
 		return
 	}
 
@@ -188,20 +429,23 @@ func updateJobByIDHandler(c *gin.Context) {
 		job.Status = json.Status
 	}
 
+	db := data.New()
 	updatedJob := db.Jobs.UpdateJobByID(id, *job)
 	c.JSON(http.StatusOK, updatedJob)
 }
 
 func getJobStatusByIDHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	user, _ := c.Get(JwtIdentityKey)
 
-	// Update status.
-	db := data.New()
-	status, _ := db.Jobs.GetJobStatusByID(int64(id))
+	job, ok := getVisibleJob(c, user, int64(id))
+	if !ok {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":     http.StatusOK,
-		"job_status": status,
+		"job_status": job.Status,
 	})
 }
 
@@ -209,15 +453,19 @@ func cancelJobByIDHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	user, _ := c.Get(JwtIdentityKey)
 
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+	job, ok := getVisibleJob(c, user, int64(id))
+	if !ok {
 		return
 	}
 
 	// Update status.
 	db := data.New()
 	db.Jobs.UpdateJobStatusByID(id, types.JobCancelled)
+	stream.Status(job.GUID, types.JobCancelled)
+	sendEvent(types.EventJobCancelled, job.GUID)
+	recordAudit(c, types.AuditJobCancel, "job", job.ID, types.AuditChanges{
+		"status": {Before: job.Status, After: types.JobCancelled},
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": http.StatusOK,
@@ -228,30 +476,296 @@ func restartJobByIDHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	user, _ := c.Get(JwtIdentityKey)
 
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+	job, ok := getVisibleJob(c, user, int64(id))
+	if !ok {
+		return
+	}
+
+	if !data.BulkAllowed(data.BulkRestart, job.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "cannot restart a job with status " + job.Status,
+		})
+		return
+	}
+
+	// Refuse restarts over the quotas of the organization or job creator.
+	exceeded, err := checkRestartQuotas(job.OrgID, []types.Job{*job})
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error checking quotas: " + err.Error(),
+		})
+		return
+	}
+	if exceeded != nil {
+		respondQuotaExceeded(c, exceeded)
 		return
 	}
 
 	// Update status.
 	db := data.New()
-	db.Jobs.UpdateJobStatusByID(id, types.JobRestarting)
+	results, err := db.Jobs.BulkUpdateJobs(data.BulkRestart, []int64{job.ID}, userOrgID(user), jobOwner(user))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error restarting job",
+		})
+		return
+	}
+	if results[0].Result != data.BulkOK {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": results[0].Message,
+		})
+		return
+	}
+	stream.Status(job.GUID, types.JobRestarting)
 
-	job, _ := db.Jobs.GetJobByID(int64(id))
+	// Send back to work queue, or wait for the dependencies again.
+	waiting, err := restartJob(*job)
+	if err != nil {
+		log.Error(err)
+		db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+		stream.Status(job.GUID, types.JobError)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error sending job to the work queue",
+		})
+		return
+	}
+	status := types.JobRestarting
+	if waiting {
+		status = types.JobWaiting
+		resolveWaitingJobs()
+	} else {
+		sendEvent(types.EventJobQueued, job.GUID)
+	}
+	recordAudit(c, types.AuditJobRestart, "job", job.ID, types.AuditChanges{
+		"status": {Before: job.Status, After: status},
+	})
 
-	// Send back to work queue.
-	_, err := enqueuer.Enqueue(config.Get().WorkerJobName, work.Q{
-		"guid":        job.GUID,
-		"preset":      job.Preset,
-		"source":      job.Source,
-		"destination": job.Destination,
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
 	})
+}
+
+func deleteJobByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, _ := c.Get(JwtIdentityKey)
+
+	job, ok := getVisibleJob(c, user, int64(id))
+	if !ok {
+		return
+	}
+
+	if !data.BulkAllowed(data.BulkDelete, job.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "cannot delete a job with status " + job.Status,
+		})
+		return
+	}
+
+	// Delete the outputs first so a failure keeps the job to retry.
+	if c.Query("delete_outputs") == "true" {
+		if err := net.DeleteOutputs(*job); err != nil {
+			log.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error deleting outputs",
+			})
+			return
+		}
+	}
+
+	db := data.New()
+	results, err := db.Jobs.BulkUpdateJobs(data.BulkDelete, []int64{job.ID}, userOrgID(user), jobOwner(user))
 	if err != nil {
-		log.Info(err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error deleting job",
+		})
+		return
+	}
+	if results[0].Result != data.BulkOK {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": results[0].Message,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": http.StatusOK,
 	})
 }
+
+// jobOwner gets the username a user's jobs are restricted to by the
+// JOB_VISIBILITY setting, or an empty string if the user can see all jobs.
+func jobOwner(user interface{}) string {
+	if hasPermission(user, types.PermJobsReadAll) {
+		return ""
+	}
+
+	db := data.New()
+	visibility, err := db.Settings.GetSetting(userOrgID(user), types.JobVisibility)
+	if err != nil || visibility.Value != types.JobVisibilityOwn {
+		return ""
+	}
+	return user.(*types.User).Username
+}
+
+// getVisibleJob gets a job by ID if the user can see it in their organization.
+// Otherwise, a not found error is sent.
+func getVisibleJob(c *gin.Context, user interface{}, id int64) (*types.Job, bool) {
+	db := data.New()
+	job, err := db.Jobs.GetJobByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Job does not exist",
+		})
+		return nil, false
+	}
+
+	if job.OrgID != userOrgID(user) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Job does not exist",
+		})
+		return nil, false
+	}
+	if owner := jobOwner(user); owner != "" && job.CreatedBy != owner {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Job does not exist",
+		})
+		return nil, false
+	}
+	return job, true
+}
+
+// createJob creates a job with its encode data and sends it to the work queue.
+func createJob(job types.Job) (*types.Job, error) {
+	db := data.New()
+	created, err := db.Jobs.CreateJob(job)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the encode relationship.
+	ed := types.Encode{
+		JobID: created.ID,
+		Progress: types.NullFloat64{
+			NullFloat64: sql.NullFloat64{
+				Float64: 0,
+				Valid:   true,
+			},
+		},
+		Probe: types.NullString{
+			NullString: sql.NullString{
+				String: "{}",
+				Valid:  true,
+			},
+		},
+		Options: types.NullString{
+			NullString: sql.NullString{
+				String: "{}",
+				Valid:  true,
+			},
+		},
+	}
+	edCreated := db.Jobs.CreateEncode(ed)
+	created.EncodeID = edCreated.EncodeID
+
+	// Waiting jobs are queued once their dependencies complete.
+	if created.Status == types.JobWaiting {
+		return created, nil
+	}
+
+	// Send to work queue.
+	if err := enqueueJob(*created); err != nil {
+		return created, err
+	}
+	sendEvent(types.EventJobQueued, created.GUID)
+	return created, nil
+}
+
+// restartJob sends a restarted job back to the work queue. Jobs with
+// dependencies wait for them again instead, so they don't run before their
+// dependencies complete and chained sources are resolved again. Returns true
+// if the job is waiting to be queued by resolveWaitingJobs.
+func restartJob(job types.Job) (bool, error) {
+	if len(job.DependsOn) == 0 {
+		return false, enqueueJob(job)
+	}
+
+	db := data.New()
+	if err := db.Jobs.UpdateJobStatusByID(int(job.ID), types.JobWaiting); err != nil {
+		return false, err
+	}
+	stream.Status(job.GUID, types.JobWaiting)
+	return true, nil
+}
+
+// enqueueJob sends a job to the work queue.
+func enqueueJob(job types.Job) error {
+	_, err := enqueuer.Enqueue(config.Get().WorkerJobName, work.Q{
+		"guid":           job.GUID,
+		"org_id":         job.OrgID,
+		"preset":         job.Preset,
+		"source":         job.Source,
+		"destination":    job.Destination,
+		"source_profile": job.SourceProfile,
+		"dest_profile":   job.DestProfile,
+		"source_from":    job.SourceFrom,
+	})
+	return err
+}
+
+// validateStorageProfiles checks the named storage profiles exist in an
+// organization.
+func validateStorageProfiles(orgID int64, names ...string) error {
+	db := data.New()
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if _, err := db.Storage.GetStorageProfileByName(orgID, name); err != nil {
+			return fmt.Errorf("storage profile does not exist: %s", name)
+		}
+	}
+	return nil
+}
+
+// validateCallbackURL checks a callback URL if provided.
+func validateCallbackURL(callbackURL string) error {
+	if callbackURL != "" && !isHTTPURL(callbackURL) {
+		return fmt.Errorf("invalid callback_url: %s", callbackURL)
+	}
+	return nil
+}
+
+// isHTTPURL checks a URL is an absolute http or https URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// sendEvent sends a job event to the webhooks, job callback and
+// notification channels.
+func sendEvent(eventType, guid string) {
+	db := data.New()
+	job, err := db.Jobs.GetJobByGUID(guid)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := dispatcher.Send(eventType, *job); err != nil {
+		log.Error(err)
+	}
+	go notify.Dispatch(eventType, *job)
+}
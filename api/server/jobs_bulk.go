@@ -0,0 +1,216 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/stream"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// BulkMaxJobs is the max number of jobs a bulk action applies to.
+const BulkMaxJobs = 1000
+
+// bulkPermissions are the permissions required for each bulk action.
+var bulkPermissions = map[string]string{
+	data.BulkCancel:  types.PermJobsCancel,
+	data.BulkRestart: types.PermJobsRestart,
+	data.BulkDelete:  types.PermJobsDelete,
+}
+
+type bulkRequest struct {
+	Action string         `json:"action" binding:"required,eq=cancel|eq=restart|eq=delete"`
+	IDs    []int64        `json:"ids"`
+	Filter *bulkJobFilter `json:"filter"`
+}
+
+type bulkJobFilter struct {
+	Status        []string `json:"status"`
+	Preset        string   `json:"preset"`
+	Source        string   `json:"source"`
+	Destination   string   `json:"dest"`
+	CreatedBy     string   `json:"created_by"`
+	CreatedAfter  string   `json:"created_after"`
+	CreatedBefore string   `json:"created_before"`
+}
+
+func bulkJobsHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+
+	// Decode json.
+	var json bulkRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !checkPermission(c, bulkPermissions[json.Action]) {
+		return
+	}
+
+	orgID := userOrgID(user)
+	owner := jobOwner(user)
+	ids, err := bulkJobIDs(json, orgID, owner)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Refuse restarts over the quotas of the organization or job creators.
+	db := data.New()
+	if json.Action == data.BulkRestart {
+		exceeded, err := checkBulkRestartQuotas(ids, orgID, owner)
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "error checking quotas: " + err.Error(),
+			})
+			return
+		}
+		if exceeded != nil {
+			respondQuotaExceeded(c, exceeded)
+			return
+		}
+	}
+
+	results, err := db.Jobs.BulkUpdateJobs(json.Action, ids, orgID, owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error updating jobs",
+		})
+		return
+	}
+
+	// Send restarted jobs back to the work queue and notify of changes.
+	// Jobs with dependencies wait for them again.
+	succeeded, waiting := 0, false
+	for i, r := range results {
+		if r.Result != data.BulkOK {
+			continue
+		}
+
+		switch json.Action {
+		case data.BulkCancel:
+			stream.Status(r.GUID, types.JobCancelled)
+			sendEvent(types.EventJobCancelled, r.GUID)
+			recordAudit(c, types.AuditJobCancel, "job", r.ID, nil)
+		case data.BulkRestart:
+			job, err := db.Jobs.GetJobByID(r.ID)
+			jobWaiting := false
+			if err == nil {
+				jobWaiting, err = restartJob(*job)
+			}
+			if err != nil {
+				log.Error(err)
+				db.Jobs.UpdateJobStatusByGUID(r.GUID, types.JobError)
+				stream.Status(r.GUID, types.JobError)
+				results[i].Result = data.BulkError
+				results[i].Message = "error sending job to the work queue"
+				continue
+			}
+			if jobWaiting {
+				waiting = true
+			} else {
+				stream.Status(r.GUID, types.JobRestarting)
+				sendEvent(types.EventJobQueued, r.GUID)
+			}
+			recordAudit(c, types.AuditJobRestart, "job", r.ID, nil)
+		}
+		succeeded++
+	}
+	if waiting {
+		resolveWaitingJobs()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"action":    json.Action,
+		"count":     len(results),
+		"succeeded": succeeded,
+		"results":   results,
+	})
+}
+
+// checkBulkRestartQuotas checks restarting the jobs a bulk restart applies to
+// is within the quotas.
+func checkBulkRestartQuotas(ids []int64, orgID int64, owner string) (*quotaExceeded, error) {
+	db := data.New()
+	jobs, err := db.Jobs.GetJobsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	restart := []types.Job{}
+	for _, job := range *jobs {
+		if job.OrgID != orgID || (owner != "" && job.CreatedBy != owner) {
+			continue
+		}
+		if data.BulkAllowed(data.BulkRestart, job.Status) {
+			restart = append(restart, job)
+		}
+	}
+	return checkRestartQuotas(orgID, restart)
+}
+
+// bulkJobIDs gets the job IDs from the request IDs or filter. The filter only
+// matches jobs of the organization, created by the owner if set.
+func bulkJobIDs(json bulkRequest, orgID int64, owner string) ([]int64, error) {
+	if len(json.IDs) > 0 && json.Filter != nil {
+		return nil, errors.New("set either ids or filter")
+	}
+	if len(json.IDs) > BulkMaxJobs {
+		return nil, errors.New("too many ids")
+	}
+	if len(json.IDs) > 0 {
+		return json.IDs, nil
+	}
+	if json.Filter == nil {
+		return nil, errors.New("ids or filter is required")
+	}
+
+	f := json.Filter
+	filter := data.JobFilter{
+		Status:      f.Status,
+		Preset:      f.Preset,
+		Source:      f.Source,
+		Destination: f.Destination,
+		CreatedBy:   f.CreatedBy,
+		OrgID:       orgID,
+		Ascending:   true,
+		Count:       BulkMaxJobs,
+	}
+
+	var err error
+	if filter.CreatedAfter, err = filterDate("created_after", f.CreatedAfter); err != nil {
+		return nil, err
+	}
+	if filter.CreatedBefore, err = filterDate("created_before", f.CreatedBefore); err != nil {
+		return nil, err
+	}
+
+	// Refuse an empty filter matching every job.
+	if len(filter.Status) == 0 && filter.Preset == "" && filter.Source == "" &&
+		filter.Destination == "" && filter.CreatedBy == "" &&
+		filter.CreatedAfter == "" && filter.CreatedBefore == "" {
+		return nil, errors.New("filter requires at least one field")
+	}
+
+	if owner != "" {
+		filter.CreatedBy = owner
+	}
+
+	db := data.New()
+	jobs := db.Jobs.GetJobs(filter)
+	ids := []int64{}
+	for _, j := range *jobs {
+		ids = append(ids, j.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBulkJobIDs(t *testing.T) {
+	tooMany := make([]int64, BulkMaxJobs+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+
+	// Only requests refused before querying the jobs, or with IDs.
+	tests := []struct {
+		name    string
+		req     bulkRequest
+		want    []int64
+		wantErr string
+	}{
+		{"ids", bulkRequest{IDs: []int64{3, 1, 2}}, []int64{3, 1, 2}, ""},
+		{"max ids", bulkRequest{IDs: tooMany[:BulkMaxJobs]}, tooMany[:BulkMaxJobs], ""},
+		{"too many ids", bulkRequest{IDs: tooMany}, nil, "too many ids"},
+		{"ids and filter", bulkRequest{IDs: []int64{1}, Filter: &bulkJobFilter{Preset: "h264"}},
+			nil, "set either ids or filter"},
+		{"neither", bulkRequest{}, nil, "ids or filter is required"},
+		{"empty ids", bulkRequest{IDs: []int64{}}, nil, "ids or filter is required"},
+		{"empty filter", bulkRequest{Filter: &bulkJobFilter{}}, nil, "filter requires at least one field"},
+		{"empty status", bulkRequest{Filter: &bulkJobFilter{Status: []string{}}},
+			nil, "filter requires at least one field"},
+		{"invalid created_after", bulkRequest{Filter: &bulkJobFilter{CreatedAfter: "yesterday"}},
+			nil, "invalid created_after: yesterday"},
+		{"invalid created_before", bulkRequest{Filter: &bulkJobFilter{CreatedBefore: "2020-13-01"}},
+			nil, "invalid created_before: 2020-13-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := bulkJobIDs(tt.req, 1, "")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("bulkJobIDs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("bulkJobIDs() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/encoder"
+	"github.com/alfg/openencoder/api/machine"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// Estimate settings.
+const (
+	EstimateMaxSources  = 100
+	EstimateConcurrency = 5 // Sources probed at once.
+)
+
+type estimateRequest struct {
+	Preset        string   `json:"preset" binding:"required"`
+	Sources       []string `json:"sources" binding:"required,min=1"`
+	SourceProfile string   `json:"source_profile"`
+	Size          string   `json:"size"`
+	Workers       int      `json:"workers" binding:"min=0"`
+}
+
+// sourceEstimate describes the estimate of a source. Durations are in
+// seconds.
+type sourceEstimate struct {
+	Source     string  `json:"source"`
+	Duration   float64 `json:"duration"`
+	EncodeTime float64 `json:"encode_time"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// estimateResponse describes the estimate of a set of jobs. Durations are in
+// seconds. Prices are nil if machines are not configured.
+type estimateResponse struct {
+	Status      int              `json:"status"`
+	Preset      string           `json:"preset"`
+	Size        string           `json:"size"`
+	Workers     int              `json:"workers"`
+	Throughput  data.Throughput  `json:"throughput"`
+	Sources     []sourceEstimate `json:"sources"`
+	Duration    float64          `json:"duration"`
+	EncodeTime  float64          `json:"encode_time"`
+	WallTime    float64          `json:"wall_time"`
+	WorkerHours float64          `json:"worker_hours"`
+	PriceHourly *float64         `json:"price_hourly"`
+	Cost        *float64         `json:"cost"`
+	Messages    []string         `json:"messages,omitempty"`
+}
+
+func estimateJobsHandler(c *gin.Context) {
+	// Decode json.
+	var json estimateRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(json.Sources) > EstimateMaxSources {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("too many sources, max is %d", EstimateMaxSources),
+		})
+		return
+	}
+
+	orgID := contextOrgID(c)
+	db := data.New()
+	if _, err := db.Presets.GetPresetByName(orgID, json.Preset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "preset does not exist: " + json.Preset,
+		})
+		return
+	}
+	if err := validateStorageProfiles(orgID, json.SourceProfile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp := estimateResponse{
+		Status:  http.StatusOK,
+		Preset:  json.Preset,
+		Size:    json.Size,
+		Workers: json.Workers,
+	}
+
+	// Get the historical throughput of the preset, on the machine size if
+	// there is any history of it.
+	throughput, err := db.Jobs.GetPresetThroughput(orgID, json.Preset, json.Size)
+	if err == nil && throughput.Samples == 0 && json.Size != "" {
+		resp.Messages = append(resp.Messages, "no completed jobs on size "+json.Size+", using all sizes")
+		throughput, err = db.Jobs.GetPresetThroughput(orgID, json.Preset, "")
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error getting preset throughput",
+		})
+		return
+	}
+	resp.Throughput = *throughput
+	if throughput.Speed == 0 {
+		resp.Messages = append(resp.Messages, "no completed jobs of preset to estimate encode time from")
+	}
+
+	// Get the machine price and worker count.
+	price, running, err := machinePrice(orgID, json.Size)
+	if err != nil {
+		resp.Messages = append(resp.Messages, "machine pricing unavailable: "+err.Error())
+	} else {
+		resp.PriceHourly = &price
+	}
+	if resp.Workers == 0 {
+		resp.Workers = running
+	}
+	if resp.Workers == 0 {
+		resp.Workers = 1
+	}
+
+	// Probe the sources and estimate each encode time.
+	resp.Sources = probeSources(orgID, json.Sources, json.SourceProfile)
+	for i, s := range resp.Sources {
+		if throughput.Speed > 0 {
+			resp.Sources[i].EncodeTime = round2(s.Duration / throughput.Speed)
+		}
+		resp.Duration += s.Duration
+		resp.EncodeTime += resp.Sources[i].EncodeTime
+	}
+
+	resp.Duration = round2(resp.Duration)
+	resp.EncodeTime = round2(resp.EncodeTime)
+	resp.WallTime = round2(resp.EncodeTime / float64(resp.Workers))
+	resp.WorkerHours = round2(resp.EncodeTime / 3600)
+	if resp.PriceHourly != nil {
+		cost := round2(resp.WorkerHours * price)
+		resp.Cost = &cost
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// probeSources probes the duration of each source.
+func probeSources(orgID int64, sources []string, profile string) []sourceEstimate {
+	estimates := make([]sourceEstimate, len(sources))
+	sem := make(chan struct{}, EstimateConcurrency)
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, source string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			estimates[i].Source = source
+			input, err := net.GetProbeURL(types.Job{OrgID: orgID, Source: source, SourceProfile: profile})
+			if err != nil {
+				estimates[i].Error = err.Error()
+				return
+			}
+
+			f := encoder.FFProbe{}
+			probeData, err := f.Probe(input)
+			if err != nil || len(probeData.Streams) == 0 {
+				estimates[i].Error = "unable to probe source"
+				return
+			}
+			estimates[i].Duration = round2(probeData.Duration())
+		}(i, source)
+	}
+	wg.Wait()
+	return estimates
+}
+
+// machinePrice gets the hourly price of a machine size, or the average price
+// of the organization's running machines if no size is set. Also returns the
+// running machine count.
+func machinePrice(orgID int64, size string) (float64, int, error) {
+	db := data.New()
+	token, err := db.Settings.GetSetting(orgID, types.DigitalOceanAccessToken)
+	if err != nil || token.Value == "" {
+		return 0, 0, errors.New("machines not configured")
+	}
+
+	client, _ := machine.NewDigitalOceanClient(token.Value)
+	ctx := context.TODO()
+
+	pricing, err := client.GetCurrentPricing(ctx, workerTag(orgID))
+	if err != nil {
+		log.Error(err)
+		return 0, 0, errors.New("machines not configured")
+	}
+
+	if size == "" {
+		if pricing.Count == 0 {
+			return 0, 0, errors.New("no running machines, set a size")
+		}
+		return pricing.PriceHourly / float64(pricing.Count), pricing.Count, nil
+	}
+
+	sizes, err := client.ListSizes(ctx)
+	if err != nil {
+		log.Error(err)
+		return 0, pricing.Count, errors.New("error listing machine sizes")
+	}
+	for _, s := range sizes {
+		if s.Slug == size {
+			return s.PriceHourly, pricing.Count, nil
+		}
+	}
+	return 0, pricing.Count, errors.New("unknown size: " + size)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Job dedupe modes.
+const (
+	DedupeRefuse = "refuse"
+	DedupeLink   = "link"
+)
+
+// Idempotency settings.
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	IdempotencyKeyMaxLength   = 255
+)
+
+// idempotencyRecord describes a job request made with an Idempotency-Key.
+// JobID is 0 while the job is being created.
+type idempotencyRecord struct {
+	Hash  string `json:"hash"`
+	JobID int64  `json:"job_id"`
+}
+
+// idempotencyKey gets the redis key of a user's Idempotency-Key.
+func idempotencyKey(orgID int64, username, key string) string {
+	return fmt.Sprintf("%s:idempotency:%d:%s:%s", config.Get().WorkerNamespace, orgID, username, key)
+}
+
+// requestHash gets a hash of a job request, to detect a key reused for a
+// different request.
+func requestHash(r request) string {
+	b, _ := json.Marshal(r)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// reserveIdempotencyKey reserves a key for a request. If the key was already
+// used within the window, its record is returned instead.
+func reserveIdempotencyKey(key, hash string) (*idempotencyRecord, error) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	b, _ := json.Marshal(idempotencyRecord{Hash: hash})
+	reply, err := redis.String(conn.Do("SET", key, b, "EX", config.Get().IdempotencyWindow, "NX"))
+	if err == nil && reply == "OK" {
+		return nil, nil
+	}
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+
+	// Already used, so get the record.
+	existing, err := redis.Bytes(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		// Expired since, so retry the reservation.
+		return reserveIdempotencyKey(key, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := idempotencyRecord{}
+	if err := json.Unmarshal(existing, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// completeIdempotencyKey records the job created for a reserved key.
+func completeIdempotencyKey(key, hash string, jobID int64) error {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	b, _ := json.Marshal(idempotencyRecord{Hash: hash, JobID: jobID})
+	_, err := conn.Do("SET", key, b, "EX", config.Get().IdempotencyWindow)
+	return err
+}
+
+// releaseIdempotencyKey releases a reserved key when no job was created, so
+// the request can be retried.
+func releaseIdempotencyKey(key string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("DEL", key); err != nil {
+		log.Error(err)
+	}
+}
+
+// replayIdempotentRequest responds to a repeated Idempotency-Key with the
+// original job.
+func replayIdempotentRequest(c *gin.Context, record *idempotencyRecord, hash string) {
+	if record.Hash != hash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"status":  http.StatusUnprocessableEntity,
+			"message": "Idempotency-Key was used for a different request",
+		})
+		return
+	}
+
+	if record.JobID == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": "a request with this Idempotency-Key is in progress",
+		})
+		return
+	}
+
+	db := data.New()
+	job, err := db.Jobs.GetJobByID(record.JobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Job does not exist",
+		})
+		return
+	}
+
+	c.Header(IdempotencyReplayedHeader, "true")
+	c.JSON(http.StatusOK, response{
+		Message: "Job already created",
+		Status:  200,
+		Job:     job,
+	})
+}
+
+// findDuplicateJob sets the source ETag of a job and gets a completed job of
+// the same source version and preset, if any.
+func findDuplicateJob(job *types.Job) (*types.Job, error) {
+	etag, err := net.GetSourceETag(*job)
+	if err != nil {
+		return nil, err
+	}
+	job.SourceETag = etag
+
+	db := data.New()
+	dup, err := db.Jobs.GetCompletedJobBySource(job.OrgID, job.Source, job.SourceProfile, job.Preset, etag)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return dup, err
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"errors"
+	"net/http"
 	"time"
 
 	"github.com/alfg/openencoder/api/config"
@@ -13,13 +14,23 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// login is a login request with a username and password, or the second step
+// with the challenge returned and a TOTP or recovery code.
 type login struct {
-	Username string `form:"username" json:"username" binding:"required"`
-	Password string `form:"password" json:"password" binding:"required"`
+	Username     string `form:"username" json:"username"`
+	Password     string `form:"password" json:"password"`
+	Challenge    string `form:"challenge" json:"challenge"`
+	Code         string `form:"code" json:"code"`
+	RecoveryCode string `form:"recovery_code" json:"recovery_code"`
 }
 
 var jwtKey []byte
 
+var (
+	errLocalLoginDisabled = errors.New("local login is disabled")
+	errPendingApproval    = errors.New("account is pending approval")
+)
+
 func jwtMiddleware() *jwt.GinJWTMiddleware {
 
 	// Set the JWT Key if provided in config. Otherwise, generate a random one.
@@ -39,9 +50,23 @@ func jwtMiddleware() *jwt.GinJWTMiddleware {
 
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
 			if v, ok := data.(*types.User); ok {
+				version, err := tokenVersion(v.Username)
+				if err != nil {
+					log.Error(err)
+				}
+
+				// Log-in to the user's first organization.
+				if v.OrgID == 0 {
+					if err := setActiveOrg(v, 0); err != nil {
+						log.Error(err)
+					}
+				}
 				return jwt.MapClaims{
 					JwtIdentityKey: v.Username,
 					JwtRoleKey:     v.Role,
+					JwtVersionKey:  version,
+					JwtOrgKey:      v.OrgID,
+					JwtOrgRoleKey:  v.OrgRole,
 				}
 			}
 			return jwt.MapClaims{}
@@ -49,10 +74,21 @@ func jwtMiddleware() *jwt.GinJWTMiddleware {
 
 		IdentityHandler: func(c *gin.Context) interface{} {
 			claims := jwt.ExtractClaims(c)
-			return &types.User{
+			user := &types.User{
 				Username: claims["id"].(string),
 				Role:     claims["role"].(string),
+				OrgID:    types.DefaultOrgID,
 			}
+
+			// Tokens issued before organizations use the default one.
+			if org, ok := claims[JwtOrgKey].(float64); ok && org != 0 {
+				user.OrgID = int64(org)
+			}
+			user.OrgRole, _ = claims[JwtOrgRoleKey].(string)
+			if user.OrgRole == "" {
+				user.OrgRole = user.Role
+			}
+			return user
 		},
 
 		Authenticator: func(c *gin.Context) (interface{}, error) {
@@ -60,27 +96,72 @@ func jwtMiddleware() *jwt.GinJWTMiddleware {
 			if err := c.ShouldBind(&loginVals); err != nil {
 				return "", jwt.ErrMissingLoginValues
 			}
+
+			// Second step of a two-factor login, also used by OIDC logins.
+			if loginVals.Challenge != "" {
+				return authenticateLoginChallenge(c, loginVals)
+			}
+
+			if config.Get().DisableLocalLogin {
+				return nil, errLocalLoginDisabled
+			}
+
+			if loginVals.Username == "" || loginVals.Password == "" {
+				return "", jwt.ErrMissingLoginValues
+			}
 			userID := loginVals.Username
 			password := loginVals.Password
 
+			// Refuse logins locked out by failed logins of the username or IP.
+			if checkLoginLockout(c, userID) {
+				recordLogin(c, userID, LoginMethodPassword, false, "locked out")
+				return nil, errLoginLocked
+			}
+
 			db := data.New()
 			user, err := db.Users.GetUserByUsername(userID)
 			if err != nil {
+				countLoginFailure(c, userID)
+				recordLogin(c, userID, LoginMethodPassword, false, "unknown user")
+				return nil, jwt.ErrFailedAuthentication
+			}
+
+			// Service accounts authenticate with API keys only.
+			if user.ServiceAccount || (!user.Active && !user.Pending) {
+				countLoginFailure(c, userID)
+				recordLogin(c, userID, LoginMethodPassword, false, "inactive user")
 				return nil, jwt.ErrFailedAuthentication
 			}
 
 			// Check the encrypted password.
 			err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 			if err != nil {
+				countLoginFailure(c, userID)
+				recordLogin(c, userID, LoginMethodPassword, false, "invalid password")
 				return nil, jwt.ErrFailedAuthentication
 			}
+			rehashPassword(user, password)
+
+			// Tell registered users awaiting approval, once the password
+			// is verified.
+			if user.Pending {
+				recordLogin(c, userID, LoginMethodPassword, false, "pending approval")
+				return nil, errPendingApproval
+			}
 
 			// Error with 403 if password needs to be reset.
 			if user.ForcePasswordReset {
 				return nil, errors.New("require password reset")
 			}
 
+			// Challenge users with two-factor authentication for a code.
+			if totpRequired(user) {
+				return nil, startLoginChallenge(c, user)
+			}
+
 			// Log-in the user.
+			resetLoginFailures(user.Username)
+			recordLogin(c, user.Username, LoginMethodPassword, true, "")
 			return &types.User{
 				Username: user.Username,
 				Role:     user.Role,
@@ -88,27 +169,53 @@ func jwtMiddleware() *jwt.GinJWTMiddleware {
 		},
 
 		Authorizator: func(data interface{}, c *gin.Context) bool {
-			// Only authorize if user has the following roles.
-			if v, ok := data.(*types.User); ok &&
-				(v.Role == "guest" || v.Role == "operator" || v.Role == "admin") {
-				return true
+			// Deny tokens revoked by logout or user changes.
+			if isTokenRevoked(jwt.ExtractClaims(c)) {
+				c.Set(tokenRevokedKey, true)
+				return false
+			}
+
+			// Only authorize if the user's roles exist.
+			if v, ok := data.(*types.User); ok {
+				if _, ok = getRole(v.Role); !ok {
+					return false
+				}
+				_, ok = getRole(v.OrgRole)
+				return ok
 			}
 			return false
 		},
 
 		Unauthorized: func(c *gin.Context, code int, message string) {
-			c.JSON(code, gin.H{
+			if respondLoginLocked(c) {
+				return
+			}
+			if c.GetBool(tokenRevokedKey) {
+				code = http.StatusUnauthorized
+				message = errTokenRevoked.Error()
+			}
+			resp := gin.H{
 				"code":    code,
 				"message": message,
-			})
+			}
+			if challenge := c.GetString(loginChallengeKey); challenge != "" {
+				resp["challenge"] = challenge
+			}
+			c.JSON(code, resp)
 		},
 
 		LoginResponse: func(c *gin.Context, code int, message string, time time.Time) {
-			c.JSON(code, gin.H{
+			resp := gin.H{
 				"code":   code,
 				"token":  message,
 				"expire": time,
-			})
+			}
+
+			// Recovery codes of users who enrolled during login.
+			if codes, ok := c.Get(recoveryCodesKey); ok {
+				resp["recovery_codes"] = codes
+			}
+			c.JSON(code, resp)
 		},
 
 		TokenLookup:   "header: Authorization, query: token, cookie: jwt",
@@ -121,27 +228,3 @@ func jwtMiddleware() *jwt.GinJWTMiddleware {
 	}
 	return authMiddleware
 }
-
-func isAdminOrOperator(user interface{}) bool {
-	role := user.(*types.User).Role
-	if role != RoleOperator && role != RoleAdmin {
-		return false
-	}
-	return true
-}
-
-func isOperator(user interface{}) bool {
-	role := user.(*types.User).Role
-	if role != RoleOperator {
-		return false
-	}
-	return true
-}
-
-func isAdmin(user interface{}) bool {
-	role := user.(*types.User).Role
-	if role != RoleAdmin {
-		return false
-	}
-	return true
-}
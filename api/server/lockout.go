@@ -0,0 +1,145 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Login lockout settings. Failed logins are counted per username and per IP,
+// and lock out further logins once over the configured attempts, for a
+// duration doubled on each further failure.
+const (
+	LoginFailuresPrefix = "login:failures:"
+	LoginLockPrefix     = "login:lock:"
+
+	loginLockedKey = "login_locked" // Context key set by the Authenticator.
+)
+
+var errLoginLocked = errors.New("too many failed logins, try again later")
+
+// loginLockoutKey is a username or IP failed logins are counted for.
+type loginLockoutKey struct {
+	key      string
+	attempts int
+}
+
+// loginLockoutKeys gets the keys failed logins of a username from an IP are
+// counted for. An empty username only counts the IP.
+func loginLockoutKeys(username, ip string) []loginLockoutKey {
+	keys := []loginLockoutKey{}
+	if username != "" && config.Get().LoginLockoutAttempts > 0 {
+		keys = append(keys, loginLockoutKey{"user:" + username, config.Get().LoginLockoutAttempts})
+	}
+	if ip != "" && config.Get().LoginLockoutIPAttempts > 0 {
+		keys = append(keys, loginLockoutKey{"ip:" + ip, config.Get().LoginLockoutIPAttempts})
+	}
+	return keys
+}
+
+// checkLoginLockout checks if logins of a username from an IP are locked out.
+// The time until the lockout ends is set in the context for the response.
+func checkLoginLockout(c *gin.Context, username string) bool {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	var retry int
+	for _, k := range loginLockoutKeys(username, c.ClientIP()) {
+		ttl, err := redis.Int(conn.Do("TTL", LoginLockPrefix+k.key))
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if ttl > retry {
+			retry = ttl
+		}
+	}
+	if retry <= 0 {
+		return false
+	}
+	c.Set(loginLockedKey, retry)
+	return true
+}
+
+// countLoginFailure counts a failed login of a username from an IP, and
+// locks out further logins of those over their attempts.
+func countLoginFailure(c *gin.Context, username string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	for _, k := range loginLockoutKeys(username, c.ClientIP()) {
+		failures, err := redis.Int(conn.Do("INCR", LoginFailuresPrefix+k.key))
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		conn.Do("EXPIRE", LoginFailuresPrefix+k.key, loginLockoutMax())
+
+		if failures < k.attempts {
+			continue
+		}
+		lockout := loginLockoutDuration(failures - k.attempts)
+		if _, err := conn.Do("SET", LoginLockPrefix+k.key, failures, "EX", lockout); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// loginLockoutDuration gets the lockout in seconds after a number of failed
+// logins over the attempts, doubling the base up to the max.
+func loginLockoutDuration(over int) int {
+	max := loginLockoutMax()
+	lockout := config.Get().LoginLockoutBase
+	if lockout <= 0 {
+		lockout = 1
+	}
+	for i := 0; i < over && lockout < max; i++ {
+		lockout *= 2
+	}
+	if lockout > max {
+		lockout = max
+	}
+	return lockout
+}
+
+// loginLockoutMax gets the max lockout in seconds. Failed logins are also
+// counted for this long after the last one.
+func loginLockoutMax() int {
+	if max := config.Get().LoginLockoutMax; max > 0 {
+		return max
+	}
+	return int(time.Hour.Seconds())
+}
+
+// resetLoginFailures resets the failed logins of a username after a
+// successful login. Failures from the IP are kept, so one known account
+// can't be used to reset them.
+func resetLoginFailures(username string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	key := "user:" + username
+	if _, err := conn.Do("DEL", LoginFailuresPrefix+key, LoginLockPrefix+key); err != nil {
+		log.Error(err)
+	}
+}
+
+// respondLoginLocked responds with 429 if the context was locked out of
+// logging in. Returns true if responded.
+func respondLoginLocked(c *gin.Context) bool {
+	retry := c.GetInt(loginLockedKey)
+	if retry <= 0 {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(retry))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code":    http.StatusTooManyRequests,
+		"message": errLoginLocked.Error(),
+	})
+	return true
+}
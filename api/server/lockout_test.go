@@ -0,0 +1,76 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alfg/openencoder/api/config"
+)
+
+// setLockoutConfig sets the login lockout config for a test.
+func setLockoutConfig(t *testing.T, attempts, ipAttempts, base, max int) {
+	c := config.C
+	t.Cleanup(func() { config.C = c })
+
+	config.C.LoginLockoutAttempts = attempts
+	config.C.LoginLockoutIPAttempts = ipAttempts
+	config.C.LoginLockoutBase = base
+	config.C.LoginLockoutMax = max
+}
+
+func TestLoginLockoutDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		base int
+		max  int
+		over int
+		want int
+	}{
+		{"first", 30, 600, 0, 30},
+		{"doubled", 30, 600, 1, 60},
+		{"doubled twice", 30, 600, 2, 120},
+		{"capped", 30, 600, 5, 600},
+		{"far over", 30, 600, 1000, 600},
+		{"base over max", 900, 600, 0, 600},
+		{"no base", 0, 600, 3, 8},
+		{"default max", 60, 0, 100, 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLockoutConfig(t, 5, 20, tt.base, tt.max)
+			if got := loginLockoutDuration(tt.over); got != tt.want {
+				t.Errorf("loginLockoutDuration(%d) = %d, want %d", tt.over, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginLockoutKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int
+		ipAttempts int
+		username   string
+		ip         string
+		want       []loginLockoutKey
+	}{
+		{"both", 5, 20, "alice", "10.0.0.1",
+			[]loginLockoutKey{{"user:alice", 5}, {"ip:10.0.0.1", 20}}},
+		{"no username", 5, 20, "", "10.0.0.1",
+			[]loginLockoutKey{{"ip:10.0.0.1", 20}}},
+		{"user lockout disabled", 0, 20, "alice", "10.0.0.1",
+			[]loginLockoutKey{{"ip:10.0.0.1", 20}}},
+		{"disabled", 0, 0, "alice", "10.0.0.1",
+			[]loginLockoutKey{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLockoutConfig(t, tt.attempts, tt.ipAttempts, 30, 600)
+			if got := loginLockoutKeys(tt.username, tt.ip); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loginLockoutKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
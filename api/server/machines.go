@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -18,16 +19,8 @@ type machineRequest struct {
 }
 
 func machinesHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	d := data.New()
-	setting, err := d.Settings.GetSetting(types.DigitalOceanAccessToken)
+	setting, err := d.Settings.GetSetting(contextOrgID(c), types.DigitalOceanAccessToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
@@ -40,7 +33,7 @@ func machinesHandler(c *gin.Context) {
 	ctx := context.TODO()
 
 	// Get list of machines from DO client.
-	machines, err := client.ListDropletByTag(ctx, WorkerTag)
+	machines, err := client.ListDropletByTag(ctx, workerTag(contextOrgID(c)))
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -56,14 +49,6 @@ func machinesHandler(c *gin.Context) {
 }
 
 func createMachineHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	// Decode json.
 	var json machineRequest
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -72,7 +57,7 @@ func createMachineHandler(c *gin.Context) {
 	}
 
 	db := data.New()
-	settings := db.Settings.GetSettings()
+	settings := db.Settings.GetSettings(contextOrgID(c))
 
 	token := types.GetSetting(types.DigitalOceanAccessToken, settings)
 	region := types.GetSetting(types.DigitalOceanRegion, settings)
@@ -82,7 +67,7 @@ func createMachineHandler(c *gin.Context) {
 	ctx := context.TODO()
 
 	// Create machine.
-	machine, err := client.CreateDroplets(ctx, region, json.Size, vpc, json.Count)
+	machine, err := client.CreateDroplets(ctx, region, json.Size, vpc, workerTag(contextOrgID(c)), json.Count)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -92,6 +77,18 @@ func createMachineHandler(c *gin.Context) {
 		return
 	}
 
+	ids := []int{}
+	for _, m := range machine {
+		ids = append(ids, m.ID)
+	}
+	recordAudit(c, types.AuditMachineCreate, "machine", workerTag(contextOrgID(c)),
+		auditDiff(nil, map[string]interface{}{
+			"size":   json.Size,
+			"count":  json.Count,
+			"region": region,
+			"ids":    ids,
+		}, nil))
+
 	// TODO: Add resource to project?
 
 	c.JSON(200, gin.H{
@@ -101,18 +98,10 @@ func createMachineHandler(c *gin.Context) {
 }
 
 func deleteMachineHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	id, _ := strconv.Atoi(c.Param("id"))
 
 	d := data.New()
-	token, err := d.Settings.GetSetting(types.DigitalOceanAccessToken)
+	token, err := d.Settings.GetSetting(contextOrgID(c), types.DigitalOceanAccessToken)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -125,6 +114,15 @@ func deleteMachineHandler(c *gin.Context) {
 	client, _ := machine.NewDigitalOceanClient(token.Value)
 	ctx := context.TODO()
 
+	// Only delete the organization's machines.
+	if !isOrgMachine(ctx, client, contextOrgID(c), id) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Machine does not exist",
+		})
+		return
+	}
+
 	// Create machine.
 	machine, err := client.DeleteDropletByID(ctx, id)
 	if err != nil {
@@ -135,6 +133,7 @@ func deleteMachineHandler(c *gin.Context) {
 		})
 		return
 	}
+	recordAudit(c, types.AuditMachineDelete, "machine", id, nil)
 
 	c.JSON(200, gin.H{
 		"machine": machine,
@@ -142,17 +141,32 @@ func deleteMachineHandler(c *gin.Context) {
 	return
 }
 
-func deleteMachineByTagHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
+// workerTag gets the tag of an organization's worker machines.
+func workerTag(orgID int64) string {
+	if orgID == types.DefaultOrgID {
+		return WorkerTag
+	}
+	return fmt.Sprintf("%s-org-%d", WorkerTag, orgID)
+}
 
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
+// isOrgMachine checks if a machine is tagged as a worker of an organization.
+func isOrgMachine(ctx context.Context, client *machine.DigitalOcean, orgID int64, id int) bool {
+	machines, err := client.ListDropletByTag(ctx, workerTag(orgID))
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	for _, m := range machines {
+		if m.ID == id {
+			return true
+		}
 	}
+	return false
+}
 
+func deleteMachineByTagHandler(c *gin.Context) {
 	d := data.New()
-	token, err := d.Settings.GetSetting(types.DigitalOceanAccessToken)
+	token, err := d.Settings.GetSetting(contextOrgID(c), types.DigitalOceanAccessToken)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -166,7 +180,7 @@ func deleteMachineByTagHandler(c *gin.Context) {
 	ctx := context.TODO()
 
 	// Create machine.
-	err = client.DeleteDropletByTag(ctx, WorkerTag)
+	err = client.DeleteDropletByTag(ctx, workerTag(contextOrgID(c)))
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -175,6 +189,7 @@ func deleteMachineByTagHandler(c *gin.Context) {
 		})
 		return
 	}
+	recordAudit(c, types.AuditMachineDeleteAll, "machine", workerTag(contextOrgID(c)), nil)
 
 	c.JSON(200, gin.H{
 		"deleted": true,
@@ -183,16 +198,8 @@ func deleteMachineByTagHandler(c *gin.Context) {
 }
 
 func listMachineRegionsHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	db := data.New()
-	settings := db.Settings.GetSettings()
+	settings := db.Settings.GetSettings(contextOrgID(c))
 
 	token := types.GetSetting(types.DigitalOceanAccessToken, settings)
 	region := types.GetSetting(types.DigitalOceanRegion, settings)
@@ -224,16 +231,8 @@ func listMachineRegionsHandler(c *gin.Context) {
 }
 
 func listMachineSizesHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	db := data.New()
-	token, err := db.Settings.GetSetting(types.DigitalOceanAccessToken)
+	token, err := db.Settings.GetSetting(contextOrgID(c), types.DigitalOceanAccessToken)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -264,7 +263,7 @@ func listMachineSizesHandler(c *gin.Context) {
 
 func getCurrentMachinePricing(c *gin.Context) {
 	db := data.New()
-	token, err := db.Settings.GetSetting(types.DigitalOceanAccessToken)
+	token, err := db.Settings.GetSetting(contextOrgID(c), types.DigitalOceanAccessToken)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -278,7 +277,7 @@ func getCurrentMachinePricing(c *gin.Context) {
 	ctx := context.TODO()
 
 	// Get the current machine pricing from DO client.
-	pricing, err := client.GetCurrentPricing(ctx, WorkerTag)
+	pricing, err := client.GetCurrentPricing(ctx, workerTag(contextOrgID(c)))
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -294,16 +293,8 @@ func getCurrentMachinePricing(c *gin.Context) {
 }
 
 func listVPCsHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	db := data.New()
-	token, err := db.Settings.GetSetting(types.DigitalOceanAccessToken)
+	token, err := db.Settings.GetSetting(contextOrgID(c), types.DigitalOceanAccessToken)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/notify"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+type notificationChannelRequest struct {
+	Name     string                   `json:"name" binding:"required"`
+	Type     string                   `json:"type" binding:"required"`
+	Config   types.NotificationConfig `json:"config"`
+	Events   []string                 `json:"events" binding:"required,min=1"`
+	Template string                   `json:"template"`
+	Active   *bool                    `json:"active" binding:"required"`
+}
+
+func getNotificationChannelsHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var channels *[]types.NotificationChannel
+	var channelsCount int
+
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		channels = db.Notifications.GetNotificationChannels((pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		channelsCount = db.Notifications.GetNotificationChannelsCount()
+		wg.Done()
+	}()
+	wg.Wait()
+
+	for i := range *channels {
+		(*channels)[i].Config.SMTPPassword = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    channelsCount,
+		"channels": channels,
+	})
+}
+
+func getNotificationChannelByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	channel, err := db.Notifications.GetNotificationChannelByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Notification channel does not exist",
+		})
+		return
+	}
+	channel.Config.SMTPPassword = ""
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"channel": channel,
+	})
+}
+
+func createNotificationChannelHandler(c *gin.Context) {
+	// Decode json.
+	var json notificationChannelRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel := notificationChannelFromRequest(json)
+	if err := validateNotificationChannel(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	db := data.New()
+	created, err := db.Notifications.CreateNotificationChannel(channel)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating notification channel",
+		})
+		return
+	}
+	created.Config.SMTPPassword = ""
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"channel": created,
+	})
+}
+
+func updateNotificationChannelByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json notificationChannelRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	existing, err := db.Notifications.GetNotificationChannelByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Notification channel does not exist",
+		})
+		return
+	}
+
+	// Keep the existing SMTP password if not provided.
+	channel := notificationChannelFromRequest(json)
+	if channel.Config.SMTPPassword == "" {
+		channel.Config.SMTPPassword = existing.Config.SMTPPassword
+	}
+
+	if err := validateNotificationChannel(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	updated, err := db.Notifications.UpdateNotificationChannelByID(int64(id), channel)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating notification channel",
+		})
+		return
+	}
+	updated.Config.SMTPPassword = ""
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteNotificationChannelByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if err := db.Notifications.DeleteNotificationChannelByID(int64(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error deleting notification channel",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"deleted": true,
+	})
+}
+
+func testNotificationChannelHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	channel, err := db.Notifications.GetNotificationChannelByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Notification channel does not exist",
+		})
+		return
+	}
+
+	if err := notify.Test(*channel); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  http.StatusBadGateway,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"sent":   true,
+	})
+}
+
+func notificationChannelFromRequest(json notificationChannelRequest) types.NotificationChannel {
+	return types.NotificationChannel{
+		Name:     json.Name,
+		Type:     json.Type,
+		Config:   json.Config,
+		Events:   json.Events,
+		Template: json.Template,
+		Active:   *json.Active,
+	}
+}
+
+func validateNotificationChannel(channel types.NotificationChannel) error {
+	for _, e := range channel.Events {
+		if !isJobEvent(e) {
+			return fmt.Errorf("invalid event: %s", e)
+		}
+	}
+	return notify.Validate(channel)
+}
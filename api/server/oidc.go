@@ -0,0 +1,351 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/oidc"
+	"github.com/alfg/openencoder/api/types"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/oauth2"
+)
+
+// OIDC login settings.
+const (
+	OIDCStatePrefix = "oidc:state:"
+	OIDCStateTTL    = 600 // Seconds to complete a login.
+)
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+)
+
+// oidcLogin is the state of a login in progress, stored by its state value.
+type oidcLogin struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// oidcEnabled checks if an OIDC provider is configured.
+func oidcEnabled() bool {
+	return config.Get().OIDCIssuer != "" && config.Get().OIDCClientID != ""
+}
+
+// getOIDCProvider gets the configured provider, discovering it on first use.
+func getOIDCProvider() (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	p, err := oidc.NewProvider(config.Get().OIDCIssuer)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = p
+	return oidcProvider, nil
+}
+
+func oauth2Config(p *oidc.Provider) *oauth2.Config {
+	scopes := strings.Fields(config.Get().OIDCScopes)
+	if !containsString(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return &oauth2.Config{
+		ClientID:     config.Get().OIDCClientID,
+		ClientSecret: config.Get().OIDCClientSecret,
+		RedirectURL:  config.Get().OIDCRedirectURL,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+	}
+}
+
+// authConfigHandler handles the request to get the login methods available.
+func authConfigHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"local_login":     !config.Get().DisableLocalLogin,
+		"oidc":            oidcEnabled(),
+		"password_policy": getPasswordPolicy(),
+		"registration":    registrationMode(),
+	})
+}
+
+// oidcLoginHandler handles the request to start an OIDC login, redirecting
+// to the provider with an authorization code request using PKCE.
+func oidcLoginHandler(c *gin.Context) {
+	if !oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "OIDC login is not configured",
+		})
+		return
+	}
+
+	p, err := getOIDCProvider()
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  http.StatusBadGateway,
+			"message": "error contacting OIDC provider",
+		})
+		return
+	}
+
+	state, err1 := oidc.GenerateState()
+	nonce, err2 := oidc.GenerateState()
+	verifier, err3 := oidc.GenerateVerifier()
+	if err1 != nil || err2 != nil || err3 != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error starting login",
+		})
+		return
+	}
+
+	b, _ := json.Marshal(oidcLogin{Verifier: verifier, Nonce: nonce})
+	conn := redisPool.Get()
+	_, err = conn.Do("SET", OIDCStatePrefix+state, b, "EX", OIDCStateTTL)
+	conn.Close()
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error starting login",
+		})
+		return
+	}
+
+	authURL := oauth2Config(p).AuthCodeURL(state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.SetAuthURLParam("code_challenge", oidc.CodeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCallbackHandler handles the provider redirect after an OIDC login. The
+// code is exchanged for an ID token, the user is provisioned with the role
+// mapped from their groups, and a JWT is set as the web UI token cookie.
+// Users who must use two-factor authentication are redirected to complete a
+// login challenge instead.
+func oidcCallbackHandler(jwtMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := oidcAuthenticate(c)
+		if err != nil {
+			log.Warn("OIDC login failed: ", err)
+			recordLogin(c, "", LoginMethodOIDC, false, err.Error())
+			c.Redirect(http.StatusFound, "/login?error="+url.QueryEscape(err.Error()))
+			return
+		}
+
+		if totpRequired(user) {
+			err := startLoginChallenge(c, user)
+			if err != errTOTPRequired && err != errTOTPEnrolRequired {
+				log.Error(err)
+				c.Redirect(http.StatusFound, "/login?error="+url.QueryEscape("error starting login"))
+				return
+			}
+			query := url.Values{"challenge": {c.GetString(loginChallengeKey)}}
+			if err == errTOTPEnrolRequired {
+				query.Set("enrol", "true")
+			}
+			c.Redirect(http.StatusFound, "/login?"+query.Encode())
+			return
+		}
+
+		token, _, err := jwtMiddleware.TokenGenerator(&types.User{
+			Username: user.Username,
+			Role:     user.Role,
+		})
+		if err != nil {
+			log.Error(err)
+			c.Redirect(http.StatusFound, "/login?error="+url.QueryEscape("error creating token"))
+			return
+		}
+
+		recordLogin(c, user.Username, LoginMethodOIDC, true, "")
+
+		secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+		c.SetCookie("token", token, int(JwtTimeout.Seconds()), "/", "", secure, false)
+		c.Redirect(http.StatusFound, "/")
+	}
+}
+
+// oidcAuthenticate completes the login of an OIDC callback request.
+func oidcAuthenticate(c *gin.Context) (*types.User, error) {
+	if !oidcEnabled() {
+		return nil, errors.New("OIDC login is not configured")
+	}
+	if e := c.Query("error"); e != "" {
+		return nil, errors.New(e)
+	}
+
+	login, err := consumeOIDCState(c.Query("state"))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := getOIDCProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth2Config(p).Exchange(c.Request.Context(), c.Query("code"),
+		oauth2.SetAuthURLParam("code_verifier", login.Verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id token in token response")
+	}
+
+	claims, err := p.Verify(rawIDToken, config.Get().OIDCClientID, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	username := claims.String(config.Get().OIDCUsernameClaim)
+	if username == "" {
+		return nil, errors.New("missing username claim")
+	}
+
+	role := oidcRole(claims.Strings(config.Get().OIDCGroupsClaim))
+	if role == "" {
+		return nil, errors.New("user is not in an allowed group")
+	}
+	return provisionOIDCUser(claims.String("iss"), claims.String("sub"), username, role)
+}
+
+// consumeOIDCState gets and deletes the login state so it is only used once.
+func consumeOIDCState(state string) (*oidcLogin, error) {
+	if state == "" {
+		return nil, errors.New("missing state")
+	}
+
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("GET", OIDCStatePrefix+state))
+	if err != nil {
+		return nil, errors.New("invalid or expired state")
+	}
+	if n, err := redis.Int(conn.Do("DEL", OIDCStatePrefix+state)); err != nil || n == 0 {
+		return nil, errors.New("invalid or expired state")
+	}
+
+	login := &oidcLogin{}
+	if err := json.Unmarshal(b, login); err != nil {
+		return nil, err
+	}
+	return login, nil
+}
+
+// oidcRole maps the user's groups to a role. Admin groups take precedence
+// over operator groups. Users in neither get the default role.
+func oidcRole(groups []string) string {
+	if containsAnyString(groups, splitList(config.Get().OIDCAdminGroups)) {
+		return RoleAdmin
+	}
+	if containsAnyString(groups, splitList(config.Get().OIDCOperatorGroups)) {
+		return RoleOperator
+	}
+
+	role := config.Get().OIDCDefaultRole
+	if _, ok := getRole(role); !ok {
+		return ""
+	}
+	return role
+}
+
+// provisionOIDCUser gets the user linked to the provider account by its
+// issuer and subject, creating them on first login, and syncs their role with
+// the one mapped from the provider. Users are never linked to an existing
+// account by username, so a provider account can't take over a local one.
+func provisionOIDCUser(issuer, subject, username, role string) (*types.User, error) {
+	db := data.New()
+	u, err := db.Users.GetUserByOIDCSubject(issuer, subject)
+	if err != nil {
+		if _, err := db.Users.GetUserByUsername(username); err == nil {
+			return nil, errors.New("username is taken by another account")
+		}
+
+		hash, err := randomPasswordHash()
+		if err != nil {
+			return nil, err
+		}
+
+		u, err = db.Users.CreateUser(types.User{
+			Username:    username,
+			Password:    hash,
+			Role:        role,
+			OIDCIssuer:  issuer,
+			OIDCSubject: subject,
+		})
+		if err != nil {
+			return nil, errors.New("error creating user")
+		}
+		return u, nil
+	}
+
+	if u.ServiceAccount {
+		return nil, errors.New("service accounts can't log in")
+	}
+	if !u.Active {
+		return nil, errors.New("user is inactive")
+	}
+
+	if u.Role != role {
+		u.Role = role
+		if _, err := db.Users.UpdateUserByID(int(u.ID), u); err != nil {
+			return nil, errors.New("error updating user")
+		}
+		revokeTokens(u.Username)
+	}
+	return u, nil
+}
+
+// splitList splits a comma separated config value.
+func splitList(s string) []string {
+	values := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func containsAnyString(values, find []string) bool {
+	for _, f := range find {
+		if containsString(values, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,458 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+var errNotMember = errors.New("not a member of the organization")
+
+type organizationRequest struct {
+	Name string `json:"name" binding:"required,max=128"`
+}
+
+type switchOrganizationRequest struct {
+	OrgID int64 `json:"org_id" binding:"required"`
+}
+
+type memberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role"`
+}
+
+type memberUpdateRequest struct {
+	Role string `json:"role"`
+}
+
+// contextOrgID gets the active organization of the request's user.
+func contextOrgID(c *gin.Context) int64 {
+	user, _ := c.Get(JwtIdentityKey)
+	return userOrgID(user)
+}
+
+// userOrgID gets the active organization of a user.
+func userOrgID(user interface{}) int64 {
+	if u, ok := user.(*types.User); ok {
+		return u.OrgID
+	}
+	return 0
+}
+
+// setActiveOrg sets the user's role, active organization and role in it. If
+// orgID is 0, the user's first organization is used. Users who manage
+// organizations can switch to any organization, with their role.
+func setActiveOrg(u *types.User, orgID int64) error {
+	db := data.New()
+	user, err := db.Users.GetUserByUsername(u.Username)
+	if err != nil {
+		return err
+	}
+	u.Role = user.Role
+
+	memberships := db.Organizations.GetUserMemberships(user.ID)
+	for _, m := range *memberships {
+		if orgID == 0 || m.OrgID == orgID {
+			u.OrgID = m.OrgID
+			u.OrgRole = m.EffectiveRole()
+			return nil
+		}
+	}
+
+	if role, ok := getRole(user.Role); ok && role.Can(types.PermOrgsManage) {
+		if orgID == 0 {
+			orgID = types.DefaultOrgID
+		}
+		if _, err := db.Organizations.GetOrganizationByID(orgID); err == nil {
+			u.OrgID = orgID
+			u.OrgRole = user.Role
+			return nil
+		}
+	}
+	return errNotMember
+}
+
+// getUserOrganizationsHandler handles the request to get the organizations the
+// current user can switch to.
+func getUserOrganizationsHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	u := user.(*types.User)
+
+	db := data.New()
+	account, err := db.Users.GetUserByUsername(u.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "User does not exist",
+		})
+		return
+	}
+	memberships := *db.Organizations.GetUserMemberships(account.ID)
+
+	// Include the organizations the user isn't a member of, if they manage
+	// organizations.
+	if hasPermission(u, types.PermOrgsManage) {
+		member := map[int64]bool{}
+		for _, m := range memberships {
+			member[m.OrgID] = true
+		}
+		orgs := db.Organizations.GetOrganizations(0, db.Organizations.GetOrganizationsCount())
+		for _, o := range *orgs {
+			if !member[o.ID] {
+				memberships = append(memberships, types.OrganizationMember{
+					OrgID:    o.ID,
+					OrgName:  o.Name,
+					UserID:   account.ID,
+					Username: account.Username,
+					UserRole: account.Role,
+				})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id":        u.OrgID,
+		"organizations": memberships,
+	})
+}
+
+// switchOrganizationHandler handles the request to switch the active
+// organization of the current user, returning a new token for it.
+func switchOrganizationHandler(jwtMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var json switchOrganizationRequest
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, _ := c.Get(JwtIdentityKey)
+		u := &types.User{Username: user.(*types.User).Username}
+		if err := setActiveOrg(u, json.OrgID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "Organization does not exist",
+			})
+			return
+		}
+
+		token, expire, err := jwtMiddleware.TokenGenerator(u)
+		if err != nil {
+			log.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error creating token",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":   http.StatusOK,
+			"token":  token,
+			"expire": expire,
+		})
+	}
+}
+
+func getOrganizationsHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var orgs *[]types.Organization
+	var orgsCount int
+
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		orgs = db.Organizations.GetOrganizations((pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		orgsCount = db.Organizations.GetOrganizationsCount()
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":         orgsCount,
+		"organizations": orgs,
+	})
+}
+
+func getOrganizationByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	org, err := db.Organizations.GetOrganizationByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Organization does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       http.StatusOK,
+		"organization": org,
+	})
+}
+
+func createOrganizationHandler(c *gin.Context) {
+	// Decode json.
+	var json organizationRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	created, err := db.Organizations.CreateOrganization(types.Organization{
+		Name: json.Name,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating organization",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":       http.StatusCreated,
+		"organization": created,
+	})
+}
+
+func updateOrganizationByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json organizationRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	org, err := db.Organizations.GetOrganizationByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Organization does not exist",
+		})
+		return
+	}
+	org.Name = json.Name
+
+	updated, err := db.Organizations.UpdateOrganizationByID(int64(id), *org)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating organization",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       http.StatusOK,
+		"organization": updated,
+	})
+}
+
+func deleteOrganizationByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	org, err := db.Organizations.GetOrganizationByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Organization does not exist",
+		})
+		return
+	}
+
+	if org.ID == types.DefaultOrgID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "the default organization cannot be deleted",
+		})
+		return
+	}
+	if db.Organizations.GetOrganizationJobsCount(org.ID) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": "organization has jobs",
+		})
+		return
+	}
+
+	// Revoke the tokens of members, which may be active in the organization.
+	members := db.Organizations.GetMembers(org.ID)
+	if err := db.Organizations.DeleteOrganizationByID(org.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error deleting organization",
+		})
+		return
+	}
+	for _, m := range *members {
+		revokeTokens(m.Username)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "organization deleted",
+	})
+}
+
+// getMembersHandler handles the request to get the members of the active
+// organization.
+func getMembersHandler(c *gin.Context) {
+	db := data.New()
+	members := db.Organizations.GetMembers(contextOrgID(c))
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+	})
+}
+
+// addMemberHandler handles the request to add a user to the active
+// organization, or update their role in it.
+func addMemberHandler(c *gin.Context) {
+	// Decode json.
+	var json memberRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	user, err := db.Users.GetUserByUsername(json.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "User does not exist",
+		})
+		return
+	}
+	setMember(c, user, json.Role)
+}
+
+// updateMemberHandler handles the request to update the role of a member of
+// the active organization.
+func updateMemberHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json memberUpdateRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	if _, err := db.Organizations.GetMember(contextOrgID(c), int64(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Member does not exist",
+		})
+		return
+	}
+
+	user, err := db.Users.GetUserByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Member does not exist",
+		})
+		return
+	}
+	setMember(c, user, json.Role)
+}
+
+// setMember sets a user's membership of the active organization with a role,
+// and revokes their tokens so the role applies.
+func setMember(c *gin.Context, user *types.User, role string) {
+	if role != "" {
+		if _, ok := getRole(role); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "role does not exist",
+			})
+			return
+		}
+	}
+
+	orgID := contextOrgID(c)
+	db := data.New()
+	if err := db.Organizations.SetMember(orgID, user.ID, role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating member",
+		})
+		return
+	}
+	revokeTokens(user.Username)
+
+	member, err := db.Organizations.GetMember(orgID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error updating member",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"member": member,
+	})
+}
+
+// deleteMemberHandler handles the request to remove a user from the active
+// organization.
+func deleteMemberHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	orgID := contextOrgID(c)
+
+	db := data.New()
+	member, err := db.Organizations.GetMember(orgID, int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Member does not exist",
+		})
+		return
+	}
+
+	if err := db.Organizations.DeleteMember(orgID, member.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error removing member",
+		})
+		return
+	}
+	revokeTokens(member.Username)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "member removed",
+	})
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// breachedPasswords are the SHA-1 hashes of the passwords in the breached
+// password list, in upper case hex.
+var breachedPasswords = map[string]bool{}
+
+// passwordPolicy describes the password policy for clients.
+type passwordPolicy struct {
+	MinLength  int  `json:"min_length"`
+	MinClasses int  `json:"min_classes"`
+	Breached   bool `json:"breached"`
+}
+
+// loadBreachedPasswords loads the breached password list. Lines are either
+// passwords, or SHA-1 hashes in hex optionally followed by a count, such as
+// the Pwned Passwords downloads.
+func loadBreachedPasswords() {
+	path := config.Get().PasswordBreachedList
+	if path == "" {
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Error("error loading breached passwords: ", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash := strings.SplitN(line, ":", 2)[0]; isSHA1Hex(hash) {
+			breachedPasswords[strings.ToUpper(hash)] = true
+			continue
+		}
+		breachedPasswords[sha1Hex(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error("error loading breached passwords: ", err)
+	}
+	log.Infof("loaded %d breached passwords", len(breachedPasswords))
+}
+
+// validatePassword checks a password against the password policy.
+func validatePassword(username, password string) error {
+	cfg := config.Get()
+	if len([]rune(password)) < cfg.PasswordMinLength {
+		return fmt.Errorf("password must be at least %d characters", cfg.PasswordMinLength)
+	}
+	if passwordClasses(password) < cfg.PasswordMinClasses {
+		return fmt.Errorf("password must contain %d of: lowercase letters, uppercase letters, digits and symbols", cfg.PasswordMinClasses)
+	}
+	if strings.EqualFold(password, username) {
+		return fmt.Errorf("password must not be the username")
+	}
+	if breachedPasswords[sha1Hex(password)] {
+		return fmt.Errorf("password is in a list of breached passwords")
+	}
+	return nil
+}
+
+// getPasswordPolicy gets the password policy for clients.
+func getPasswordPolicy() passwordPolicy {
+	return passwordPolicy{
+		MinLength:  config.Get().PasswordMinLength,
+		MinClasses: config.Get().PasswordMinClasses,
+		Breached:   len(breachedPasswords) > 0,
+	}
+}
+
+// passwordClasses counts the character classes in a password.
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// hashPassword hashes a password with the configured bcrypt cost.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// rehashPassword updates the hash of a user's verified password if it was
+// hashed with a lower cost than configured.
+func rehashPassword(user *types.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil || cost >= bcryptCost() {
+		return
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	db := data.New()
+	if err := db.Users.UpdateUserPasswordHashByID(user.ID, hash); err != nil {
+		log.Error(err)
+	}
+}
+
+// bcryptCost gets the configured bcrypt cost within the bcrypt limits.
+func bcryptCost() int {
+	cost := config.Get().BcryptCost
+	if cost < bcrypt.MinCost {
+		return bcrypt.DefaultCost
+	}
+	if cost > bcrypt.MaxCost {
+		return bcrypt.MaxCost
+	}
+	return cost
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/alfg/openencoder/api/config"
+)
+
+func TestPasswordClasses(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcDEF", 2},
+		{"abcDEF123", 3},
+		{"abcDEF123!", 4},
+		{"ÄÖÜäöü", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := passwordClasses(tt.password); got != tt.want {
+				t.Errorf("passwordClasses() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	c := config.C
+	t.Cleanup(func() { config.C = c })
+	config.C.PasswordMinLength = 8
+	config.C.PasswordMinClasses = 3
+
+	breached := breachedPasswords
+	t.Cleanup(func() { breachedPasswords = breached })
+	breachedPasswords = map[string]bool{sha1Hex("Password1"): true}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		valid    bool
+	}{
+		{"valid", "alice", "correct-Horse1", true},
+		{"short", "alice", "aB1!", false},
+		{"short runes", "alice", "äöüÄÖÜ1", false},
+		{"few classes", "alice", "correcthorse", false},
+		{"username", "Alice.Smith1", "alice.smith1", false},
+		{"breached", "alice", "Password1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePassword(tt.username, tt.password); (err == nil) != tt.valid {
+				t.Errorf("validatePassword() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
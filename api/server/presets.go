@@ -1,11 +1,14 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/helpers"
 	"github.com/alfg/openencoder/api/types"
 	"github.com/gin-gonic/gin"
 )
@@ -27,14 +30,6 @@ type presetUpdateRequest struct {
 }
 
 func createPresetHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	// Decode json.
 	var json createPresetRequest
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -42,6 +37,12 @@ func createPresetHandler(c *gin.Context) {
 		return
 	}
 
+	// Validate the output name template.
+	if err := validateOutputTemplate(json.Output); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create Preset.
 	preset := types.Preset{
 		Name:        json.Name,
@@ -49,12 +50,16 @@ func createPresetHandler(c *gin.Context) {
 		Output:      json.Output,
 		Data:        json.Data,
 		Active:      json.Active,
+		OrgID:       contextOrgID(c),
 	}
 
 	db := data.New()
 	created, err := db.Presets.CreatePreset(preset)
 	if err != nil {
 		log.Error(err)
+	} else {
+		recordAudit(c, types.AuditPresetCreate, "preset", created.ID,
+			auditDiff(nil, presetAuditValues(created), nil))
 	}
 
 	// Create response.
@@ -78,16 +83,17 @@ func getPresetsHandler(c *gin.Context) {
 	var presets *[]types.Preset
 	var presetsCount int
 
+	orgID := contextOrgID(c)
 	db := data.New()
 	wg.Add(1)
 	go func() {
-		presets = db.Presets.GetPresets((pageInt-1)*countInt, countInt)
+		presets = db.Presets.GetPresets(orgID, (pageInt-1)*countInt, countInt)
 		wg.Done()
 	}()
 
 	wg.Add(1)
 	go func() {
-		presetsCount = db.Presets.GetPresetsCount()
+		presetsCount = db.Presets.GetPresetsCount(orgID)
 		wg.Done()
 	}()
 	wg.Wait()
@@ -103,7 +109,7 @@ func getPresetByIDHandler(c *gin.Context) {
 	presetInt, _ := strconv.Atoi(id)
 
 	db := data.New()
-	preset, err := db.Presets.GetPresetByID(presetInt)
+	preset, err := db.Presets.GetPresetByID(contextOrgID(c), presetInt)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"status":  http.StatusNotFound,
@@ -120,13 +126,6 @@ func getPresetByIDHandler(c *gin.Context) {
 
 func updatePresetByIDHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdminOrOperator(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
 
 	// Decode json.
 	var json presetUpdateRequest
@@ -135,8 +134,14 @@ func updatePresetByIDHandler(c *gin.Context) {
 		return
 	}
 
+	// Validate the output name template.
+	if err := validateOutputTemplate(json.Output); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	db := data.New()
-	preset, err := db.Presets.GetPresetByID(id)
+	preset, err := db.Presets.GetPresetByID(contextOrgID(c), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"status":  http.StatusNotFound,
@@ -145,6 +150,8 @@ func updatePresetByIDHandler(c *gin.Context) {
 		return
 	}
 
+	before := presetAuditValues(preset)
+
 	// Update struct with new data if provided.
 	if json.Name != "" {
 		preset.Name = json.Name
@@ -165,5 +172,16 @@ func updatePresetByIDHandler(c *gin.Context) {
 	preset.Active = json.Active
 
 	updatedPreset := db.Presets.UpdatePresetByID(id, *preset)
+	recordAudit(c, types.AuditPresetUpdate, "preset", id,
+		auditDiff(before, presetAuditValues(preset), nil))
 	c.JSON(http.StatusOK, updatedPreset)
 }
+
+// validateOutputTemplate validates a preset output name template. Output
+// names are file names, so path separators are not allowed.
+func validateOutputTemplate(output string) error {
+	if strings.Contains(output, "/") {
+		return errors.New("invalid output: must be a file name")
+	}
+	return helpers.ValidateTemplate(output)
+}
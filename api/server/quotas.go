@@ -0,0 +1,360 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// Quota names.
+const (
+	QuotaMaxConcurrentJobs      = "max_concurrent_jobs"
+	QuotaJobsPerDay             = "jobs_per_day"
+	QuotaEncodedMinutesPerMonth = "encoded_minutes_per_month"
+	QuotaMaxSourceSize          = "max_source_size"
+)
+
+// Quota scopes.
+const (
+	QuotaScopeOrg  = "org"
+	QuotaScopeUser = "user"
+)
+
+type quotaRequest struct {
+	MaxConcurrentJobs      int   `json:"max_concurrent_jobs" binding:"min=0"`
+	JobsPerDay             int   `json:"jobs_per_day" binding:"min=0"`
+	EncodedMinutesPerMonth int   `json:"encoded_minutes_per_month" binding:"min=0"`
+	MaxSourceSize          int64 `json:"max_source_size" binding:"min=0"`
+}
+
+// quotaExceeded describes a quota a request exceeds.
+type quotaExceeded struct {
+	Scope      string
+	Quota      string
+	Limit      float64
+	Usage      float64
+	RetryAfter time.Duration // 0 if the usage isn't reset over time.
+}
+
+// Error describes the exceeded quota, for jobs created without a request.
+func (e *quotaExceeded) Error() string {
+	scope := "organization"
+	if e.Scope == QuotaScopeUser {
+		scope = "user"
+	}
+	return fmt.Sprintf("%s quota exceeded: %s is %g, used %g", scope, e.Quota, e.Limit, e.Usage)
+}
+
+// usageResponse describes the quota and usage of an organization or user.
+type usageResponse struct {
+	Quota *types.Quota `json:"quota"`
+	Usage *types.Usage `json:"usage"`
+}
+
+// checkQuotas checks creating a number of jobs in an organization by a user
+// is within the quotas of the organization and the user. The size of the
+// sources is checked if a quota limits it.
+func checkQuotas(orgID int64, username string, count int, sources []types.Job) (*quotaExceeded, error) {
+	db := data.New()
+	var maxSourceSize int64
+	var maxSourceScope string
+
+	scopes := []struct {
+		name     string
+		username string
+	}{
+		{QuotaScopeOrg, ""},
+		{QuotaScopeUser, username},
+	}
+	for _, s := range scopes {
+		quota, err := db.Quotas.GetQuota(orgID, s.username)
+		if err != nil {
+			return nil, err
+		}
+		if quota.MaxSourceSize > 0 && (maxSourceSize == 0 || quota.MaxSourceSize < maxSourceSize) {
+			maxSourceSize = quota.MaxSourceSize
+			maxSourceScope = s.name
+		}
+		if quota.MaxConcurrentJobs == 0 && quota.JobsPerDay == 0 && quota.EncodedMinutesPerMonth == 0 {
+			continue
+		}
+
+		usage, err := db.Quotas.GetUsage(orgID, s.username)
+		if err != nil {
+			return nil, err
+		}
+		if e := exceededQuota(quota, usage, count); e != nil {
+			e.Scope = s.name
+			return e, nil
+		}
+	}
+
+	if maxSourceSize == 0 {
+		return nil, nil
+	}
+	for _, job := range sources {
+		size, err := net.GetSourceSize(job)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxSourceSize {
+			return &quotaExceeded{
+				Scope: maxSourceScope,
+				Quota: QuotaMaxSourceSize,
+				Limit: float64(maxSourceSize),
+				Usage: float64(size),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkRestartQuotas checks restarting jobs is within the quotas of the
+// organization and the users who created them. Jobs that haven't finished
+// are already counted.
+func checkRestartQuotas(orgID int64, jobs []types.Job) (*quotaExceeded, error) {
+	total := 0
+	counts := map[string]int{}
+	for _, job := range jobs {
+		if isJobFinished(job.Status) {
+			counts[job.CreatedBy]++
+			total++
+		}
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	if e, err := checkQuotas(orgID, "", total, nil); e != nil || err != nil {
+		return e, err
+	}
+	for username, count := range counts {
+		if username == "" {
+			continue
+		}
+		if e, err := checkQuotas(orgID, username, count, nil); e != nil || err != nil {
+			return e, err
+		}
+	}
+	return nil, nil
+}
+
+// exceededQuota gets the quota exceeded by creating a number of jobs, if any.
+func exceededQuota(quota *types.Quota, usage *types.Usage, count int) *quotaExceeded {
+	now := time.Now()
+	switch {
+	case quota.MaxConcurrentJobs > 0 && usage.ConcurrentJobs+count > quota.MaxConcurrentJobs:
+		return &quotaExceeded{
+			Quota: QuotaMaxConcurrentJobs,
+			Limit: float64(quota.MaxConcurrentJobs),
+			Usage: float64(usage.ConcurrentJobs),
+		}
+	case quota.JobsPerDay > 0 && usage.JobsToday+count > quota.JobsPerDay:
+		tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		return &quotaExceeded{
+			Quota:      QuotaJobsPerDay,
+			Limit:      float64(quota.JobsPerDay),
+			Usage:      float64(usage.JobsToday),
+			RetryAfter: tomorrow.Sub(now),
+		}
+	case quota.EncodedMinutesPerMonth > 0 && usage.EncodedMinutes >= float64(quota.EncodedMinutesPerMonth):
+		nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+		return &quotaExceeded{
+			Quota:      QuotaEncodedMinutesPerMonth,
+			Limit:      float64(quota.EncodedMinutesPerMonth),
+			Usage:      usage.EncodedMinutes,
+			RetryAfter: nextMonth.Sub(now),
+		}
+	}
+	return nil
+}
+
+// respondQuotaExceeded responds to a request exceeding a quota with 429.
+func respondQuotaExceeded(c *gin.Context, e *quotaExceeded) {
+	if e.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())))
+	}
+
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"status":  http.StatusTooManyRequests,
+		"message": e.Error(),
+		"scope":   e.Scope,
+		"quota":   e.Quota,
+		"limit":   e.Limit,
+		"usage":   e.Usage,
+	})
+}
+
+// getUsageHandler handles the request to get the quotas and usage of the
+// active organization and the current user, and the rate limit of the API key.
+func getUsageHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	u := user.(*types.User)
+
+	db := data.New()
+	resp := gin.H{}
+	scopes := map[string]string{
+		QuotaScopeOrg:  "",
+		QuotaScopeUser: u.Username,
+	}
+	for scope, username := range scopes {
+		quota, err := db.Quotas.GetQuota(u.OrgID, username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error getting usage",
+			})
+			return
+		}
+		usage, err := db.Quotas.GetUsage(u.OrgID, username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error getting usage",
+			})
+			return
+		}
+		resp[scope] = usageResponse{Quota: quota, Usage: usage}
+	}
+
+	if limit, ok := c.Get(rateLimitKey); ok {
+		resp["rate_limit"] = limit
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// getOrganizationQuotaHandler handles the request to get the quota of an
+// organization.
+func getOrganizationQuotaHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if _, err := db.Organizations.GetOrganizationByID(int64(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Organization does not exist",
+		})
+		return
+	}
+	getQuota(c, int64(id), "")
+}
+
+// updateOrganizationQuotaHandler handles the request to set the quota of an
+// organization.
+func updateOrganizationQuotaHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if _, err := db.Organizations.GetOrganizationByID(int64(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Organization does not exist",
+		})
+		return
+	}
+	setQuota(c, int64(id), "")
+}
+
+// getMemberQuotaHandler handles the request to get the quota of a member of
+// the active organization.
+func getMemberQuotaHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	orgID := contextOrgID(c)
+
+	db := data.New()
+	member, err := db.Organizations.GetMember(orgID, int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Member does not exist",
+		})
+		return
+	}
+	getQuota(c, orgID, member.Username)
+}
+
+// updateMemberQuotaHandler handles the request to set the quota of a member
+// of the active organization.
+func updateMemberQuotaHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	orgID := contextOrgID(c)
+
+	db := data.New()
+	member, err := db.Organizations.GetMember(orgID, int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Member does not exist",
+		})
+		return
+	}
+	setQuota(c, orgID, member.Username)
+}
+
+// getQuota responds with the quota and usage of an organization, or of a
+// user in it if the username is set.
+func getQuota(c *gin.Context, orgID int64, username string) {
+	db := data.New()
+	quota, err := db.Quotas.GetQuota(orgID, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error getting quota",
+		})
+		return
+	}
+	usage, err := db.Quotas.GetUsage(orgID, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error getting quota",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"quota":  quota,
+		"usage":  usage,
+	})
+}
+
+// setQuota sets the quota of an organization, or of a user in it if the
+// username is set.
+func setQuota(c *gin.Context, orgID int64, username string) {
+	// Decode json.
+	var json quotaRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	quota, err := db.Quotas.SetQuota(types.Quota{
+		OrgID:                  orgID,
+		Username:               username,
+		MaxConcurrentJobs:      json.MaxConcurrentJobs,
+		JobsPerDay:             json.JobsPerDay,
+		EncodedMinutesPerMonth: json.EncodedMinutesPerMonth,
+		MaxSourceSize:          json.MaxSourceSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating quota",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"quota":  quota,
+	})
+}
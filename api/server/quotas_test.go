@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+func TestExceededQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota types.Quota
+		usage types.Usage
+		count int
+		want  string // Exceeded quota, or empty if within the quotas.
+		retry bool
+	}{
+		{"unlimited", types.Quota{}, types.Usage{ConcurrentJobs: 1000, JobsToday: 1000, EncodedMinutes: 1000}, 10, "", false},
+		{"concurrent within", types.Quota{MaxConcurrentJobs: 5}, types.Usage{ConcurrentJobs: 3}, 2, "", false},
+		{"concurrent over", types.Quota{MaxConcurrentJobs: 5}, types.Usage{ConcurrentJobs: 3}, 3, QuotaMaxConcurrentJobs, false},
+		{"concurrent already over", types.Quota{MaxConcurrentJobs: 5}, types.Usage{ConcurrentJobs: 5}, 0, "", false},
+		{"daily within", types.Quota{JobsPerDay: 10}, types.Usage{JobsToday: 9}, 1, "", false},
+		{"daily over", types.Quota{JobsPerDay: 10}, types.Usage{JobsToday: 9}, 2, QuotaJobsPerDay, true},
+		{"minutes under", types.Quota{EncodedMinutesPerMonth: 60}, types.Usage{EncodedMinutes: 59.5}, 1, "", false},
+		{"minutes used", types.Quota{EncodedMinutesPerMonth: 60}, types.Usage{EncodedMinutes: 60}, 1, QuotaEncodedMinutesPerMonth, true},
+		{"concurrent first", types.Quota{MaxConcurrentJobs: 1, JobsPerDay: 1}, types.Usage{ConcurrentJobs: 1, JobsToday: 1}, 1, QuotaMaxConcurrentJobs, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := exceededQuota(&tt.quota, &tt.usage, tt.count)
+			if tt.want == "" {
+				if e != nil {
+					t.Errorf("exceededQuota() = %s, want nil", e.Quota)
+				}
+				return
+			}
+			if e == nil {
+				t.Fatalf("exceededQuota() = nil, want %s", tt.want)
+			}
+			if e.Quota != tt.want {
+				t.Errorf("exceededQuota() = %s, want %s", e.Quota, tt.want)
+			}
+			if (e.RetryAfter > 0) != tt.retry {
+				t.Errorf("RetryAfter = %s, want retry %v", e.RetryAfter, tt.retry)
+			}
+		})
+	}
+}
+
+func TestQuotaExceededError(t *testing.T) {
+	tests := []struct {
+		e    quotaExceeded
+		want string
+	}{
+		{quotaExceeded{Scope: QuotaScopeOrg, Quota: QuotaJobsPerDay, Limit: 100, Usage: 100},
+			"organization quota exceeded: jobs_per_day is 100, used 100"},
+		{quotaExceeded{Scope: QuotaScopeUser, Quota: QuotaEncodedMinutesPerMonth, Limit: 60, Usage: 60.5},
+			"user quota exceeded: encoded_minutes_per_month is 60, used 60.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.e.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
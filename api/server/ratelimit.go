@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Rate limit settings. Requests with API keys are counted in fixed windows.
+const (
+	RateLimitWindow          = time.Minute
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+
+	rateLimitKey = "rate_limit"
+)
+
+// rateLimit describes the rate limit of an API key in the current window.
+// Reset is in seconds.
+type rateLimit struct {
+	Limit     int `json:"limit"`
+	Remaining int `json:"remaining"`
+	Reset     int `json:"reset"`
+}
+
+// apiKeyRateLimit gets the requests per window allowed for an API key, or 0
+// if unlimited.
+func apiKeyRateLimit(k *types.APIKey) int {
+	if k.RateLimit > 0 {
+		return k.RateLimit
+	}
+	return config.Get().APIKeyRateLimit
+}
+
+// checkRateLimit counts a request with an API key, and responds with 429 if
+// the key exceeded its rate limit. Requests are allowed if the count fails.
+func checkRateLimit(c *gin.Context, k *types.APIKey) bool {
+	limit := apiKeyRateLimit(k)
+	if limit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	window := now.Truncate(RateLimitWindow)
+	reset := int(window.Add(RateLimitWindow).Sub(now).Seconds()) + 1
+
+	count, err := countRequest(k.ID, window)
+	if err != nil {
+		log.Error(err)
+		return true
+	}
+
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header(RateLimitLimitHeader, strconv.Itoa(limit))
+	c.Header(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	c.Header(RateLimitResetHeader, strconv.Itoa(reset))
+	c.Set(rateLimitKey, rateLimit{Limit: limit, Remaining: remaining, Reset: reset})
+
+	if count > limit {
+		c.Header("Retry-After", strconv.Itoa(reset))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"code":    http.StatusTooManyRequests,
+			"message": fmt.Sprintf("rate limit exceeded: %d requests per minute", limit),
+		})
+		return false
+	}
+	return true
+}
+
+// countRequest increments the request count of an API key in a window.
+func countRequest(id int64, window time.Time) (int, error) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf("%s:ratelimit:%d:%d", config.Get().WorkerNamespace, id, window.Unix())
+	count, err := redis.Int(conn.Do("INCR", key))
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		conn.Do("EXPIRE", key, int(RateLimitWindow.Seconds())*2)
+	}
+	return count, nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/types"
+)
+
+// RetentionBatchSize is the max number of jobs purged per query.
+const RetentionBatchSize = 100
+
+// retentionPolicies maps the job statuses purged to their retention setting.
+var retentionPolicies = map[string]string{
+	types.JobCompleted: types.RetentionCompletedDays,
+	types.JobError:     types.RetentionFailedDays,
+	types.JobCancelled: types.RetentionCancelledDays,
+}
+
+func startRetention() {
+	interval := time.Duration(config.Get().RetentionInterval) * time.Second
+	if interval <= 0 {
+		log.Info("retention disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		runRetention()
+	}
+}
+
+// runRetention purges the jobs of each organization by its retention
+// settings.
+func runRetention() {
+	db := data.New()
+	orgs := db.Organizations.GetOrganizations(0, db.Organizations.GetOrganizationsCount())
+	for _, org := range *orgs {
+		settings := db.Settings.GetSettings(org.ID)
+		deleteOutputs := types.GetSetting(types.RetentionDeleteOutputs, settings) == "enabled"
+
+		for status, setting := range retentionPolicies {
+			days, err := strconv.Atoi(types.GetSetting(setting, settings))
+			if err != nil || days <= 0 {
+				continue // Keep forever.
+			}
+
+			before := time.Now().UTC().AddDate(0, 0, -days).Format(time.RFC3339Nano)
+			n, err := purgeJobs(org.ID, status, before, deleteOutputs)
+			if err != nil {
+				log.Errorf("retention %s %s: %s", org.Name, status, err)
+			}
+			if n > 0 {
+				log.Infof("retention %s: purged %d %s jobs older than %d days", org.Name, n, status, days)
+			}
+		}
+	}
+}
+
+// purgeJobs deletes the jobs of an organization with a status created before
+// a date, in batches. Jobs with outputs that fail to delete are kept to retry
+// on the next run, and skipped over so they don't stop later jobs from being
+// purged.
+func purgeJobs(orgID int64, status, before string, deleteOutputs bool) (int, error) {
+	db := data.New()
+	purged := 0
+	var last int64
+	for {
+		jobs, err := db.Jobs.GetExpiredJobs(orgID, status, before, last, RetentionBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(*jobs) > 0 {
+			last = (*jobs)[len(*jobs)-1].ID
+		}
+
+		ids := []int64{}
+		for _, j := range *jobs {
+			if deleteOutputs {
+				if err := net.DeleteOutputs(j); err != nil {
+					log.Errorf("retention: deleting outputs of job %d: %s", j.ID, err)
+					continue
+				}
+			}
+			ids = append(ids, j.ID)
+		}
+		if len(ids) > 0 {
+			results, err := db.Jobs.BulkUpdateJobs(data.BulkDelete, ids, orgID, "")
+			if err != nil {
+				return purged, err
+			}
+			for _, r := range results {
+				if r.Result == data.BulkOK {
+					purged++
+				}
+			}
+		}
+
+		// Done once a batch is not full.
+		if len(*jobs) < RetentionBatchSize {
+			return purged, nil
+		}
+	}
+}
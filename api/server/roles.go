@@ -0,0 +1,306 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleCacheTTL is how long roles are cached before they are reloaded, so
+// role changes on other servers apply within it.
+const RoleCacheTTL = 30 * time.Second
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+var roleCache = struct {
+	sync.Mutex
+	roles  map[string]*types.Role
+	loaded time.Time
+}{}
+
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// getRole gets a role by name from the role cache.
+func getRole(name string) (*types.Role, bool) {
+	roleCache.Lock()
+	defer roleCache.Unlock()
+
+	if roleCache.roles == nil || time.Since(roleCache.loaded) > RoleCacheTTL {
+		db := data.New()
+		roles := db.Roles.GetRoles()
+
+		// Keep the cached roles if the database can't be reached.
+		if len(*roles) > 0 || roleCache.roles == nil {
+			roleCache.roles = map[string]*types.Role{}
+			for i := range *roles {
+				r := (*roles)[i]
+				roleCache.roles[r.Name] = &r
+			}
+			roleCache.loaded = time.Now()
+		}
+	}
+
+	role, ok := roleCache.roles[name]
+	return role, ok
+}
+
+// invalidateRoles reloads the role cache on next use.
+func invalidateRoles() {
+	roleCache.Lock()
+	roleCache.roles = nil
+	roleCache.Unlock()
+}
+
+// hasPermission checks if the user's role has a permission.
+func hasPermission(user interface{}, permission string) bool {
+	u, ok := user.(*types.User)
+	if !ok {
+		return false
+	}
+
+	// System permissions are granted by the user's role, others by their
+	// role in the active organization.
+	name := u.OrgRole
+	if name == "" || types.IsSystemPermission(permission) {
+		name = u.Role
+	}
+	role, ok := getRole(name)
+	return ok && role.Can(permission)
+}
+
+// requirePermission is route middleware allowing users whose role has any of
+// the permissions.
+func requirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if checkPermission(c, permissions...) {
+			c.Next()
+		}
+	}
+}
+
+// checkPermission checks if the user's role has any of the permissions, for
+// routes needing a permission depending on the request. Otherwise, an
+// unauthorized error is sent.
+func checkPermission(c *gin.Context, permissions ...string) bool {
+	user, _ := c.Get(JwtIdentityKey)
+	for _, p := range permissions {
+		if hasPermission(user, p) {
+			return true
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
+	return false
+}
+
+// validatePermissions checks that permissions are known.
+func validatePermissions(permissions []string) bool {
+	for _, p := range permissions {
+		if p != types.PermAll && !containsString(types.Permissions, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// getPermissionsHandler handles the request to get the effective permissions
+// of the current user.
+func getPermissionsHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	u := user.(*types.User)
+
+	permissions := []string{}
+	for _, p := range types.Permissions {
+		if hasPermission(u, p) {
+			permissions = append(permissions, p)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":        u.Role,
+		"org_id":      u.OrgID,
+		"org_role":    u.OrgRole,
+		"permissions": permissions,
+	})
+}
+
+func getRolesHandler(c *gin.Context) {
+	db := data.New()
+	roles := db.Roles.GetRoles()
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":       roles,
+		"permissions": types.Permissions,
+	})
+}
+
+func getRoleByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	role, err := db.Roles.GetRoleByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Role does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"role":   role,
+	})
+}
+
+func createRoleHandler(c *gin.Context) {
+	// Decode json.
+	var json roleRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !roleNameRegexp.MatchString(json.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "name must be 1-64 lowercase letters, digits, dashes or underscores",
+		})
+		return
+	}
+	if !validatePermissions(json.Permissions) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "unknown permission",
+		})
+		return
+	}
+
+	db := data.New()
+	created, err := db.Roles.CreateRole(types.Role{
+		Name:        json.Name,
+		Description: json.Description,
+		Permissions: json.Permissions,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating role",
+		})
+		return
+	}
+	invalidateRoles()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": http.StatusCreated,
+		"role":   created,
+	})
+}
+
+func updateRoleByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json roleRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	role, err := db.Roles.GetRoleByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Role does not exist",
+		})
+		return
+	}
+
+	// Disallow updates on the admin role, so it can't be locked out.
+	if role.Name == RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "the admin role cannot be updated",
+		})
+		return
+	}
+	if !validatePermissions(json.Permissions) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "unknown permission",
+		})
+		return
+	}
+
+	// Roles can't be renamed, as users and tokens refer to them by name.
+	role.Description = json.Description
+	role.Permissions = json.Permissions
+
+	updated, err := db.Roles.UpdateRoleByID(int64(id), *role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating role",
+		})
+		return
+	}
+	invalidateRoles()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"role":   updated,
+	})
+}
+
+func deleteRoleByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	role, err := db.Roles.GetRoleByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Role does not exist",
+		})
+		return
+	}
+
+	if role.Builtin {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "built-in roles cannot be deleted",
+		})
+		return
+	}
+	if db.Roles.GetRoleUsersCount(role.Name) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": "role is assigned to users",
+		})
+		return
+	}
+
+	if err := db.Roles.DeleteRoleByID(int64(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error deleting role",
+		})
+		return
+	}
+	invalidateRoles()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "role deleted",
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// setRoles sets the role cache to roles, as if loaded from the database.
+func setRoles(t *testing.T, roles ...types.Role) {
+	roleCache.Lock()
+	roleCache.roles = map[string]*types.Role{}
+	for i := range roles {
+		roleCache.roles[roles[i].Name] = &roles[i]
+	}
+	roleCache.loaded = time.Now()
+	roleCache.Unlock()
+	t.Cleanup(invalidateRoles)
+}
+
+func TestHasPermission(t *testing.T) {
+	setRoles(t,
+		types.Role{Name: "admin", Permissions: []string{types.PermAll}},
+		types.Role{Name: "operator", Permissions: []string{types.PermJobsRead, types.PermJobsCreate, types.PermAPIKeysManage}},
+		types.Role{Name: "guest", Permissions: []string{types.PermJobsRead}},
+	)
+
+	tests := []struct {
+		name       string
+		user       interface{}
+		permission string
+		want       bool
+	}{
+		{"not a user", "admin", types.PermJobsRead, false},
+		{"nil user", nil, types.PermJobsRead, false},
+		{"admin all", &types.User{Role: "admin"}, types.PermUsersManage, true},
+		{"org role grants org permission", &types.User{Role: "guest", OrgRole: "operator"}, types.PermJobsCreate, true},
+		{"org role without permission", &types.User{Role: "admin", OrgRole: "guest"}, types.PermJobsCreate, false},
+		{"no org role uses system role", &types.User{Role: "operator"}, types.PermJobsCreate, true},
+		{"org admin has no system permission", &types.User{Role: "guest", OrgRole: "admin"}, types.PermOrgsManage, false},
+		{"org admin can't read audit", &types.User{Role: "guest", OrgRole: "admin"}, types.PermAuditRead, false},
+		{"system admin in guest org", &types.User{Role: "admin", OrgRole: "guest"}, types.PermUsersManage, true},
+		{"unknown role", &types.User{Role: "missing"}, types.PermJobsRead, false},
+		{"unknown org role", &types.User{Role: "admin", OrgRole: "missing"}, types.PermJobsRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasPermission(tt.user, tt.permission); got != tt.want {
+				t.Errorf("hasPermission(%v, %q) = %v, want %v", tt.user, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		want        bool
+	}{
+		{"none", []string{}, true},
+		{"all", []string{types.PermAll}, true},
+		{"known", []string{types.PermJobsRead, types.PermAuditRead}, true},
+		{"unknown", []string{types.PermJobsRead, "jobs:everything"}, false},
+		{"empty", []string{""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validatePermissions(tt.permissions); got != tt.want {
+				t.Errorf("validatePermissions(%v) = %v, want %v", tt.permissions, got, tt.want)
+			}
+		})
+	}
+}
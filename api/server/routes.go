@@ -1,6 +1,9 @@
 package server
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+)
 
 func registerRoutes(r *gin.Engine) {
 
@@ -8,61 +11,157 @@ func registerRoutes(r *gin.Engine) {
 	authMiddlware := jwtMiddleware()
 	r.POST("/api/register", registerHandler)
 	r.POST("/api/login", authMiddlware.LoginHandler)
-	r.GET("/api/refresh-token", authMiddlware.RefreshHandler)
+	r.POST("/api/login/totp", loginTOTPEnrolHandler)
+	r.GET("/api/refresh-token", refreshHandler(authMiddlware))
 	r.POST("/api/update-password", updatePasswordHandler)
+	r.GET("/api/auth/config", authConfigHandler)
+	r.GET("/api/oidc/login", oidcLoginHandler)
+	r.GET("/api/oidc/callback", oidcCallbackHandler(authMiddlware))
 	r.GET("/api/", indexHandler)
 	r.GET("/api/health", healthHandler)
 
 	// API routes.
 	api := r.Group("/api")
-	api.Use(authMiddlware.MiddlewareFunc())
+	api.Use(authMiddleware(authMiddlware))
 	{
 		// User profile.
+		api.POST("/logout", logoutHandler)
 		api.GET("/me", getUserProfileHandler)
 		api.PUT("/me", updateUserProfileHandler)
+		api.GET("/me/totp", getTOTPHandler)
+		api.POST("/me/totp", enrolTOTPHandler)
+		api.DELETE("/me/totp", disableTOTPHandler)
+		api.POST("/me/totp/verify", verifyTOTPHandler)
+		api.POST("/me/totp/recovery-codes", regenerateRecoveryCodesHandler)
+		api.GET("/me/orgs", getUserOrganizationsHandler)
+		api.PUT("/me/org", switchOrganizationHandler(authMiddlware))
+		api.GET("/usage", getUsageHandler)
 
 		// Storage.
-		api.GET("/storage/list", storageListHandler)
+		api.GET("/storage/list", requirePermission(types.PermStorageRead), storageListHandler)
+		api.GET("/storage/profiles", requirePermission(types.PermStorageManage), getStorageProfilesHandler)
+		api.POST("/storage/profiles", requirePermission(types.PermStorageManage), createStorageProfileHandler)
+		api.GET("/storage/profiles/:id", requirePermission(types.PermStorageManage), getStorageProfileByIDHandler)
+		api.PUT("/storage/profiles/:id", requirePermission(types.PermStorageManage), updateStorageProfileByIDHandler)
+		api.DELETE("/storage/profiles/:id", requirePermission(types.PermStorageManage), deleteStorageProfileByIDHandler)
 
 		// Jobs.
-		api.POST("/jobs", createJobHandler)
-		api.GET("/jobs", getJobsHandler)
-		api.GET("/jobs/:id", getJobsByIDHandler)
-		api.PUT("/jobs/:id", updateJobByIDHandler)
-		api.GET("/jobs/:id/status", getJobStatusByIDHandler)
-		api.POST("/jobs/:id/cancel", cancelJobByIDHandler)
-		api.POST("/jobs/:id/restart", restartJobByIDHandler)
+		api.POST("/jobs", requirePermission(types.PermJobsCreate), createJobHandler)
+		api.POST("/jobs/:id", postJobHandler) // POST /jobs/bulk and /jobs/estimate check permissions by action.
+		api.GET("/jobs", requirePermission(types.PermJobsRead), getJobsHandler)
+		api.GET("/jobs/:id", requirePermission(types.PermJobsRead), getJobsByIDHandler)
+		api.PUT("/jobs/:id", requirePermission(types.PermJobsUpdate), updateJobByIDHandler)
+		api.DELETE("/jobs/:id", requirePermission(types.PermJobsDelete), deleteJobByIDHandler)
+		api.GET("/jobs/:id/status", requirePermission(types.PermJobsRead), getJobStatusByIDHandler)
+		api.POST("/jobs/:id/cancel", requirePermission(types.PermJobsCancel), cancelJobByIDHandler)
+		api.POST("/jobs/:id/restart", requirePermission(types.PermJobsRestart), restartJobByIDHandler)
+		api.GET("/jobs/:id/stream", requirePermission(types.PermJobsRead), jobStreamHandler)
+		api.GET("/stream/jobs", requirePermission(types.PermJobsRead), jobsWebSocketHandler)
+
+		// Workflows.
+		api.GET("/workflows", requirePermission(types.PermJobsRead), getWorkflowsHandler)
+		api.POST("/workflows", requirePermission(types.PermJobsCreate), createWorkflowHandler)
+		api.GET("/workflows/:id", requirePermission(types.PermJobsRead), getWorkflowByIDHandler)
+
+		// Watchers.
+		api.GET("/watchers", requirePermission(types.PermWatchersManage), getWatchersHandler)
+		api.POST("/watchers", requirePermission(types.PermWatchersManage), createWatcherHandler)
+		api.GET("/watchers/:id", requirePermission(types.PermWatchersManage), getWatcherByIDHandler)
+		api.PUT("/watchers/:id", requirePermission(types.PermWatchersManage), updateWatcherByIDHandler)
+		api.DELETE("/watchers/:id", requirePermission(types.PermWatchersManage), deleteWatcherByIDHandler)
+
+		// Ingest.
+		api.GET("/ingest/rules", requirePermission(types.PermIngestManage), getIngestRulesHandler)
+		api.POST("/ingest/rules", requirePermission(types.PermIngestManage), createIngestRuleHandler)
+		api.GET("/ingest/rules/:id", requirePermission(types.PermIngestManage), getIngestRuleByIDHandler)
+		api.PUT("/ingest/rules/:id", requirePermission(types.PermIngestManage), updateIngestRuleByIDHandler)
+		api.DELETE("/ingest/rules/:id", requirePermission(types.PermIngestManage), deleteIngestRuleByIDHandler)
+
+		// Webhooks.
+		api.GET("/webhooks", requirePermission(types.PermWebhooksManage), getWebhooksHandler)
+		api.POST("/webhooks", requirePermission(types.PermWebhooksManage), createWebhookHandler)
+		api.GET("/webhooks/:id", requirePermission(types.PermWebhooksManage), getWebhookByIDHandler)
+		api.PUT("/webhooks/:id", requirePermission(types.PermWebhooksManage), updateWebhookByIDHandler)
+		api.DELETE("/webhooks/:id", requirePermission(types.PermWebhooksManage), deleteWebhookByIDHandler)
+		api.GET("/webhooks/:id/deliveries", requirePermission(types.PermWebhooksManage), getWebhookDeliveriesHandler)
+
+		// Notifications.
+		api.GET("/notifications/channels", requirePermission(types.PermNotificationsManage), getNotificationChannelsHandler)
+		api.POST("/notifications/channels", requirePermission(types.PermNotificationsManage), createNotificationChannelHandler)
+		api.GET("/notifications/channels/:id", requirePermission(types.PermNotificationsManage), getNotificationChannelByIDHandler)
+		api.PUT("/notifications/channels/:id", requirePermission(types.PermNotificationsManage), updateNotificationChannelByIDHandler)
+		api.DELETE("/notifications/channels/:id", requirePermission(types.PermNotificationsManage), deleteNotificationChannelByIDHandler)
+		api.POST("/notifications/channels/:id/test", requirePermission(types.PermNotificationsManage), testNotificationChannelHandler)
 
 		// Stats.
-		api.GET("/stats", getStatsHandler)
+		api.GET("/stats", requirePermission(types.PermStatsRead), getStatsHandler)
 
 		// Worker info.
-		api.GET("/worker/queue", workerQueueHandler)
-		api.GET("/worker/pools", workerPoolsHandler)
-		api.GET("/worker/busy", workerBusyHandler)
+		api.GET("/worker/queue", requirePermission(types.PermWorkersRead), workerQueueHandler)
+		api.GET("/worker/pools", requirePermission(types.PermWorkersRead), workerPoolsHandler)
+		api.GET("/worker/busy", requirePermission(types.PermWorkersRead), workerBusyHandler)
 
 		// Machines.
-		api.GET("/machines", machinesHandler)
-		api.POST("/machines", createMachineHandler)
-		api.DELETE("/machines", deleteMachineByTagHandler)
-		api.DELETE("/machines/:id", deleteMachineHandler)
-		api.GET("/machines/regions", listMachineRegionsHandler)
-		api.GET("/machines/sizes", listMachineSizesHandler)
-		api.GET("/machines/pricing", getCurrentMachinePricing)
-		api.GET("/machines/vpc", listVPCsHandler)
+		api.GET("/machines", requirePermission(types.PermMachinesRead), machinesHandler)
+		api.POST("/machines", requirePermission(types.PermMachinesManage), createMachineHandler)
+		api.DELETE("/machines", requirePermission(types.PermMachinesManage), deleteMachineByTagHandler)
+		api.DELETE("/machines/:id", requirePermission(types.PermMachinesManage), deleteMachineHandler)
+		api.GET("/machines/regions", requirePermission(types.PermMachinesRead), listMachineRegionsHandler)
+		api.GET("/machines/sizes", requirePermission(types.PermMachinesRead), listMachineSizesHandler)
+		api.GET("/machines/pricing", requirePermission(types.PermStatsRead), getCurrentMachinePricing)
+		api.GET("/machines/vpc", requirePermission(types.PermMachinesRead), listVPCsHandler)
 
 		// Presets.
-		api.POST("/presets", createPresetHandler)
-		api.GET("/presets", getPresetsHandler)
-		api.GET("/presets/:id", getPresetByIDHandler)
-		api.PUT("/presets/:id", updatePresetByIDHandler)
+		api.POST("/presets", requirePermission(types.PermPresetsWrite), createPresetHandler)
+		api.GET("/presets", requirePermission(types.PermPresetsRead), getPresetsHandler)
+		api.GET("/presets/:id", requirePermission(types.PermPresetsRead), getPresetByIDHandler)
+		api.PUT("/presets/:id", requirePermission(types.PermPresetsWrite), updatePresetByIDHandler)
 
 		// Users.
-		api.GET("/users", getUsersHandler)
-		api.PUT("/users/:id", updateUserByIDHandler)
+		api.GET("/users", requirePermission(types.PermUsersManage), getUsersHandler)
+		api.PUT("/users/:id", requirePermission(types.PermUsersManage), updateUserByIDHandler)
+		api.DELETE("/users/:id/totp", requirePermission(types.PermUsersManage), resetUserTOTPHandler)
+		api.POST("/users/:id/approve", requirePermission(types.PermUsersManage), approveUserHandler)
+
+		// Invitations.
+		api.GET("/invitations", requirePermission(types.PermUsersManage), getInvitationsHandler)
+		api.POST("/invitations", requirePermission(types.PermUsersManage), createInvitationHandler)
+		api.DELETE("/invitations/:id", requirePermission(types.PermUsersManage), deleteInvitationByIDHandler)
+
+		// API keys.
+		api.GET("/apikeys", requirePermission(types.PermAPIKeysManage), getAPIKeysHandler)
+		api.POST("/apikeys", requirePermission(types.PermAPIKeysManage), createAPIKeyHandler)
+		api.GET("/apikeys/:id", requirePermission(types.PermAPIKeysManage), getAPIKeyByIDHandler)
+		api.DELETE("/apikeys/:id", requirePermission(types.PermAPIKeysManage), deleteAPIKeyByIDHandler)
+
+		// Roles.
+		api.GET("/me/permissions", getPermissionsHandler)
+		api.GET("/roles", requirePermission(types.PermRolesManage, types.PermUsersManage, types.PermMembersManage), getRolesHandler)
+		api.POST("/roles", requirePermission(types.PermRolesManage), createRoleHandler)
+		api.GET("/roles/:id", requirePermission(types.PermRolesManage), getRoleByIDHandler)
+		api.PUT("/roles/:id", requirePermission(types.PermRolesManage), updateRoleByIDHandler)
+		api.DELETE("/roles/:id", requirePermission(types.PermRolesManage), deleteRoleByIDHandler)
+
+		// Organizations.
+		api.GET("/orgs", requirePermission(types.PermOrgsManage), getOrganizationsHandler)
+		api.POST("/orgs", requirePermission(types.PermOrgsManage), createOrganizationHandler)
+		api.GET("/orgs/:id", requirePermission(types.PermOrgsManage), getOrganizationByIDHandler)
+		api.PUT("/orgs/:id", requirePermission(types.PermOrgsManage), updateOrganizationByIDHandler)
+		api.DELETE("/orgs/:id", requirePermission(types.PermOrgsManage), deleteOrganizationByIDHandler)
+		api.GET("/orgs/:id/quota", requirePermission(types.PermOrgsManage), getOrganizationQuotaHandler)
+		api.PUT("/orgs/:id/quota", requirePermission(types.PermOrgsManage), updateOrganizationQuotaHandler)
+		api.GET("/org/members", requirePermission(types.PermMembersManage), getMembersHandler)
+		api.POST("/org/members", requirePermission(types.PermMembersManage), addMemberHandler)
+		api.PUT("/org/members/:id", requirePermission(types.PermMembersManage), updateMemberHandler)
+		api.DELETE("/org/members/:id", requirePermission(types.PermMembersManage), deleteMemberHandler)
+		api.GET("/org/members/:id/quota", requirePermission(types.PermMembersManage), getMemberQuotaHandler)
+		api.PUT("/org/members/:id/quota", requirePermission(types.PermMembersManage), updateMemberQuotaHandler)
 
 		// Settings.
-		api.GET("/settings", settingsHandler)
-		api.PUT("/settings", updateSettingsHandler)
+		api.GET("/settings", requirePermission(types.PermSettingsRead), settingsHandler)
+		api.PUT("/settings", requirePermission(types.PermSettingsWrite), updateSettingsHandler)
+
+		// Audit log.
+		api.GET("/audit", requirePermission(types.PermAuditRead), getAuditHandler)
 	}
 }
@@ -6,7 +6,10 @@ import (
 	"os"
 	"time"
 
+	"github.com/alfg/openencoder/api/config"
 	"github.com/alfg/openencoder/api/logging"
+	"github.com/alfg/openencoder/api/stream"
+	"github.com/alfg/openencoder/api/webhook"
 	"github.com/gin-gonic/gin"
 	"github.com/gocraft/work"
 	"github.com/gomodule/redigo/redis"
@@ -28,6 +31,9 @@ const (
 	JwtRealm       = "openencoder"
 	JwtIdentityKey = "id"
 	JwtRoleKey     = "role"
+	JwtVersionKey  = "ver"
+	JwtOrgKey      = "org"
+	JwtOrgRoleKey  = "org_role"
 	JwtTimeout     = time.Hour // Duration a JWT is valid.
 	JwtMaxRefresh  = time.Hour // Duration a JWT can be refreshed.
 
@@ -42,9 +48,11 @@ var (
 	ProjectVersion = os.Getenv("VERSION")
 
 	// Server settings.
-	redisPool *redis.Pool
-	enqueuer  *work.Enqueuer
-	log       = logging.Log
+	redisPool  *redis.Pool
+	enqueuer   *work.Enqueuer
+	dispatcher *webhook.Dispatcher
+	hub        *stream.Hub
+	log        = logging.Log
 )
 
 // Config defines configuration for creating a NewServer.
@@ -71,6 +79,29 @@ func NewServer(serverCfg Config) {
 	}
 	enqueuer = work.NewEnqueuer(serverCfg.Namespace, redisPool)
 
+	// Start webhook deliveries.
+	dispatcher = webhook.NewDispatcher(serverCfg.Namespace, redisPool)
+	deliveries := webhook.NewDeliveryPool(serverCfg.Namespace, redisPool, config.Get().WebhookConcurrency)
+	deliveries.Start()
+
+	// Start live job updates.
+	stream.Init(redisPool, serverCfg.Namespace)
+	hub = stream.NewHub()
+	go hub.Run()
+
+	// Load the breached passwords of the password policy.
+	loadBreachedPasswords()
+
+	// Start watch folder scans.
+	go startWatchers()
+	go startIngest()
+
+	// Start purging expired jobs.
+	go startRetention()
+
+	// Start queueing jobs once their dependencies complete.
+	go startWorkflows()
+
 	// Setup server.
 	r := gin.New()
 	r.Use(gin.Logger())
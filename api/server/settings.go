@@ -30,24 +30,34 @@ type settingsUpdateRequest struct {
 	DigitalOceanRegion      string `json:"DIGITAL_OCEAN_REGION"`
 	DigitalOceanVPC         string `json:"DIGITAL_OCEAN_VPC"`
 	SlackWebhook            string `json:"SLACK_WEBHOOK"`
-}
+	WebhookSecret           string `json:"WEBHOOK_SECRET"`
 
-func settingsHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
+	RetentionCompletedDays string `json:"RETENTION_COMPLETED_DAYS" binding:"omitempty,numeric,min=0"`
+	RetentionFailedDays    string `json:"RETENTION_FAILED_DAYS" binding:"omitempty,numeric,min=0"`
+	RetentionCancelledDays string `json:"RETENTION_CANCELLED_DAYS" binding:"omitempty,numeric,min=0"`
+	RetentionDeleteOutputs string `json:"RETENTION_DELETE_OUTPUTS" binding:"eq=enabled|eq=disabled|eq="`
 
-	// Role check.
-	if !isAdmin(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
+	JobVisibility string `json:"JOB_VISIBILITY" binding:"eq=all|eq=own|eq="`
+
+	RequireAdminTOTP string `json:"REQUIRE_ADMIN_TOTP" binding:"eq=enabled|eq=disabled|eq="`
+
+	RegistrationMode     string `json:"REGISTRATION_MODE" binding:"eq=open|eq=invite|eq=disabled|eq="`
+	RegistrationApproval string `json:"REGISTRATION_APPROVAL" binding:"eq=enabled|eq=disabled|eq="`
+}
 
+func settingsHandler(c *gin.Context) {
+	orgID := contextOrgID(c)
 	d := data.New()
-	settings := d.Settings.GetSettings()
+	settings := d.Settings.GetSettings(orgID)
 	settingOptions := d.Settings.GetSettingsOptions()
 
-	// Get all settings for response and set blank defaults.
+	// Get all settings for response and set blank defaults. Instance settings
+	// are only set in the default organization.
 	var resp []types.SettingsForm
 	for _, v := range settingOptions {
+		if orgID != types.DefaultOrgID && types.IsInstanceSetting(v.Name) {
+			continue
+		}
 		s := types.SettingsForm{
 			Title:       v.Title,
 			Name:        v.Name,
@@ -68,14 +78,6 @@ func settingsHandler(c *gin.Context) {
 }
 
 func updateSettingsHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdmin(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	// Decode json.
 	var json settingsUpdateRequest
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -105,17 +107,53 @@ func updateSettingsHandler(c *gin.Context) {
 		types.DigitalOceanRegion:      json.DigitalOceanRegion,
 		types.DigitalOceanVPC:         json.DigitalOceanVPC,
 		types.SlackWebhook:            json.SlackWebhook,
+		types.WebhookSecret:           json.WebhookSecret,
+
+		types.RetentionCompletedDays: json.RetentionCompletedDays,
+		types.RetentionFailedDays:    json.RetentionFailedDays,
+		types.RetentionCancelledDays: json.RetentionCancelledDays,
+		types.RetentionDeleteOutputs: json.RetentionDeleteOutputs,
+
+		types.JobVisibility: json.JobVisibility,
+
+		types.RequireAdminTOTP: json.RequireAdminTOTP,
+
+		types.RegistrationMode:     json.RegistrationMode,
+		types.RegistrationApproval: json.RegistrationApproval,
+	}
+
+	orgID := contextOrgID(c)
+	if orgID != types.DefaultOrgID {
+		for k := range s {
+			if types.IsInstanceSetting(k) {
+				delete(s, k)
+			}
+		}
 	}
 
 	db := data.New()
-	// userID := db.Users.GetUserID(username)
+	before := map[string]interface{}{}
+	for _, v := range db.Settings.GetSettings(orgID) {
+		before[v.Name] = v.Value
+	}
+	secrets := map[string]bool{}
+	for _, v := range db.Settings.GetSettingsOptions() {
+		secrets[v.Name] = v.Secure
+	}
 
-	err := db.Settings.UpdateSettings(s)
+	err := db.Settings.UpdateSettings(orgID, s)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "error updating settings",
 		})
+		return
+	}
+
+	after := map[string]interface{}{}
+	for k, v := range s {
+		after[k] = v
 	}
+	recordAudit(c, types.AuditSettingsUpdate, "settings", orgID, auditDiff(before, after, secrets))
 
 	c.JSON(http.StatusOK, gin.H{
 		"settings": "updated",
@@ -9,7 +9,7 @@ import (
 
 func getStatsHandler(c *gin.Context) {
 	db := data.New()
-	stats, err := db.Jobs.GetJobsStats()
+	stats, err := db.Jobs.GetJobsStats(contextOrgID(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"status":  http.StatusNotFound,
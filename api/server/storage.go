@@ -2,6 +2,8 @@ package server
 
 import (
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/alfg/openencoder/api/data"
 	"github.com/alfg/openencoder/api/net"
@@ -10,12 +12,6 @@ import (
 	"github.com/jlaffaye/ftp"
 )
 
-// Storage drivers available.
-const (
-	StorageS3  = "s3"
-	StorageFTP = "ftp"
-)
-
 type storageListResponse struct {
 	Folders []string `json:"folders"`
 	Files   []file   `json:"files"`
@@ -31,49 +27,53 @@ type s3ListResponse struct {
 	Files   []file   `json:"files"`
 }
 
+type storageProfileRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Driver         string `json:"driver" binding:"eq=s3|eq=ftp"`
+	Provider       string `json:"provider" binding:"eq=digitaloceanspaces|eq=amazonaws|eq=custom|eq="`
+	Endpoint       string `json:"endpoint"`
+	Region         string `json:"region"`
+	AccessKey      string `json:"access_key"`
+	SecretKey      string `json:"secret_key"`
+	InboundBucket  string `json:"inbound_bucket"`
+	OutboundBucket string `json:"outbound_bucket"`
+	FTPAddr        string `json:"ftp_addr"`
+	FTPUsername    string `json:"ftp_username"`
+	FTPPassword    string `json:"ftp_password"`
+}
+
 func storageListHandler(c *gin.Context) {
 	prefix := c.DefaultQuery("prefix", "")
+	profile := c.DefaultQuery("profile", "")
 
-	db := data.New()
-	driver, err := db.Settings.GetSetting(types.StorageDriver)
+	storage, err := net.GetStorage(contextOrgID(c), profile)
 	if err != nil {
 		log.Error(err)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
 			"message": "storage not configured",
 		})
+		return
 	}
 
-	files := getFileList(driver.Value, prefix)
+	files := getFileList(storage, prefix)
 
 	c.JSON(200, gin.H{
 		"data": files,
 	})
 }
 
-func getFileList(driver string, prefix string) *storageListResponse {
+func getFileList(storage *net.Storage, prefix string) *storageListResponse {
 	resp := &storageListResponse{}
-	if driver == StorageS3 {
-		resp, _ = getS3FileList(prefix)
-	} else if driver == StorageFTP {
-		resp, _ = getFTPFileList(prefix)
+	if storage.Driver == types.StorageS3 {
+		resp, _ = getS3FileList(storage.S3, prefix)
+	} else if storage.Driver == types.StorageFTP {
+		resp, _ = getFTPFileList(storage.FTP, prefix)
 	}
 	return resp
 }
 
-func getS3FileList(prefix string) (*storageListResponse, error) {
-	db := data.New()
-	settings := db.Settings.GetSettings()
-
-	config := net.S3Config{
-		AccessKey:      types.GetSetting(types.S3AccessKey, settings),
-		SecretKey:      types.GetSetting(types.S3SecretKey, settings),
-		Provider:       types.GetSetting(types.S3Provider, settings),
-		Region:         types.GetSetting(types.S3OutboundBucketRegion, settings),
-		InboundBucket:  types.GetSetting(types.S3InboundBucket, settings),
-		OutboundBucket: types.GetSetting(types.S3OutboundBucket, settings),
-	}
-
+func getS3FileList(config net.S3Config, prefix string) (*storageListResponse, error) {
 	s3 := net.NewS3(config)
 
 	resp := &storageListResponse{}
@@ -95,15 +95,8 @@ func getS3FileList(prefix string) (*storageListResponse, error) {
 	return resp, nil
 }
 
-func getFTPFileList(prefix string) (*storageListResponse, error) {
-	db := data.New()
-	settings := db.Settings.GetSettings()
-
-	addr := types.GetSetting(types.FTPAddr, settings)
-	user := types.GetSetting(types.FTPUsername, settings)
-	pass := types.GetSetting(types.FTPPassword, settings)
-
-	f := net.NewFTP(addr, user, pass)
+func getFTPFileList(config net.FTPConfig, prefix string) (*storageListResponse, error) {
+	f := net.NewFTP(config.Addr, config.Username, config.Password)
 	files, err := f.ListFiles(prefix)
 	if err != nil {
 		return nil, err
@@ -123,3 +116,186 @@ func getFTPFileList(prefix string) (*storageListResponse, error) {
 	}
 	return resp, nil
 }
+
+func getStorageProfilesHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var profiles *[]types.StorageProfile
+	var profilesCount int
+
+	orgID := contextOrgID(c)
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		profiles = db.Storage.GetStorageProfiles(orgID, (pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		profilesCount = db.Storage.GetStorageProfilesCount(orgID)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    profilesCount,
+		"profiles": profiles,
+	})
+}
+
+func getStorageProfileByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	profile, err := db.Storage.GetStorageProfileByID(contextOrgID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Storage profile does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"profile": profile,
+	})
+}
+
+func createStorageProfileHandler(c *gin.Context) {
+	// Decode json.
+	var json storageProfileRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile := types.StorageProfile{
+		Name:           json.Name,
+		Driver:         json.Driver,
+		Provider:       json.Provider,
+		Endpoint:       json.Endpoint,
+		Region:         json.Region,
+		AccessKey:      json.AccessKey,
+		SecretKey:      json.SecretKey,
+		InboundBucket:  json.InboundBucket,
+		OutboundBucket: json.OutboundBucket,
+		FTPAddr:        json.FTPAddr,
+		FTPUsername:    json.FTPUsername,
+		FTPPassword:    json.FTPPassword,
+		OrgID:          contextOrgID(c),
+	}
+
+	db := data.New()
+	created, err := db.Storage.CreateStorageProfile(profile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating storage profile",
+		})
+		return
+	}
+	recordAudit(c, types.AuditStorageCreate, "storage_profile", created.ID,
+		auditDiff(nil, storageProfileAuditValues(&profile), storageProfileSecrets))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"profile": created,
+	})
+}
+
+func updateStorageProfileByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json storageProfileRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	profile, err := db.Storage.GetStorageProfileByID(contextOrgID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Storage profile does not exist",
+		})
+		return
+	}
+
+	before := storageProfileAuditValues(profile)
+
+	profile.Name = json.Name
+	profile.Driver = json.Driver
+	profile.Provider = json.Provider
+	profile.Endpoint = json.Endpoint
+	profile.Region = json.Region
+	profile.InboundBucket = json.InboundBucket
+	profile.OutboundBucket = json.OutboundBucket
+	profile.FTPAddr = json.FTPAddr
+
+	// Only replace credentials if provided, since they are never returned.
+	if json.AccessKey != "" {
+		profile.AccessKey = json.AccessKey
+	}
+	if json.SecretKey != "" {
+		profile.SecretKey = json.SecretKey
+	}
+	if json.FTPUsername != "" {
+		profile.FTPUsername = json.FTPUsername
+	}
+	if json.FTPPassword != "" {
+		profile.FTPPassword = json.FTPPassword
+	}
+
+	updated, err := db.Storage.UpdateStorageProfileByID(id, *profile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating storage profile",
+		})
+		return
+	}
+	recordAudit(c, types.AuditStorageUpdate, "storage_profile", id,
+		auditDiff(before, storageProfileAuditValues(profile), storageProfileSecrets))
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteStorageProfileByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	profile, err := db.Storage.GetStorageProfileByID(contextOrgID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Storage profile does not exist",
+		})
+		return
+	}
+	if err := db.Storage.DeleteStorageProfileByID(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error deleting storage profile",
+		})
+		return
+	}
+	after := map[string]interface{}{"name": nil}
+	recordAudit(c, types.AuditStorageDelete, "storage_profile", id,
+		auditDiff(map[string]interface{}{"name": profile.Name}, after, nil))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"deleted": true,
+	})
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// StreamKeepAlive is the interval keep-alive messages are sent to streams.
+const StreamKeepAlive = 15 * time.Second
+
+// jobStreamHandler streams the live updates of a job as Server-Sent Events.
+// The current job is sent first as a "job" event, followed by "update" events.
+func jobStreamHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, _ := c.Get(JwtIdentityKey)
+
+	job, ok := getVisibleJob(c, user, int64(id))
+	if !ok {
+		return
+	}
+
+	updates := hub.Subscribe(job.GUID)
+	defer hub.Unsubscribe(updates)
+
+	ticker := time.NewTicker(StreamKeepAlive)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("job", job)
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case u := <-updates:
+			c.SSEvent("update", u)
+		case <-ticker.C:
+			c.SSEvent("ping", "")
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
+// jobsWebSocketHandler sends the live updates of all jobs as JSON messages
+// over a WebSocket. Users only get the updates of their organization's jobs,
+// and of their own jobs if restricted.
+func jobsWebSocketHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	orgID := userOrgID(user)
+	owner := jobOwner(user)
+	visible := map[string]bool{} // Cache of visible job GUIDs.
+
+	s := websocket.Server{
+		// Allow any origin, as requests are authenticated by token.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			updates := hub.Subscribe("")
+			defer hub.Unsubscribe(updates)
+
+			// Read until the client closes the connection.
+			closed := make(chan struct{})
+			go func() {
+				io.Copy(ioutil.Discard, ws)
+				close(closed)
+			}()
+
+			for {
+				select {
+				case u := <-updates:
+					if !isJobVisible(visible, u.GUID, orgID, owner) {
+						continue
+					}
+					if err := websocket.JSON.Send(ws, u); err != nil {
+						return
+					}
+				case <-closed:
+					return
+				}
+			}
+		},
+	}
+	s.ServeHTTP(c.Writer, c.Request)
+}
+
+// isJobVisible checks a job is of the organization and, if an owner is set,
+// was created by the owner, caching the result.
+func isJobVisible(visible map[string]bool, guid string, orgID int64, owner string) bool {
+	if ok, cached := visible[guid]; cached {
+		return ok
+	}
+
+	db := data.New()
+	job, err := db.Jobs.GetJobByGUID(guid)
+	if err != nil {
+		return false
+	}
+	visible[guid] = job.OrgID == orgID && (owner == "" || job.CreatedBy == owner)
+	return visible[guid]
+}
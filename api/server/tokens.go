@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alfg/openencoder/api/types"
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Token revocation. Each user has a token version in Redis that is included
+// in their JWTs. Bumping it revokes all tokens issued before.
+const (
+	TokenVersionPrefix = "token:version:"
+	tokenRevokedKey    = "token_revoked" // Context key set when a token is revoked.
+)
+
+var errTokenRevoked = errors.New("token has been revoked")
+
+// tokenVersion gets the current token version of a user.
+func tokenVersion(username string) (int64, error) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	version, err := redis.Int64(conn.Do("GET", TokenVersionPrefix+username))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// revokeTokens revokes all tokens of a user.
+func revokeTokens(username string) error {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("INCR", TokenVersionPrefix+username)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// isTokenRevoked checks the token version claims against the user's current
+// version. Tokens are treated as revoked if the version can't be checked.
+func isTokenRevoked(claims map[string]interface{}) bool {
+	username, _ := claims[JwtIdentityKey].(string)
+	version, _ := claims[JwtVersionKey].(float64) // Tokens without a version are version 0.
+
+	current, err := tokenVersion(username)
+	if err != nil {
+		log.Error(err)
+		return true
+	}
+	return int64(version) != current
+}
+
+// refreshHandler refreshes a token unless it has been revoked.
+func refreshHandler(jwtMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := jwtMiddleware.CheckIfTokenExpire(c)
+		if err == nil && isTokenRevoked(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": errTokenRevoked.Error(),
+			})
+			return
+		}
+		jwtMiddleware.RefreshHandler(c)
+	}
+}
+
+// logoutHandler handles the request to log out the current user, revoking
+// all of their tokens.
+func logoutHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	username := user.(*types.User).Username
+
+	if err := revokeTokens(username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "error logging out",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    http.StatusOK,
+		"message": "logged out",
+	})
+}
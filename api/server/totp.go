@@ -0,0 +1,524 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/totp"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Two-factor authentication settings.
+const (
+	TOTPRecoveryCodes = 10  // Recovery codes generated on enrolment.
+	TOTPEnrolTTL      = 600 // Seconds to verify an enrolment.
+	TOTPEnrolPrefix   = "totp:enrol:"
+	TOTPUsedPrefix    = "totp:used:"
+
+	LoginChallengePrefix   = "login:challenge:"
+	LoginChallengeTTL      = 300 // Seconds to complete a login challenge.
+	LoginChallengeAttempts = 5   // Invalid codes before a challenge is dropped.
+
+	loginChallengeKey = "login_challenge" // Context keys set by the Authenticator.
+	recoveryCodesKey  = "recovery_codes"
+)
+
+var (
+	errTOTPRequired      = errors.New("two-factor authentication required")
+	errTOTPEnrolRequired = errors.New("two-factor enrolment required")
+	errInvalidChallenge  = errors.New("invalid or expired challenge")
+	errInvalidTOTPCode   = errors.New("invalid two-factor code")
+)
+
+// loginChallenge is the second step of a login, stored by its ID after the
+// password is verified. Users enrolling during login get a pending secret.
+type loginChallenge struct {
+	Username string `json:"username"`
+	Enrol    bool   `json:"enrol"`
+	Secret   string `json:"secret"`
+	Attempts int    `json:"attempts"`
+}
+
+type totpCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type loginTOTPEnrolRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+}
+
+// adminTOTPRequired checks if admins must use two-factor authentication.
+func adminTOTPRequired() bool {
+	db := data.New()
+	setting, err := db.Settings.GetSetting(types.DefaultOrgID, types.RequireAdminTOTP)
+	return err == nil && setting.Value == "enabled"
+}
+
+// totpRequired checks if a user must use two-factor authentication.
+func totpRequired(user *types.User) bool {
+	return user.TOTPEnabled || (user.Role == RoleAdmin && adminTOTPRequired())
+}
+
+// startLoginChallenge creates the second step of a login for a user whose
+// password was verified. The challenge ID is returned with the error.
+func startLoginChallenge(c *gin.Context, user *types.User) error {
+	id, err := generateToken(16)
+	if err != nil {
+		return err
+	}
+
+	challenge := &loginChallenge{
+		Username: user.Username,
+		Enrol:    !user.TOTPEnabled,
+	}
+	if err := saveLoginChallenge(id, challenge); err != nil {
+		return err
+	}
+
+	c.Set(loginChallengeKey, id)
+	if challenge.Enrol {
+		return errTOTPEnrolRequired
+	}
+	return errTOTPRequired
+}
+
+// authenticateLoginChallenge completes a login challenge with a TOTP or
+// recovery code. Users enrolling during login get their recovery codes set
+// in the context for the login response.
+func authenticateLoginChallenge(c *gin.Context, vals login) (*types.User, error) {
+	challenge, err := getLoginChallenge(vals.Challenge)
+	if err != nil {
+		return nil, errInvalidChallenge
+	}
+
+	if checkLoginLockout(c, challenge.Username) {
+		recordLogin(c, challenge.Username, LoginMethodTOTP, false, "locked out")
+		return nil, errLoginLocked
+	}
+
+	db := data.New()
+	user, err := db.Users.GetUserByUsername(challenge.Username)
+	if err != nil || !user.Active {
+		return nil, errInvalidChallenge
+	}
+
+	var ok bool
+	switch {
+	case challenge.Enrol && challenge.Secret != "":
+		if ok = validateTOTPCode(user.Username, challenge.Secret, vals.Code); ok {
+			codes, err := enableTOTP(user.ID, challenge.Secret)
+			if err != nil {
+				return nil, err
+			}
+			c.Set(recoveryCodesKey, codes)
+		}
+	case challenge.Enrol:
+		return nil, errors.New("start two-factor enrolment first")
+	case vals.RecoveryCode != "":
+		ok, _ = db.Users.UseUserRecoveryCode(user.ID, hashRecoveryCode(vals.RecoveryCode))
+	default:
+		ok = validateTOTPCode(user.Username, user.TOTPSecret, vals.Code)
+	}
+
+	if !ok {
+		challenge.Attempts++
+		if challenge.Attempts >= LoginChallengeAttempts {
+			deleteLoginChallenge(vals.Challenge)
+		} else {
+			saveLoginChallenge(vals.Challenge, challenge)
+		}
+		c.Set(loginChallengeKey, vals.Challenge)
+		countLoginFailure(c, user.Username)
+		recordLogin(c, user.Username, LoginMethodTOTP, false, "invalid code")
+		return nil, errInvalidTOTPCode
+	}
+
+	deleteLoginChallenge(vals.Challenge)
+	resetLoginFailures(user.Username)
+	recordLogin(c, user.Username, LoginMethodTOTP, true, "")
+	return &types.User{
+		Username: user.Username,
+		Role:     user.Role,
+	}, nil
+}
+
+// validateTOTPCode checks a code against a secret. Each code is only accepted
+// once per user.
+func validateTOTPCode(username, secret, code string) bool {
+	step, ok := totp.Validate(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false
+	}
+
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	key := fmt.Sprintf("%s%s:%d", TOTPUsedPrefix, username, step)
+	ttl := totp.Period * (2*totp.Skew + 2)
+	_, err := redis.String(conn.Do("SET", key, 1, "NX", "EX", ttl))
+	return err == nil
+}
+
+// enableTOTP saves a verified secret and returns new recovery codes.
+func enableTOTP(userID int64, secret string) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	db := data.New()
+	if err := db.Users.UpdateUserTOTPByID(userID, secret, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCodes returns new recovery codes, and their hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := []string{}
+	hashes := []string{}
+	for i := 0; i < TOTPRecoveryCodes; i++ {
+		b, err := generateToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := b[:5] + "-" + b[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode returns the hash of a recovery code, ignoring case and
+// separators.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateToken returns n random bytes, hex encoded.
+func generateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func saveLoginChallenge(id string, challenge *loginChallenge) error {
+	b, _ := json.Marshal(challenge)
+
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", LoginChallengePrefix+id, b, "EX", LoginChallengeTTL)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+func getLoginChallenge(id string) (*loginChallenge, error) {
+	conn := redisPool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("GET", LoginChallengePrefix+id))
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &loginChallenge{}
+	if err := json.Unmarshal(b, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func deleteLoginChallenge(id string) {
+	conn := redisPool.Get()
+	defer conn.Close()
+	conn.Do("DEL", LoginChallengePrefix+id)
+}
+
+// loginTOTPEnrolHandler handles the request to start two-factor enrolment
+// during login, for users required to use it who haven't enrolled.
+func loginTOTPEnrolHandler(c *gin.Context) {
+	var json loginTOTPEnrolRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := getLoginChallenge(json.Challenge)
+	if err != nil || !challenge.Enrol {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    http.StatusUnauthorized,
+			"message": errInvalidChallenge.Error(),
+		})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "error starting enrolment",
+		})
+		return
+	}
+
+	challenge.Secret = secret
+	if err := saveLoginChallenge(json.Challenge, challenge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "error starting enrolment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    totp.URI(ProjectName, challenge.Username, secret),
+	})
+}
+
+// getTOTPHandler handles the request to get the two-factor status of the
+// current user.
+func getTOTPHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+
+	db := data.New()
+	u, err := db.Users.GetUserByUsername(user.(*types.User).Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "User does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":        u.TOTPEnabled,
+		"required":       totpRequired(u),
+		"recovery_codes": len(u.RecoveryCodes),
+	})
+}
+
+// enrolTOTPHandler handles the request to start two-factor enrolment. The
+// secret is enabled once a code is verified.
+func enrolTOTPHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	username := user.(*types.User).Username
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error starting enrolment",
+		})
+		return
+	}
+
+	conn := redisPool.Get()
+	_, err = conn.Do("SET", TOTPEnrolPrefix+username, secret, "EX", TOTPEnrolTTL)
+	conn.Close()
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error starting enrolment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    totp.URI(ProjectName, username, secret),
+	})
+}
+
+// verifyTOTPHandler handles the request to verify a code of a pending
+// enrolment, enabling two-factor authentication.
+func verifyTOTPHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	username := user.(*types.User).Username
+
+	var json totpCodeRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn := redisPool.Get()
+	secret, err := redis.String(conn.Do("GET", TOTPEnrolPrefix+username))
+	conn.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "no pending enrolment",
+		})
+		return
+	}
+
+	if !validateTOTPCode(username, secret, json.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": errInvalidTOTPCode.Error(),
+		})
+		return
+	}
+
+	db := data.New()
+	u, err := db.Users.GetUserByUsername(username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "User does not exist",
+		})
+		return
+	}
+
+	codes, err := enableTOTP(u.ID, secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error enabling two-factor authentication",
+		})
+		return
+	}
+
+	conn = redisPool.Get()
+	conn.Do("DEL", TOTPEnrolPrefix+username)
+	conn.Close()
+
+	// Recovery codes are only returned once.
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// regenerateRecoveryCodesHandler handles the request to replace the recovery
+// codes of the current user.
+func regenerateRecoveryCodesHandler(c *gin.Context) {
+	u, ok := verifyCurrentTOTP(c)
+	if !ok {
+		return
+	}
+
+	codes, err := enableTOTP(u.ID, u.TOTPSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error generating recovery codes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// disableTOTPHandler handles the request to disable two-factor authentication
+// of the current user, unless it is required for them.
+func disableTOTPHandler(c *gin.Context) {
+	u, ok := verifyCurrentTOTP(c)
+	if !ok {
+		return
+	}
+
+	if u.Role == RoleAdmin && adminTOTPRequired() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "two-factor authentication is required for admins",
+		})
+		return
+	}
+
+	db := data.New()
+	if err := db.Users.UpdateUserTOTPByID(u.ID, "", nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error disabling two-factor authentication",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": false,
+	})
+}
+
+// verifyCurrentTOTP checks a code sent for the current user, who must have
+// two-factor authentication enabled. Otherwise, an error is sent.
+func verifyCurrentTOTP(c *gin.Context) (*types.User, bool) {
+	user, _ := c.Get(JwtIdentityKey)
+
+	var json totpCodeRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	db := data.New()
+	u, err := db.Users.GetUserByUsername(user.(*types.User).Username)
+	if err != nil || !u.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "two-factor authentication is not enabled",
+		})
+		return nil, false
+	}
+
+	if !validateTOTPCode(u.Username, u.TOTPSecret, json.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": errInvalidTOTPCode.Error(),
+		})
+		return nil, false
+	}
+	return u, true
+}
+
+// resetUserTOTPHandler handles the request to reset two-factor
+// authentication of a user who lost their device, for user management.
+func resetUserTOTPHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	u, err := db.Users.GetUserByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "User does not exist",
+		})
+		return
+	}
+
+	if err := db.Users.UpdateUserTOTPByID(u.ID, "", nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error resetting two-factor authentication",
+		})
+		return
+	}
+	revokeTokens(u.Username)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "two-factor authentication reset",
+	})
+}
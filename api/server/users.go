@@ -1,10 +1,13 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
 	"strconv"
 	"sync"
 
+	"github.com/alfg/openencoder/api/config"
 	"github.com/alfg/openencoder/api/data"
 	"github.com/alfg/openencoder/api/types"
 	"github.com/gin-gonic/gin"
@@ -12,8 +15,9 @@ import (
 )
 
 type registerRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	Invitation string `json:"invitation"`
 }
 
 type userProfileUpdateRequest struct {
@@ -25,7 +29,7 @@ type userProfileUpdateRequest struct {
 
 type userUpdateRequest struct {
 	Active bool   `json:"active"`
-	Role   string `json:"role" binding:"eq=admin|eq=operator|eq=guest|eq="`
+	Role   string `json:"role"`
 }
 
 type userPasswordUpdateRequest struct {
@@ -37,6 +41,14 @@ type userPasswordUpdateRequest struct {
 
 // registerHandler handles the request to register a new user.
 func registerHandler(c *gin.Context) {
+	if config.Get().DisableLocalLogin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    http.StatusForbidden,
+			"message": errLocalLoginDisabled.Error(),
+		})
+		return
+	}
+
 	// Decode json.
 	var json registerRequest
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -44,7 +56,42 @@ func registerHandler(c *gin.Context) {
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(json.Password), bcrypt.MinCost)
+	// Check the registration mode. Invited users can register unless
+	// registration is disabled.
+	switch mode := registrationMode(); {
+	case mode == types.RegistrationDisabled:
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    http.StatusForbidden,
+			"message": "registration is disabled",
+		})
+		return
+	case mode != types.RegistrationOpen && json.Invitation == "":
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    http.StatusForbidden,
+			"message": "registration requires an invitation",
+		})
+		return
+	}
+
+	db := data.New()
+	if json.Invitation != "" {
+		if _, err := db.Invitations.GetInvitationByHash(hashInvitationToken(json.Invitation)); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    http.StatusForbidden,
+				"message": data.ErrInvitationInvalid.Error(),
+			})
+			return
+		}
+	}
+
+	if err := validatePassword(json.Username, json.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	hash, err := hashPassword(json.Password)
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "error creating user",
@@ -52,15 +99,28 @@ func registerHandler(c *gin.Context) {
 		return
 	}
 
-	// Create Job and push the work to work queue.
 	user := types.User{
 		Username: json.Username,
-		Password: string(hash),
-		Role:     "guest",
+		Password: hash,
 	}
 
-	db := data.New()
-	u, err := db.Users.CreateUser(user)
+	// Users are guests of the instance. Invited users get the invitation's
+	// role in its organization. Others are pending approval if required.
+	var u *types.User
+	user.Role = RoleGuest
+	if json.Invitation != "" {
+		u, _, err = db.Invitations.CreateUserFromInvitation(hashInvitationToken(json.Invitation), user)
+	} else {
+		user.Pending = registrationApproval()
+		u, err = db.Users.CreateUser(user)
+	}
+	if err == data.ErrInvitationInvalid {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    http.StatusForbidden,
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "error creating user",
@@ -68,9 +128,14 @@ func registerHandler(c *gin.Context) {
 		return
 	}
 
+	message := "user created"
+	if u.Pending {
+		message = "user pending approval"
+	}
 	c.JSON(200, gin.H{
 		"user":    u.Username,
-		"message": "user created",
+		"pending": u.Pending,
+		"message": message,
 	})
 }
 
@@ -119,10 +184,18 @@ func updateUserProfileHandler(c *gin.Context) {
 	if json.Username != "" {
 		u.Username = json.Username
 	}
+	revoke := u.Username != username || json.NewPassword != ""
 
 	// Create new password hash if new_password is provided.
 	if json.NewPassword != "" {
-		hash, err := bcrypt.GenerateFromPassword([]byte(json.NewPassword), bcrypt.MinCost)
+		if err := validatePassword(u.Username, json.NewPassword); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    http.StatusBadRequest,
+				"message": err.Error(),
+			})
+			return
+		}
+		hash, err := hashPassword(json.NewPassword)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"code":    http.StatusBadRequest,
@@ -130,7 +203,7 @@ func updateUserProfileHandler(c *gin.Context) {
 			})
 			return
 		}
-		u.Password = string(hash)
+		u.Password = hash
 	}
 
 	// Update the user.
@@ -143,6 +216,11 @@ func updateUserProfileHandler(c *gin.Context) {
 		return
 	}
 
+	// Revoke existing tokens on username or password changes.
+	if revoke {
+		revokeTokens(username)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"user":    username,
 		"message": "user updated",
@@ -152,6 +230,14 @@ func updateUserProfileHandler(c *gin.Context) {
 // updatePasswordHandler handles the request to update a user password if the user
 // credentials are validated.
 func updatePasswordHandler(c *gin.Context) {
+	if config.Get().DisableLocalLogin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    http.StatusForbidden,
+			"message": errLocalLoginDisabled.Error(),
+		})
+		return
+	}
+
 	// Decode json.
 	var json userProfileUpdateRequest
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -162,13 +248,21 @@ func updatePasswordHandler(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts locked out by failed logins of the username or IP.
+	if checkLoginLockout(c, json.Username) {
+		respondLoginLocked(c)
+		return
+	}
+
 	// Get user from DB.
 	db := data.New()
-	u, _ := db.Users.GetUserByUsername(json.Username)
-
-	// Verify user credentials.
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(json.CurrentPassword))
+	u, err := db.Users.GetUserByUsername(json.Username)
+	if err == nil {
+		// Verify user credentials.
+		err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(json.CurrentPassword))
+	}
 	if err != nil {
+		countLoginFailure(c, json.Username)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code":    http.StatusUnauthorized,
 			"message": "unauthorized",
@@ -178,7 +272,14 @@ func updatePasswordHandler(c *gin.Context) {
 
 	// Create new password hash if new_password is provided.
 	if json.NewPassword != "" {
-		hash, err := bcrypt.GenerateFromPassword([]byte(json.NewPassword), bcrypt.MinCost)
+		if err := validatePassword(u.Username, json.NewPassword); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    http.StatusBadRequest,
+				"message": err.Error(),
+			})
+			return
+		}
+		hash, err := hashPassword(json.NewPassword)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"code":    http.StatusBadRequest,
@@ -186,7 +287,7 @@ func updatePasswordHandler(c *gin.Context) {
 			})
 			return
 		}
-		u.Password = string(hash)
+		u.Password = hash
 	}
 
 	// Update the user.
@@ -198,6 +299,8 @@ func updatePasswordHandler(c *gin.Context) {
 		})
 		return
 	}
+	resetLoginFailures(u.Username)
+	revokeTokens(u.Username)
 
 	c.JSON(http.StatusOK, gin.H{
 		"user":    u.Username,
@@ -207,14 +310,6 @@ func updatePasswordHandler(c *gin.Context) {
 
 // getUsersHandler handles the request to get all users for user management.
 func getUsersHandler(c *gin.Context) {
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdmin(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
-
 	page := c.DefaultQuery("page", "1")
 	count := c.DefaultQuery("count", "10")
 	pageInt, _ := strconv.Atoi(page)
@@ -252,13 +347,6 @@ func getUsersHandler(c *gin.Context) {
 // updateUserByIDHandler handles the request update a user for user management.
 func updateUserByIDHandler(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	user, _ := c.Get(JwtIdentityKey)
-
-	// Role check.
-	if !isAdmin(user) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "unauthorized"})
-		return
-	}
 
 	// Decode json.
 	var json userUpdateRequest
@@ -267,6 +355,14 @@ func updateUserByIDHandler(c *gin.Context) {
 		return
 	}
 
+	if _, ok := getRole(json.Role); json.Role != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "role does not exist",
+		})
+		return
+	}
+
 	db := data.New()
 	u, err := db.Users.GetUserByID(id)
 	if err != nil {
@@ -277,17 +373,91 @@ func updateUserByIDHandler(c *gin.Context) {
 		return
 	}
 
+	before := map[string]interface{}{"role": u.Role, "active": u.Active}
+
 	// Disallow updates on master user.
+	revoke := false
 	if id != 1 {
 		// Set role.
-		if json.Role != "" {
+		if json.Role != "" && json.Role != u.Role {
 			u.Role = json.Role
+			revoke = true
 		}
 
-		// Set active status.
+		// Set active status. Activating a pending user approves them.
+		if u.Active && !json.Active {
+			revoke = true
+		}
 		u.Active = json.Active
+		if u.Active {
+			u.Pending = false
+		}
 	}
 
-	updatedUser, _ := db.Users.UpdateUserByID(id, u)
+	updatedUser, err := db.Users.UpdateUserByID(id, u)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating user",
+		})
+		return
+	}
+
+	// Revoke existing tokens on role changes and deactivation.
+	if revoke {
+		revokeTokens(u.Username)
+	}
+
+	after := map[string]interface{}{"role": u.Role, "active": u.Active}
+	recordAudit(c, types.AuditUserUpdate, "user", u.Username, auditDiff(before, after, nil))
 	c.JSON(http.StatusOK, updatedUser)
 }
+
+// approveUserHandler handles the request to approve a user pending approval
+// after registering.
+func approveUserHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	u, err := db.Users.GetUserByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "User does not exist",
+		})
+		return
+	}
+	if !u.Pending {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "user is not pending approval",
+		})
+		return
+	}
+
+	u.Pending = false
+	u.Active = true
+	updatedUser, err := db.Users.UpdateUserByID(id, u)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating user",
+		})
+		return
+	}
+	recordAudit(c, types.AuditUserApprove, "user", u.Username, types.AuditChanges{
+		"active": {Before: false, After: true},
+	})
+	c.JSON(http.StatusOK, updatedUser)
+}
+
+// randomPasswordHash returns the hash of a random password, for users who
+// don't log in with a password.
+func randomPasswordHash() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hashPassword(hex.EncodeToString(b))
+}
@@ -0,0 +1,364 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alfg/openencoder/api/config"
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/helpers"
+	"github.com/alfg/openencoder/api/net"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gin-gonic/gin"
+	"github.com/jlaffaye/ftp"
+	"github.com/rs/xid"
+)
+
+type watcherRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Profile     string   `json:"profile"`
+	Prefix      string   `json:"prefix"`
+	Glob        string   `json:"glob"`
+	Presets     []string `json:"presets" binding:"required,min=1"`
+	Destination string   `json:"dest" binding:"required"`
+	DestProfile string   `json:"dest_profile"`
+	Active      *bool    `json:"active" binding:"required"`
+}
+
+// watchedObject describes an object found by a watcher scan.
+type watchedObject struct {
+	Key  string
+	ETag string
+}
+
+func getWatchersHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var watchers *[]types.Watcher
+	var watchersCount int
+
+	orgID := contextOrgID(c)
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		watchers = db.Watchers.GetWatchers(orgID, (pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		watchersCount = db.Watchers.GetWatchersCount(orgID)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    watchersCount,
+		"watchers": watchers,
+	})
+}
+
+func getWatcherByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	watcher, err := db.Watchers.GetWatcherByID(contextOrgID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Watcher does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"watcher": watcher,
+	})
+}
+
+func createWatcherHandler(c *gin.Context) {
+	// Decode json.
+	var json watcherRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	watcher := watcherFromRequest(json)
+	watcher.OrgID = contextOrgID(c)
+	if err := validateWatcher(watcher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	db := data.New()
+	created, err := db.Watchers.CreateWatcher(watcher)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating watcher",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"watcher": created,
+	})
+}
+
+func updateWatcherByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json watcherRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := data.New()
+	if _, err := db.Watchers.GetWatcherByID(contextOrgID(c), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Watcher does not exist",
+		})
+		return
+	}
+
+	watcher := watcherFromRequest(json)
+	watcher.OrgID = contextOrgID(c)
+	if err := validateWatcher(watcher); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	updated, err := db.Watchers.UpdateWatcherByID(id, watcher)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating watcher",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteWatcherByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if _, err := db.Watchers.GetWatcherByID(contextOrgID(c), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Watcher does not exist",
+		})
+		return
+	}
+	if err := db.Watchers.DeleteWatcherByID(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error deleting watcher",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"deleted": true,
+	})
+}
+
+func watcherFromRequest(json watcherRequest) types.Watcher {
+	glob := json.Glob
+	if glob == "" {
+		glob = "*"
+	}
+
+	return types.Watcher{
+		Name:        json.Name,
+		Profile:     json.Profile,
+		Prefix:      json.Prefix,
+		Glob:        glob,
+		Presets:     json.Presets,
+		Destination: json.Destination,
+		DestProfile: json.DestProfile,
+		Active:      *json.Active,
+	}
+}
+
+func validateWatcher(w types.Watcher) error {
+	if _, err := path.Match(w.Glob, ""); err != nil {
+		return fmt.Errorf("invalid glob: %s", w.Glob)
+	}
+	if err := helpers.ValidateTemplate(w.Destination); err != nil {
+		return err
+	}
+	return validateStorageProfiles(w.OrgID, w.Profile, w.DestProfile)
+}
+
+// startWatchers scans the active watchers on the configured interval.
+func startWatchers() {
+	interval := time.Duration(config.Get().WatcherInterval) * time.Second
+	if interval <= 0 {
+		log.Info("watchers disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		runWatchers()
+	}
+}
+
+func runWatchers() {
+	db := data.New()
+	watchers, err := db.Watchers.GetActiveWatchers()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, w := range *watchers {
+		jobs, err := scanWatcher(w)
+		if err != nil {
+			log.Errorf("watcher %s: %s", w.Name, err)
+			db.Watchers.UpdateWatcherScanByID(w.ID, types.WatcherScanError, err.Error(), jobs)
+			continue
+		}
+		db.Watchers.UpdateWatcherScanByID(w.ID, types.WatcherScanOK, "", jobs)
+	}
+}
+
+// scanWatcher lists the watched prefix and creates jobs for new objects.
+// Each preset of an object is recorded by key and ETag before its job is
+// created, so each version of an object creates a job per preset exactly
+// once, even if a scan fails part way through its presets.
+func scanWatcher(w types.Watcher) (int, error) {
+	objects, err := listWatchedObjects(w)
+	if err != nil {
+		return 0, err
+	}
+
+	db := data.New()
+	created := 0
+	for _, obj := range objects {
+		if match, _ := path.Match(w.Glob, path.Base(obj.Key)); !match {
+			continue
+		}
+
+		for _, preset := range w.Presets {
+			job := types.Job{
+				GUID:          xid.New().String(),
+				OrgID:         w.OrgID,
+				Preset:        preset,
+				Source:        obj.Key,
+				Destination:   w.Destination,
+				SourceProfile: w.Profile,
+				DestProfile:   w.DestProfile,
+				SourceETag:    obj.ETag,
+				Status:        types.JobQueued,
+			}
+
+			isNew, err := db.Watchers.CreateWatcherObject(w.ID, obj.Key, obj.ETag, preset)
+			if err != nil {
+				return created, err
+			}
+			if !isNew {
+				continue
+			}
+
+			// Stop the scan over the quotas of the organization, and forget
+			// the preset of the object so a later scan picks it up.
+			exceeded, err := checkQuotas(w.OrgID, "", 1, []types.Job{job})
+			if exceeded != nil || err != nil {
+				db.Watchers.DeleteWatcherObject(w.ID, obj.Key, obj.ETag, preset)
+				if err != nil {
+					return created, err
+				}
+				return created, exceeded
+			}
+
+			log.Infof("watcher %s: found %s for %s", w.Name, obj.Key, preset)
+			if j, err := createJob(job); err != nil {
+				// Fail the job that wasn't queued, and forget the preset of
+				// the object so it is retried on the next scan.
+				if j != nil {
+					db.Jobs.UpdateJobStatusByGUID(j.GUID, types.JobError)
+				}
+				db.Watchers.DeleteWatcherObject(w.ID, obj.Key, obj.ETag, preset)
+				return created, err
+			}
+			created++
+		}
+	}
+	return created, nil
+}
+
+func listWatchedObjects(w types.Watcher) ([]watchedObject, error) {
+	storage, err := net.GetStorage(w.OrgID, w.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []watchedObject{}
+	if storage.Driver == types.StorageS3 {
+		s3 := net.NewS3(storage.S3)
+		list, err := s3.ListObjects(w.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			key := aws.StringValue(item.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			objects = append(objects, watchedObject{
+				Key:  key,
+				ETag: strings.Trim(aws.StringValue(item.ETag), `"`),
+			})
+		}
+		return objects, nil
+	} else if storage.Driver == types.StorageFTP {
+		f := net.NewFTP(storage.FTP.Addr, storage.FTP.Username, storage.FTP.Password)
+		list, err := f.ListFiles(w.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list {
+			if item.Type != ftp.EntryTypeFile {
+				continue
+			}
+
+			// FTP has no ETag, so use the size and modified time.
+			objects = append(objects, watchedObject{
+				Key:  path.Join(w.Prefix, item.Name),
+				ETag: fmt.Sprintf("%d-%d", item.Size, item.Time.Unix()),
+			})
+		}
+		return objects, nil
+	}
+	return nil, errors.New("no driver set")
+}
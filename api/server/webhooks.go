@@ -0,0 +1,250 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/alfg/openencoder/api/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events" binding:"required,min=1"`
+	Active *bool    `json:"active" binding:"required"`
+}
+
+func getWebhooksHandler(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var webhooks *[]types.Webhook
+	var webhooksCount int
+
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		webhooks = db.Webhooks.GetWebhooks((pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		webhooksCount = db.Webhooks.GetWebhooksCount()
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    webhooksCount,
+		"webhooks": webhooks,
+	})
+}
+
+func getWebhookByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	w, err := db.Webhooks.GetWebhookByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Webhook does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"webhook": w,
+	})
+}
+
+func createWebhookHandler(c *gin.Context) {
+	// Decode json.
+	var json webhookRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateWebhook(json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Generate a secret if not provided.
+	secret := json.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.GenerateSecret(); err != nil {
+			log.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error creating webhook",
+			})
+			return
+		}
+	}
+
+	w := types.Webhook{
+		URL:    json.URL,
+		Secret: secret,
+		Events: json.Events,
+		Active: *json.Active,
+	}
+
+	db := data.New()
+	created, err := db.Webhooks.CreateWebhook(w)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error creating webhook",
+		})
+		return
+	}
+
+	// The secret is only returned on create.
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"webhook": created,
+		"secret":  created.Secret,
+	})
+}
+
+func updateWebhookByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	// Decode json.
+	var json webhookRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateWebhook(json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	db := data.New()
+	w, err := db.Webhooks.GetWebhookByID(int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Webhook does not exist",
+		})
+		return
+	}
+
+	// Keep the existing secret if not provided.
+	w.URL = json.URL
+	w.Events = json.Events
+	w.Active = *json.Active
+	if json.Secret != "" {
+		w.Secret = json.Secret
+	}
+
+	updated, err := db.Webhooks.UpdateWebhookByID(int64(id), *w)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error updating webhook",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func deleteWebhookByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	db := data.New()
+	if err := db.Webhooks.DeleteWebhookByID(int64(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error deleting webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"deleted": true,
+	})
+}
+
+func getWebhookDeliveriesHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var deliveries *[]types.WebhookDelivery
+	var deliveriesCount int
+
+	db := data.New()
+	wg.Add(1)
+	go func() {
+		deliveries = db.Webhooks.GetWebhookDeliveries(int64(id), (pageInt-1)*countInt, countInt)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		deliveriesCount = db.Webhooks.GetWebhookDeliveriesCount(int64(id))
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":      deliveriesCount,
+		"deliveries": deliveries,
+	})
+}
+
+func validateWebhook(json webhookRequest) error {
+	if !isHTTPURL(json.URL) {
+		return fmt.Errorf("invalid url: %s", json.URL)
+	}
+	for _, e := range json.Events {
+		if !isJobEvent(e) {
+			return fmt.Errorf("invalid event: %s", e)
+		}
+	}
+	return nil
+}
+
+func isJobEvent(event string) bool {
+	for _, e := range types.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
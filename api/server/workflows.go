@@ -0,0 +1,438 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/helpers"
+	"github.com/alfg/openencoder/api/stream"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/xid"
+)
+
+// WorkflowInterval is the interval waiting jobs are checked, in case a
+// status update was missed.
+const WorkflowInterval = 30 * time.Second
+
+// workflowMu serializes resolving the waiting jobs.
+var workflowMu sync.Mutex
+
+type workflowRequest struct {
+	Name  string         `json:"name" binding:"required"`
+	Steps []workflowStep `json:"steps" binding:"required,min=1,dive"`
+}
+
+// workflowStep describes a workflow step. DependsOn and SourceFrom refer to
+// the names of previous steps.
+type workflowStep struct {
+	Name          string   `json:"name" binding:"required"`
+	Preset        string   `json:"preset" binding:"required"`
+	Source        string   `json:"source"`
+	SourceFrom    string   `json:"source_from"`
+	Destination   string   `json:"dest" binding:"required"`
+	SourceProfile string   `json:"source_profile"`
+	DestProfile   string   `json:"dest_profile"`
+	DependsOn     []string `json:"depends_on"`
+}
+
+func getWorkflowsHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+	page := c.DefaultQuery("page", "1")
+	count := c.DefaultQuery("count", "10")
+	pageInt, _ := strconv.Atoi(page)
+	countInt, _ := strconv.Atoi(count)
+
+	if page == "0" {
+		pageInt = 1
+	}
+
+	var wg sync.WaitGroup
+	var workflows *[]types.Workflow
+	var workflowsCount int
+
+	db := data.New()
+	orgID := userOrgID(user)
+	owner := jobOwner(user)
+	wg.Add(1)
+	go func() {
+		workflows = db.Workflows.GetWorkflows(orgID, (pageInt-1)*countInt, countInt, owner)
+		wg.Done()
+	}()
+
+	wg.Add(1)
+	go func() {
+		workflowsCount = db.Workflows.GetWorkflowsCount(orgID, owner)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":     workflowsCount,
+		"workflows": workflows,
+	})
+}
+
+func getWorkflowByIDHandler(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, _ := c.Get(JwtIdentityKey)
+
+	db := data.New()
+	workflow, err := db.Workflows.GetWorkflowByID(userOrgID(user), int64(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Workflow does not exist",
+		})
+		return
+	}
+
+	if owner := jobOwner(user); owner != "" && workflow.CreatedBy != owner {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Workflow does not exist",
+		})
+		return
+	}
+
+	jobs, err := db.Jobs.GetJobsByWorkflowID(workflow.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error getting workflow jobs",
+		})
+		return
+	}
+	workflow.Jobs = *jobs
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"workflow": workflow,
+	})
+}
+
+func createWorkflowHandler(c *gin.Context) {
+	user, _ := c.Get(JwtIdentityKey)
+
+	// Decode json.
+	var json workflowRequest
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID := userOrgID(user)
+	if err := validateWorkflowSteps(orgID, json.Steps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Refuse workflows over the quotas of the organization or user.
+	username := user.(*types.User).Username
+	sources := []types.Job{}
+	for _, step := range json.Steps {
+		if step.Source != "" {
+			sources = append(sources, types.Job{OrgID: orgID, Source: step.Source, SourceProfile: step.SourceProfile})
+		}
+	}
+	exceeded, err := checkQuotas(orgID, username, len(json.Steps), sources)
+	if err != nil {
+		log.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "error checking quotas: " + err.Error(),
+		})
+		return
+	}
+	if exceeded != nil {
+		respondQuotaExceeded(c, exceeded)
+		return
+	}
+
+	db := data.New()
+	workflow, err := db.Workflows.CreateWorkflow(types.Workflow{
+		Name:      json.Name,
+		CreatedBy: username,
+		OrgID:     orgID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "error creating workflow",
+		})
+		return
+	}
+
+	// Create the steps in order, so dependencies are created first.
+	stepIDs := map[string]int64{}
+	for _, step := range json.Steps {
+		job := types.Job{
+			GUID:          xid.New().String(),
+			OrgID:         orgID,
+			Preset:        step.Preset,
+			Source:        step.Source,
+			Destination:   step.Destination,
+			SourceProfile: step.SourceProfile,
+			DestProfile:   step.DestProfile,
+			CreatedBy:     username,
+			WorkflowID:    workflow.ID,
+			WorkflowStep:  step.Name,
+			Status:        types.JobQueued,
+		}
+		for _, name := range step.DependsOn {
+			job.DependsOn = append(job.DependsOn, stepIDs[name])
+		}
+		if step.SourceFrom != "" {
+			job.SourceFrom = stepIDs[step.SourceFrom]
+			if !containsID(job.DependsOn, job.SourceFrom) {
+				job.DependsOn = append(job.DependsOn, job.SourceFrom)
+			}
+		}
+		if len(job.DependsOn) > 0 {
+			job.Status = types.JobWaiting
+		}
+
+		// Fail the workflow if a step can't be queued, cancelling the
+		// steps already created so none run without the others.
+		created, err := createJob(job)
+		if err != nil {
+			log.Error(err)
+			if created != nil {
+				workflow.Jobs = append(workflow.Jobs, *created)
+			}
+			cancelWorkflowJobs(workflow.Jobs)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "error queueing workflow step: " + step.Name,
+			})
+			return
+		}
+		stepIDs[step.Name] = created.ID
+		workflow.Jobs = append(workflow.Jobs, *created)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"workflow": workflow,
+	})
+}
+
+// cancelWorkflowJobs cancels the jobs of a workflow that failed to be created.
+func cancelWorkflowJobs(jobs []types.Job) {
+	db := data.New()
+	for _, job := range jobs {
+		if err := db.Jobs.UpdateJobStatusByID(int(job.ID), types.JobCancelled); err != nil {
+			log.Error(err)
+			continue
+		}
+		stream.Status(job.GUID, types.JobCancelled)
+		sendEvent(types.EventJobCancelled, job.GUID)
+	}
+}
+
+// validateWorkflowSteps checks the step names are unique and only refer to
+// previous steps, so the steps cannot form a cycle.
+func validateWorkflowSteps(orgID int64, steps []workflowStep) error {
+	previous := map[string]bool{}
+	for _, step := range steps {
+		if previous[step.Name] {
+			return fmt.Errorf("duplicate step: %s", step.Name)
+		}
+		if (step.Source == "") == (step.SourceFrom == "") {
+			return fmt.Errorf("step %s: set either source or source_from", step.Name)
+		}
+		if step.SourceFrom != "" && step.SourceProfile != "" {
+			return fmt.Errorf("step %s: source_profile can't be set with source_from", step.Name)
+		}
+
+		refs := append([]string{}, step.DependsOn...)
+		if step.SourceFrom != "" {
+			refs = append(refs, step.SourceFrom)
+		}
+		for _, ref := range refs {
+			if !previous[ref] {
+				return fmt.Errorf("step %s: unknown previous step: %s", step.Name, ref)
+			}
+		}
+
+		if err := helpers.ValidateTemplate(step.Destination); err != nil {
+			return fmt.Errorf("step %s: %s", step.Name, err)
+		}
+		if err := validateStorageProfiles(orgID, step.SourceProfile, step.DestProfile); err != nil {
+			return fmt.Errorf("step %s: %s", step.Name, err)
+		}
+		previous[step.Name] = true
+	}
+	return nil
+}
+
+// jobDependencies checks the jobs a job depends on exist and are visible to
+// the user, and gets the dependency IDs including the source job.
+func jobDependencies(user interface{}, dependsOn []int64, sourceFrom int64) ([]int64, error) {
+	ids := append([]int64{}, dependsOn...)
+	if sourceFrom != 0 && !containsID(ids, sourceFrom) {
+		ids = append(ids, sourceFrom)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	db := data.New()
+	jobs, err := db.Jobs.GetJobsByIDs(ids)
+	if err != nil {
+		return nil, errors.New("error getting dependencies")
+	}
+
+	owner := jobOwner(user)
+	found := map[int64]bool{}
+	for _, j := range *jobs {
+		if j.OrgID == userOrgID(user) && (owner == "" || j.CreatedBy == owner) {
+			found[j.ID] = true
+		}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("dependency does not exist: %d", id)
+		}
+	}
+	return ids, nil
+}
+
+func startWorkflows() {
+	updates := hub.Subscribe("")
+	ticker := time.NewTicker(WorkflowInterval)
+
+	resolveWaitingJobs()
+	for {
+		select {
+		case u := <-updates:
+			// Only finished jobs change the waiting jobs.
+			if u.Type != stream.UpdateStatus || !isJobFinished(u.Status) {
+				continue
+			}
+		case <-ticker.C:
+		}
+		resolveWaitingJobs()
+	}
+}
+
+// resolveWaitingJobs queues the waiting jobs whose dependencies completed,
+// and fails or cancels those with a failed or cancelled dependency. Repeats
+// until no jobs change, so failures cascade to the downstream jobs.
+func resolveWaitingJobs() {
+	workflowMu.Lock()
+	defer workflowMu.Unlock()
+
+	db := data.New()
+	for {
+		jobs, err := db.Jobs.GetWaitingJobs()
+		if err != nil {
+			return
+		}
+
+		changed := false
+		for _, job := range *jobs {
+			if resolveWaitingJob(job) {
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// resolveWaitingJob updates a waiting job once its dependencies finished.
+// Returns true if the job was updated.
+func resolveWaitingJob(job types.Job) bool {
+	db := data.New()
+	parents, err := db.Jobs.GetJobsByIDs(job.DependsOn)
+	if err != nil {
+		return false
+	}
+	byID := map[int64]types.Job{}
+	for _, p := range *parents {
+		byID[p.ID] = p
+	}
+
+	// A failed or missing dependency fails the job, then a cancelled one
+	// cancels it. Otherwise the job waits until all are completed.
+	failed, cancelled, waiting := false, false, false
+	for _, id := range job.DependsOn {
+		p, ok := byID[id]
+		switch {
+		case !ok || p.Status == types.JobError:
+			failed = true
+		case p.Status == types.JobCancelled:
+			cancelled = true
+		case p.Status != types.JobCompleted:
+			waiting = true
+		}
+	}
+
+	status := types.JobQueued
+	switch {
+	case failed:
+		status = types.JobError
+	case cancelled:
+		status = types.JobCancelled
+	case waiting:
+		return false
+	}
+
+	// Use the output of the source job as the source, read from its
+	// destination storage.
+	source, sourceProfile := job.Source, job.SourceProfile
+	if status == types.JobQueued && job.SourceFrom != 0 {
+		p := byID[job.SourceFrom]
+		source = p.OutputDestination() + p.Output
+		sourceProfile = p.DestProfile
+	}
+
+	updated, err := db.Jobs.UpdateWaitingJobByID(job.ID, status, source, sourceProfile)
+	if err != nil || !updated {
+		return false
+	}
+	stream.Status(job.GUID, status)
+
+	switch status {
+	case types.JobQueued:
+		job.Source = source
+		job.SourceProfile = sourceProfile
+		if err := enqueueJob(job); err != nil {
+			log.Error(err)
+			db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+			stream.Status(job.GUID, types.JobError)
+			sendEvent(types.EventJobFailed, job.GUID)
+			return true
+		}
+		sendEvent(types.EventJobQueued, job.GUID)
+	case types.JobError:
+		sendEvent(types.EventJobFailed, job.GUID)
+	case types.JobCancelled:
+		sendEvent(types.EventJobCancelled, job.GUID)
+	}
+	return true
+}
+
+// isJobFinished checks a job status is final.
+func isJobFinished(status string) bool {
+	return status == types.JobCompleted || status == types.JobError || status == types.JobCancelled
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package server
+
+import "testing"
+
+func TestValidateWorkflowSteps(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []workflowStep
+		valid bool
+	}{
+		{"single", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+		}, true},
+		{"chained", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+			{Name: "b", SourceFrom: "a", Destination: "s3:///out/{source_basename}/"},
+			{Name: "c", Source: "s3:///in.mp4", Destination: "s3:///out/", DependsOn: []string{"a", "b"}},
+		}, true},
+		{"duplicate", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+		}, false},
+		{"no source", []workflowStep{
+			{Name: "a", Destination: "s3:///out/"},
+		}, false},
+		{"source and source_from", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+			{Name: "b", Source: "s3:///in.mp4", SourceFrom: "a", Destination: "s3:///out/"},
+		}, false},
+		{"source_profile with source_from", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+			{Name: "b", SourceFrom: "a", SourceProfile: "other", Destination: "s3:///out/"},
+		}, false},
+		{"later step", []workflowStep{
+			{Name: "a", SourceFrom: "b", Destination: "s3:///out/"},
+			{Name: "b", Source: "s3:///in.mp4", Destination: "s3:///out/"},
+		}, false},
+		{"self dependency", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/", DependsOn: []string{"a"}},
+		}, false},
+		{"unknown dependency", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/", DependsOn: []string{"x"}},
+		}, false},
+		{"invalid template", []workflowStep{
+			{Name: "a", Source: "s3:///in.mp4", Destination: "s3:///out/{nope}/"},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWorkflowSteps(1, tt.steps)
+			if (err == nil) != tt.valid {
+				t.Errorf("validateWorkflowSteps() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// SubscriberBuffer is the number of updates buffered per subscriber. Updates
+// to a subscriber with a full buffer are dropped.
+const SubscriberBuffer = 64
+
+// Hub receives the published updates and fans them out to subscribers.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan Update]string // Subscriber to GUID filter.
+}
+
+// NewHub creates a new hub.
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[chan Update]string),
+	}
+}
+
+// Subscribe returns a channel receiving the updates of a job, or of all
+// jobs if guid is empty.
+func (h *Hub) Subscribe(guid string) chan Update {
+	ch := make(chan Update, SubscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = guid
+	h.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a subscriber.
+func (h *Hub) Unsubscribe(ch chan Update) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+func (h *Hub) broadcast(u Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch, guid := range h.subscribers {
+		if guid != "" && guid != u.GUID {
+			continue
+		}
+		select {
+		case ch <- u:
+		default:
+		}
+	}
+}
+
+// Run subscribes to the published updates, reconnecting on errors.
+func (h *Hub) Run() {
+	for {
+		if err := h.receive(); err != nil {
+			log.Error(err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (h *Hub) receive() error {
+	// Use a dedicated connection, as it is held by the subscription.
+	conn, err := pool.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(Channel()); err != nil {
+		return err
+	}
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var u Update
+			if err := json.Unmarshal(v.Data, &u); err != nil {
+				log.Error(err)
+				continue
+			}
+			h.broadcast(u)
+		case error:
+			return v
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package stream
+
+import (
+	"encoding/json"
+
+	"github.com/alfg/openencoder/api/logging"
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	log       = logging.Log
+	pool      *redis.Pool
+	namespace string
+)
+
+// Update types.
+const (
+	UpdateStatus   = "status"
+	UpdateTransfer = "transfer"
+	UpdateEncode   = "encode"
+)
+
+// Update defines a live job update published by workers.
+type Update struct {
+	Type     string  `json:"type"`
+	GUID     string  `json:"guid"`
+	Status   string  `json:"status,omitempty"`
+	Progress float64 `json:"progress,omitempty"`
+	FPS      float64 `json:"fps,omitempty"`
+	Speed    string  `json:"speed,omitempty"`
+}
+
+// Init sets the redis pool and namespace updates are published with.
+func Init(p *redis.Pool, ns string) {
+	pool = p
+	namespace = ns
+}
+
+// Channel returns the redis channel updates are published to.
+func Channel() string {
+	return namespace + ":job-updates"
+}
+
+// Publish publishes an update. Updates are dropped if Init was not called.
+func Publish(u Update) {
+	if pool == nil {
+		return
+	}
+
+	b, err := json.Marshal(u)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PUBLISH", Channel(), b); err != nil {
+		log.Error(err)
+	}
+}
+
+// Status publishes a job status update.
+func Status(guid, status string) {
+	Publish(Update{Type: UpdateStatus, GUID: guid, Status: status})
+}
+
+// Transfer publishes a job transfer progress update.
+func Transfer(guid string, progress float64) {
+	Publish(Update{Type: UpdateTransfer, GUID: guid, Progress: progress})
+}
+
+// Encode publishes a job encode progress update.
+func Encode(guid string, progress float64, fps float64, speed string) {
+	Publish(Update{Type: UpdateEncode, GUID: guid, Progress: progress, FPS: fps, Speed: speed})
+}
@@ -0,0 +1,114 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 appendix B SHA-1 test vectors, truncated to 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestGenerate(t *testing.T) {
+	key, err := encoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range rfcVectors {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := generate(key, tt.unix/Period); got != tt.code {
+				t.Errorf("generate() = %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.code, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0))
+			if !ok {
+				t.Fatal("Validate() = false, want true")
+			}
+			if step != tt.unix/Period {
+				t.Errorf("step = %d, want %d", step, tt.unix/Period)
+			}
+		})
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	const unix, code = 1111111111, "050471" // Step 37037037.
+	step := int64(unix / Period)
+
+	tests := []struct {
+		name    string
+		secret  string
+		code    string
+		offset  int64 // Periods from the code's step.
+		valid   bool
+		matched int64
+	}{
+		{"current", rfcSecret, code, 0, true, step},
+		{"one before", rfcSecret, code, -Skew, true, step},
+		{"one after", rfcSecret, code, Skew, true, step},
+		{"two before", rfcSecret, code, -Skew - 1, false, 0},
+		{"two after", rfcSecret, code, Skew + 1, false, 0},
+		{"lower case secret", strings.ToLower(rfcSecret), code, 0, true, step},
+		{"wrong code", rfcSecret, "050472", 0, false, 0},
+		{"short", rfcSecret, "05047", 0, false, 0},
+		{"long", rfcSecret, "0504710", 0, false, 0},
+		{"8 digits", rfcSecret, "14050471", 0, false, 0},
+		{"empty", rfcSecret, "", 0, false, 0},
+		{"invalid secret", "not base32!", code, 0, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := time.Unix(unix+tt.offset*Period, 0)
+			matched, ok := Validate(tt.secret, tt.code, at)
+			if ok != tt.valid {
+				t.Fatalf("Validate() = %v, want %v", ok, tt.valid)
+			}
+			if matched != tt.matched {
+				t.Errorf("step = %d, want %d", matched, tt.matched)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != SecretSize {
+		t.Errorf("len(key) = %d, want %d", len(key), SecretSize)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("openencoder", "alice", rfcSecret)
+	want := "otpauth://totp/openencoder:alice?algorithm=SHA1&digits=6&issuer=openencoder&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}
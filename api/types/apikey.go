@@ -0,0 +1,26 @@
+package types
+
+// APIKeyPrefix is prepended to generated API keys to make them recognizable.
+const APIKeyPrefix = "oe_"
+
+// APIKey defines a long-lived API key of a service account user.
+// Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	ID           int64      `db:"id" json:"id,omitempty"`
+	UserID       int64      `db:"user_id" json:"user_id"`
+	Name         string     `db:"name" json:"name"`
+	Prefix       string     `db:"prefix" json:"prefix"`
+	KeyHash      string     `db:"key_hash" json:"-"`
+	CreatedBy    string     `db:"created_by" json:"created_by"`
+	CreatedDate  string     `db:"created_date" json:"created_date"`
+	LastUsedDate NullString `db:"last_used_date" json:"last_used_date"`
+	ExpiresDate  NullString `db:"expires_date" json:"expires_date"`
+	Revoked      bool       `db:"revoked" json:"revoked"`
+	RateLimit    int        `db:"rate_limit" json:"rate_limit"` // Requests per minute, 0 uses the default.
+	OrgID        int64      `db:"org_id" json:"org_id"`
+
+	// Service account user.
+	Username string `db:"username" json:"username"`
+	Role     string `db:"role" json:"role"`
+	Active   bool   `db:"active" json:"active"`
+}
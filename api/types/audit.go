@@ -0,0 +1,77 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Audited actions.
+const (
+	AuditLogin            = "login"
+	AuditSettingsUpdate   = "settings.update"
+	AuditUserUpdate       = "user.update"
+	AuditUserApprove      = "user.approve"
+	AuditInvitationCreate = "invitation.create"
+	AuditInvitationRevoke = "invitation.revoke"
+	AuditPresetCreate     = "preset.create"
+	AuditPresetUpdate     = "preset.update"
+	AuditStorageCreate    = "storage_profile.create"
+	AuditStorageUpdate    = "storage_profile.update"
+	AuditStorageDelete    = "storage_profile.delete"
+	AuditMachineCreate    = "machine.create"
+	AuditMachineDelete    = "machine.delete"
+	AuditMachineDeleteAll = "machine.delete_all"
+	AuditJobCancel        = "job.cancel"
+	AuditJobRestart       = "job.restart"
+)
+
+// AuditRedacted replaces the values of secrets in audit changes.
+const AuditRedacted = "[redacted]"
+
+// AuditEntry defines an entry of the append-only audit log. Actor is the
+// username of the user who acted, or tried to log in.
+type AuditEntry struct {
+	ID          int64        `db:"id" json:"id"`
+	CreatedDate string       `db:"created_date" json:"created_date"`
+	Actor       string       `db:"actor" json:"actor"`
+	OrgID       NullInt64    `db:"org_id" json:"org_id"`
+	IP          string       `db:"ip" json:"ip"`
+	Action      string       `db:"action" json:"action"`
+	TargetType  string       `db:"target_type" json:"target_type"`
+	TargetID    string       `db:"target_id" json:"target_id"`
+	Success     bool         `db:"success" json:"success"`
+	Message     string       `db:"message" json:"message"`
+	Changes     AuditChanges `db:"changes" json:"changes"`
+}
+
+// AuditChange defines the values of a field before and after an action.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges defines the changed fields of an action, stored as JSON.
+type AuditChanges map[string]AuditChange
+
+// Value implements driver.Valuer.
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner.
+func (c *AuditChanges) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return errors.New("invalid audit changes")
+}
@@ -0,0 +1,19 @@
+package types
+
+import "github.com/lib/pq"
+
+// IngestRule defines a rule for creating jobs from S3 event notifications.
+type IngestRule struct {
+	ID            int64          `db:"id" json:"id,omitempty"`
+	Name          string         `db:"name" json:"name"`
+	Bucket        string         `db:"bucket" json:"bucket"`
+	Prefix        string         `db:"prefix" json:"prefix"`
+	Glob          string         `db:"glob" json:"glob"`
+	Presets       pq.StringArray `db:"presets" json:"presets"`
+	SourceProfile string         `db:"source_profile" json:"source_profile"`
+	Destination   string         `db:"destination" json:"dest"`
+	DestProfile   string         `db:"dest_profile" json:"dest_profile"`
+	Active        bool           `db:"active" json:"active"`
+	CreatedDate   string         `db:"created_date" json:"created_date"`
+	OrgID         int64          `db:"org_id" json:"org_id"`
+}
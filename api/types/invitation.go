@@ -0,0 +1,17 @@
+package types
+
+// Invitation defines a single-use invitation to register a user with a role
+// in an organization. Only the SHA-256 hash of the token is stored.
+type Invitation struct {
+	ID          int64      `db:"id" json:"id,omitempty"`
+	TokenHash   string     `db:"token_hash" json:"-"`
+	Email       string     `db:"email" json:"email"`
+	Role        string     `db:"role" json:"role"`
+	OrgID       int64      `db:"org_id" json:"org_id"`
+	CreatedBy   string     `db:"created_by" json:"created_by"`
+	CreatedDate string     `db:"created_date" json:"created_date"`
+	ExpiresDate string     `db:"expires_date" json:"expires_date"`
+	UsedBy      string     `db:"used_by" json:"used_by"`
+	UsedDate    NullString `db:"used_date" json:"used_date"`
+	Revoked     bool       `db:"revoked" json:"revoked"`
+}
@@ -3,6 +3,8 @@ package types
 import (
 	"database/sql"
 	"encoding/json"
+
+	"github.com/lib/pq"
 )
 
 // Job status types.
@@ -16,6 +18,7 @@ const (
 	JobError       = "error"
 	JobCancelled   = "cancelled"
 	JobRestarting  = "restarting"
+	JobWaiting     = "waiting"
 )
 
 // JobStatuses All job status types.
@@ -29,6 +32,7 @@ var JobStatuses = []string{
 	JobError,
 	JobCancelled,
 	JobRestarting,
+	JobWaiting,
 }
 
 // Job describes the job info.
@@ -40,6 +44,28 @@ type Job struct {
 	Status      string `db:"status" json:"status"`
 	Source      string `db:"source" json:"source"`
 	Destination string `db:"destination" json:"destination"`
+	Output      string `db:"output" json:"output"`
+	CreatedBy   string `db:"created_by" json:"created_by"`
+	SourceETag  string `db:"source_etag" json:"source_etag"`
+	OrgID       int64  `db:"org_id" json:"org_id"`
+
+	// Destination expanded from the Destination template on each run. The
+	// Destination is kept as submitted, so restarts expand it again.
+	ResolvedDestination string `db:"resolved_destination" json:"resolved_destination"`
+
+	// Storage profiles.
+	SourceProfile string `db:"source_profile" json:"source_profile"`
+	DestProfile   string `db:"dest_profile" json:"dest_profile"`
+
+	// URL to deliver job events to.
+	CallbackURL string `db:"callback_url" json:"callback_url"`
+
+	// Dependencies. A waiting job is queued once the jobs it depends on are
+	// completed, and its source is set to the output of SourceFrom if set.
+	WorkflowID   int64         `db:"workflow_id" json:"workflow_id"`
+	WorkflowStep string        `db:"workflow_step" json:"workflow_step"`
+	DependsOn    pq.Int64Array `db:"depends_on" json:"depends_on"`
+	SourceFrom   int64         `db:"source_from" json:"source_from"`
 
 	// EncodeData.
 	Encode `db:"encode"`
@@ -49,15 +75,25 @@ type Job struct {
 	Streaming        bool   `json:"streaming"`
 }
 
+// OutputDestination gets the destination the outputs are uploaded to: the
+// resolved destination, or the Destination if it wasn't resolved yet.
+func (j Job) OutputDestination() string {
+	if j.ResolvedDestination != "" {
+		return j.ResolvedDestination
+	}
+	return j.Destination
+}
+
 // Encode describes the encode data.
 type Encode struct {
-	EncodeID int64       `db:"id" json:"-"`
-	JobID    int64       `db:"job_id" json:"-"`
-	Probe    NullString  `db:"probe" json:"probe,omitempty"`
-	Options  NullString  `db:"options" json:"options,omitempty"`
-	Progress NullFloat64 `db:"progress" json:"progress,omitempty"`
-	Speed    NullString  `db:"speed" json:"speed"`
-	FPS      NullFloat64 `db:"fps" json:"fps"`
+	EncodeID  int64       `db:"id" json:"-"`
+	JobID     int64       `db:"job_id" json:"-"`
+	Probe     NullString  `db:"probe" json:"probe,omitempty"`
+	Options   NullString  `db:"options" json:"options,omitempty"`
+	Progress  NullFloat64 `db:"progress" json:"progress,omitempty"`
+	Speed     NullString  `db:"speed" json:"speed"`
+	FPS       NullFloat64 `db:"fps" json:"fps"`
+	Checksums NullString  `db:"checksums" json:"checksums,omitempty"`
 }
 
 // NullString is an alias for sql.NullString data type
@@ -0,0 +1,45 @@
+package types
+
+import "github.com/lib/pq"
+
+// Notification channel types.
+const (
+	NotifySlack   = "slack"
+	NotifyWebhook = "webhook"
+	NotifyTeams   = "teams"
+	NotifyDiscord = "discord"
+	NotifyEmail   = "email"
+)
+
+// NotificationChannelTypes All notification channel types.
+var NotificationChannelTypes = []string{
+	NotifySlack,
+	NotifyWebhook,
+	NotifyTeams,
+	NotifyDiscord,
+	NotifyEmail,
+}
+
+// NotificationChannel defines a channel notified of job events.
+type NotificationChannel struct {
+	ID          int64              `db:"id" json:"id,omitempty"`
+	Name        string             `db:"name" json:"name"`
+	Type        string             `db:"type" json:"type"`
+	Config      NotificationConfig `db:"-" json:"config"`
+	ConfigData  string             `db:"config" json:"-"` // Encrypted Config JSON.
+	Events      pq.StringArray     `db:"events" json:"events"`
+	Template    string             `db:"template" json:"template"`
+	Active      bool               `db:"active" json:"active"`
+	CreatedDate string             `db:"created_date" json:"created_date"`
+}
+
+// NotificationConfig defines the connection settings of a channel.
+type NotificationConfig struct {
+	URL          string   `json:"url,omitempty"`
+	SMTPHost     string   `json:"smtp_host,omitempty"`
+	SMTPPort     int      `json:"smtp_port,omitempty"`
+	SMTPUsername string   `json:"smtp_username,omitempty"`
+	SMTPPassword string   `json:"smtp_password,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+}
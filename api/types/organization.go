@@ -0,0 +1,34 @@
+package types
+
+// DefaultOrgID is the ID of the default organization. Instance settings are
+// stored with it, and new users are added to it.
+const DefaultOrgID = 1
+
+// Organization defines a tenant owning jobs, presets, settings, machines and
+// storage profiles, isolated from other organizations.
+type Organization struct {
+	ID           int64  `db:"id" json:"id,omitempty"`
+	Name         string `db:"name" json:"name"`
+	CreatedDate  string `db:"created_date" json:"created_date"`
+	MembersCount int    `db:"members_count" json:"members_count"`
+}
+
+// OrganizationMember defines a user's membership of an organization. Role is
+// the user's role in the organization. If empty, the user's role is used.
+type OrganizationMember struct {
+	OrgID       int64  `db:"org_id" json:"org_id"`
+	OrgName     string `db:"org_name" json:"org_name"`
+	UserID      int64  `db:"user_id" json:"user_id"`
+	Username    string `db:"username" json:"username"`
+	Role        string `db:"role" json:"role"`
+	UserRole    string `db:"user_role" json:"user_role"`
+	CreatedDate string `db:"created_date" json:"created_date"`
+}
+
+// EffectiveRole gets the member's role in the organization.
+func (m *OrganizationMember) EffectiveRole() string {
+	if m.Role != "" {
+		return m.Role
+	}
+	return m.UserRole
+}
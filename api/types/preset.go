@@ -8,4 +8,5 @@ type Preset struct {
 	Data        string `db:"data" json:"data"`
 	Output      string `db:"output" json:"output"`
 	Active      *bool  `db:"active" json:"active"`
+	OrgID       int64  `db:"org_id" json:"org_id"`
 }
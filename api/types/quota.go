@@ -0,0 +1,22 @@
+package types
+
+// Quota defines the limits of the jobs of an organization, or of a user in
+// it if Username is set. Limits of 0 are unlimited.
+type Quota struct {
+	OrgID                  int64  `db:"org_id" json:"org_id"`
+	Username               string `db:"username" json:"username,omitempty"`
+	MaxConcurrentJobs      int    `db:"max_concurrent_jobs" json:"max_concurrent_jobs"`
+	JobsPerDay             int    `db:"jobs_per_day" json:"jobs_per_day"`
+	EncodedMinutesPerMonth int    `db:"encoded_minutes_per_month" json:"encoded_minutes_per_month"`
+	MaxSourceSize          int64  `db:"max_source_size" json:"max_source_size"` // In bytes.
+	UpdatedDate            string `db:"updated_date" json:"updated_date"`
+}
+
+// Usage defines the usage counted against quotas. Concurrent jobs are the
+// unfinished jobs. Encoded minutes are the source minutes of the completed
+// jobs created this month.
+type Usage struct {
+	ConcurrentJobs int     `db:"concurrent_jobs" json:"concurrent_jobs"`
+	JobsToday      int     `db:"jobs_today" json:"jobs_today"`
+	EncodedMinutes float64 `db:"encoded_minutes" json:"encoded_minutes"`
+}
@@ -0,0 +1,127 @@
+package types
+
+import "github.com/lib/pq"
+
+// Permissions granted to roles.
+const (
+	PermJobsRead    = "jobs:read"
+	PermJobsReadAll = "jobs:read_all" // See all jobs regardless of JOB_VISIBILITY.
+	PermJobsCreate  = "jobs:create"
+	PermJobsUpdate  = "jobs:update"
+	PermJobsCancel  = "jobs:cancel"
+	PermJobsRestart = "jobs:restart"
+	PermJobsDelete  = "jobs:delete"
+
+	PermPresetsRead  = "presets:read"
+	PermPresetsWrite = "presets:write"
+
+	PermStorageRead   = "storage:read"
+	PermStorageManage = "storage:manage"
+
+	PermWatchersManage      = "watchers:manage"
+	PermIngestManage        = "ingest:manage"
+	PermWebhooksManage      = "webhooks:manage"
+	PermNotificationsManage = "notifications:manage"
+
+	PermMachinesRead   = "machines:read"
+	PermMachinesManage = "machines:manage"
+	PermWorkersRead    = "workers:read"
+	PermStatsRead      = "stats:read"
+
+	PermUsersManage   = "users:manage"
+	PermRolesManage   = "roles:manage"
+	PermOrgsManage    = "orgs:manage" // Manage and switch to all organizations.
+	PermMembersManage = "members:manage"
+	PermAPIKeysManage = "apikeys:manage"
+	PermSettingsRead  = "settings:read"
+	PermSettingsWrite = "settings:write"
+	PermAuditRead     = "audit:read"
+
+	// PermAll grants all permissions.
+	PermAll = "*"
+)
+
+// Permissions All permissions.
+var Permissions = []string{
+	PermJobsRead,
+	PermJobsReadAll,
+	PermJobsCreate,
+	PermJobsUpdate,
+	PermJobsCancel,
+	PermJobsRestart,
+	PermJobsDelete,
+	PermPresetsRead,
+	PermPresetsWrite,
+	PermStorageRead,
+	PermStorageManage,
+	PermWatchersManage,
+	PermIngestManage,
+	PermWebhooksManage,
+	PermNotificationsManage,
+	PermMachinesRead,
+	PermMachinesManage,
+	PermWorkersRead,
+	PermStatsRead,
+	PermUsersManage,
+	PermRolesManage,
+	PermOrgsManage,
+	PermMembersManage,
+	PermAPIKeysManage,
+	PermSettingsRead,
+	PermSettingsWrite,
+	PermAuditRead,
+}
+
+// SystemPermissions are the permissions of the whole instance, granted by a
+// user's role. Other permissions are granted by the user's role in their
+// active organization.
+var SystemPermissions = []string{
+	PermWebhooksManage,
+	PermNotificationsManage,
+	PermUsersManage,
+	PermRolesManage,
+	PermOrgsManage,
+	PermAuditRead,
+}
+
+// IsSystemPermission checks if a permission is a system permission.
+func IsSystemPermission(permission string) bool {
+	for _, p := range SystemPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Role defines a named set of permissions assigned to users. Built-in roles
+// can't be deleted.
+type Role struct {
+	ID          int64          `db:"id" json:"id,omitempty"`
+	Name        string         `db:"name" json:"name"`
+	Description string         `db:"description" json:"description"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	Builtin     bool           `db:"builtin" json:"builtin"`
+	CreatedDate string         `db:"created_date" json:"created_date"`
+}
+
+// Can checks if the role has a permission.
+func (r *Role) Can(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission || p == PermAll {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectivePermissions gets the permissions of the role, expanding PermAll.
+func (r *Role) EffectivePermissions() []string {
+	perms := []string{}
+	for _, p := range Permissions {
+		if r.Can(p) {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
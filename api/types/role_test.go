@@ -0,0 +1,95 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoleCan(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		permission  string
+		want        bool
+	}{
+		{"granted", []string{PermJobsRead, PermJobsCreate}, PermJobsCreate, true},
+		{"not granted", []string{PermJobsRead}, PermJobsCreate, false},
+		{"all", []string{PermAll}, PermOrgsManage, true},
+		{"none", nil, PermJobsRead, false},
+		{"no prefix match", []string{"jobs"}, PermJobsRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Role{Permissions: tt.permissions}
+			if got := r.Can(tt.permission); got != tt.want {
+				t.Errorf("Can(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleEffectivePermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		want        []string
+	}{
+		{"all", []string{PermAll}, Permissions},
+		{"some", []string{PermStatsRead, PermJobsRead}, []string{PermJobsRead, PermStatsRead}},
+		{"unknown dropped", []string{"jobs:everything"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Role{Permissions: tt.permissions}
+			if got := r.EffectivePermissions(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EffectivePermissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSystemPermission(t *testing.T) {
+	tests := []struct {
+		permission string
+		want       bool
+	}{
+		{PermUsersManage, true},
+		{PermRolesManage, true},
+		{PermOrgsManage, true},
+		{PermAuditRead, true},
+		{PermWebhooksManage, true},
+		{PermJobsRead, false},
+		{PermAPIKeysManage, false},
+		{PermMembersManage, false},
+		{PermAll, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			if got := IsSystemPermission(tt.permission); got != tt.want {
+				t.Errorf("IsSystemPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrganizationMemberEffectiveRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		member OrganizationMember
+		want   string
+	}{
+		{"member role", OrganizationMember{Role: "operator", UserRole: "admin"}, "operator"},
+		{"inherits user role", OrganizationMember{UserRole: "guest"}, "guest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.member.EffectiveRole(); got != tt.want {
+				t.Errorf("EffectiveRole() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
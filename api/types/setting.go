@@ -23,16 +23,70 @@ const (
 	DigitalOceanRegion      = "DIGITAL_OCEAN_REGION"
 	DigitalOceanVPC         = "DIGITAL_OCEAN_VPC"
 	SlackWebhook            = "SLACK_WEBHOOK"
+	WebhookSecret           = "WEBHOOK_SECRET"
+
+	RetentionCompletedDays = "RETENTION_COMPLETED_DAYS"
+	RetentionFailedDays    = "RETENTION_FAILED_DAYS"
+	RetentionCancelledDays = "RETENTION_CANCELLED_DAYS"
+	RetentionDeleteOutputs = "RETENTION_DELETE_OUTPUTS"
+
+	JobVisibility = "JOB_VISIBILITY"
+
+	RequireAdminTOTP = "REQUIRE_ADMIN_TOTP"
+
+	RegistrationMode     = "REGISTRATION_MODE"
+	RegistrationApproval = "REGISTRATION_APPROVAL"
 
 	DigitalOceanSpaces = "DIGITALOCEANSPACES"
 	AmazonAWS          = "AMAZONAWS"
 	Custom             = "CUSTOM"
 )
 
+// InstanceSettings are settings of the whole instance rather than of an
+// organization. They are stored with the default organization.
+var InstanceSettings = []string{
+	SlackWebhook,
+	RequireAdminTOTP,
+	RegistrationMode,
+	RegistrationApproval,
+}
+
+// IsInstanceSetting checks if a setting is an instance setting.
+func IsInstanceSetting(name string) bool {
+	for _, s := range InstanceSettings {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OrganizationDefaultSettings are the settings new organizations are created
+// with, matching the defaults of the default organization.
+var OrganizationDefaultSettings = map[string]string{
+	StorageDriver: StorageS3,
+	S3Provider:    "digitaloceanspaces",
+	S3Streaming:   "disabled",
+}
+
+// Registration modes. Invited users can register in the open and invite
+// modes.
+const (
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationDisabled = "disabled"
+)
+
+// Job visibility types.
+const (
+	JobVisibilityAll = "all"
+	JobVisibilityOwn = "own"
+)
+
 // Setting defines a setting for a user.
 type Setting struct {
-	ID     int64 `db:"id" json:"-"`
-	UserID int64 `db:"user_id" json:"-"`
+	ID    int64 `db:"id" json:"-"`
+	OrgID int64 `db:"org_id" json:"-"`
 
 	SettingsOptionID int64 `db:"settings_option_id" json:"-"`
 	SettingsOption   `db:"settings_option"`
@@ -0,0 +1,26 @@
+package types
+
+// Storage driver types.
+const (
+	StorageS3  = "s3"
+	StorageFTP = "ftp"
+)
+
+// StorageProfile defines a named set of storage credentials and locations.
+type StorageProfile struct {
+	ID             int64  `db:"id" json:"id,omitempty"`
+	Name           string `db:"name" json:"name"`
+	Driver         string `db:"driver" json:"driver"`
+	Provider       string `db:"provider" json:"provider"`
+	Endpoint       string `db:"endpoint" json:"endpoint"`
+	Region         string `db:"region" json:"region"`
+	AccessKey      string `db:"access_key" json:"-"`
+	SecretKey      string `db:"secret_key" json:"-"`
+	InboundBucket  string `db:"inbound_bucket" json:"inbound_bucket"`
+	OutboundBucket string `db:"outbound_bucket" json:"outbound_bucket"`
+	FTPAddr        string `db:"ftp_addr" json:"ftp_addr"`
+	FTPUsername    string `db:"ftp_username" json:"-"`
+	FTPPassword    string `db:"ftp_password" json:"-"`
+	CreatedDate    string `db:"created_date" json:"created_date"`
+	OrgID          int64  `db:"org_id" json:"org_id"`
+}
@@ -1,5 +1,7 @@
 package types
 
+import "github.com/lib/pq"
+
 // User contains user models.
 type User struct {
 	ID                 int64  `db:"id" json:"id,omitempty"`
@@ -8,4 +10,19 @@ type User struct {
 	Role               string `db:"role" json:"role" valid:"required"`
 	ForcePasswordReset bool   `db:"force_password_reset" json:"-"`
 	Active             bool   `db:"active" json:"active"`
+	ServiceAccount     bool   `db:"service_account" json:"service_account"`
+	Pending            bool   `db:"pending" json:"pending"` // Registered, awaiting approval.
+
+	// Provider account of users created by an OIDC login.
+	OIDCIssuer  string `db:"oidc_issuer" json:"-"`
+	OIDCSubject string `db:"oidc_subject" json:"-"`
+
+	// Two-factor authentication.
+	TOTPSecret    string         `db:"totp_secret" json:"-"`
+	TOTPEnabled   bool           `db:"totp_enabled" json:"totp_enabled"`
+	RecoveryCodes pq.StringArray `db:"recovery_codes" json:"-"` // SHA-256 hashes.
+
+	// Active organization of an authenticated user, and the user's role in it.
+	OrgID   int64  `db:"-" json:"org_id,omitempty"`
+	OrgRole string `db:"-" json:"org_role,omitempty"`
 }
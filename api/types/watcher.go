@@ -0,0 +1,30 @@
+package types
+
+import "github.com/lib/pq"
+
+// Watcher scan statuses.
+const (
+	WatcherScanOK    = "ok"
+	WatcherScanError = "error"
+)
+
+// Watcher defines a watch rule for creating jobs from new files in storage.
+type Watcher struct {
+	ID          int64          `db:"id" json:"id,omitempty"`
+	Name        string         `db:"name" json:"name"`
+	Profile     string         `db:"profile" json:"profile"`
+	Prefix      string         `db:"prefix" json:"prefix"`
+	Glob        string         `db:"glob" json:"glob"`
+	Presets     pq.StringArray `db:"presets" json:"presets"`
+	Destination string         `db:"destination" json:"dest"`
+	DestProfile string         `db:"dest_profile" json:"dest_profile"`
+	Active      bool           `db:"active" json:"active"`
+	CreatedDate string         `db:"created_date" json:"created_date"`
+	OrgID       int64          `db:"org_id" json:"org_id"`
+
+	// Last scan status.
+	LastScanDate   NullString `db:"last_scan_date" json:"last_scan_date"`
+	LastScanStatus string     `db:"last_scan_status" json:"last_scan_status"`
+	LastScanError  string     `db:"last_scan_error" json:"last_scan_error"`
+	LastScanJobs   int        `db:"last_scan_jobs" json:"last_scan_jobs"`
+}
@@ -0,0 +1,56 @@
+package types
+
+import "github.com/lib/pq"
+
+// Webhook event types.
+const (
+	EventJobQueued    = "job.queued"
+	EventJobStarted   = "job.started"
+	EventJobProgress  = "job.progress"
+	EventJobCompleted = "job.completed"
+	EventJobFailed    = "job.failed"
+	EventJobCancelled = "job.cancelled"
+)
+
+// WebhookEvents All webhook event types.
+var WebhookEvents = []string{
+	EventJobQueued,
+	EventJobStarted,
+	EventJobProgress,
+	EventJobCompleted,
+	EventJobFailed,
+	EventJobCancelled,
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook defines a registered endpoint receiving job events.
+type Webhook struct {
+	ID          int64          `db:"id" json:"id,omitempty"`
+	URL         string         `db:"url" json:"url"`
+	Secret      string         `db:"secret" json:"-"`
+	Events      pq.StringArray `db:"events" json:"events"`
+	Active      bool           `db:"active" json:"active"`
+	CreatedDate string         `db:"created_date" json:"created_date"`
+}
+
+// WebhookDelivery defines a delivery of an event to a webhook or job callback.
+type WebhookDelivery struct {
+	ID           int64     `db:"id" json:"id"`
+	WebhookID    NullInt64 `db:"webhook_id" json:"webhook_id"`
+	JobID        int64     `db:"job_id" json:"job_id"`
+	URL          string    `db:"url" json:"url"`
+	Event        string    `db:"event" json:"event"`
+	Payload      string    `db:"payload" json:"payload"`
+	Status       string    `db:"status" json:"status"`
+	Attempts     int       `db:"attempts" json:"attempts"`
+	ResponseCode int       `db:"response_code" json:"response_code"`
+	Error        string    `db:"error" json:"error"`
+	CreatedDate  string    `db:"created_date" json:"created_date"`
+	UpdatedDate  string    `db:"updated_date" json:"updated_date"`
+}
@@ -0,0 +1,13 @@
+package types
+
+// Workflow defines a group of jobs run as steps, each queued once the steps
+// it depends on are completed.
+type Workflow struct {
+	ID          int64  `db:"id" json:"id"`
+	Name        string `db:"name" json:"name"`
+	CreatedBy   string `db:"created_by" json:"created_by"`
+	CreatedDate string `db:"created_date" json:"created_date"`
+	OrgID       int64  `db:"org_id" json:"org_id"`
+
+	Jobs []Job `db:"-" json:"jobs,omitempty"`
+}
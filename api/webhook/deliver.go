@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+)
+
+var client = &http.Client{Timeout: Timeout}
+
+// NewDeliveryPool creates a worker pool delivering queued webhook events.
+func NewDeliveryPool(namespace string, pool *redis.Pool, concurrency uint) *work.WorkerPool {
+	p := work.NewWorkerPool(struct{}{}, concurrency, namespace, pool)
+	p.JobWithOptions(JobName, work.JobOptions{
+		MaxFails: MaxAttempts,
+		SkipDead: true,
+		Backoff:  backoff,
+	}, Deliver)
+	return p
+}
+
+// Deliver is the work queue handler attempting a delivery. Returning an
+// error retries the delivery until MaxAttempts.
+func Deliver(job *work.Job) error {
+	id := job.ArgInt64("delivery_id")
+	if err := job.ArgError(); err != nil {
+		return err
+	}
+
+	db := data.New()
+	delivery, err := db.Webhooks.GetWebhookDeliveryByID(id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status != types.DeliveryPending {
+		return nil
+	}
+
+	secret, err := deliverySecret(delivery)
+	if err != nil {
+		return err
+	}
+
+	code, err := post(delivery, secret)
+	if err == nil {
+		db.Webhooks.UpdateWebhookDeliveryByID(id, types.DeliveryDelivered, code, "")
+		return nil
+	}
+
+	// Keep the delivery pending until the attempts are exhausted.
+	status := types.DeliveryPending
+	if delivery.Attempts+1 >= MaxAttempts {
+		status = types.DeliveryFailed
+	}
+	db.Webhooks.UpdateWebhookDeliveryByID(id, status, code, err.Error())
+	log.Warnf("webhook delivery %d to %s: %s", id, delivery.URL, err)
+	return err
+}
+
+// deliverySecret gets the webhook secret, or the callback secret setting of
+// the job's organization for job callbacks.
+func deliverySecret(delivery *types.WebhookDelivery) (string, error) {
+	db := data.New()
+	if delivery.WebhookID.Valid {
+		w, err := db.Webhooks.GetWebhookByID(delivery.WebhookID.Int64)
+		if err != nil {
+			return "", err
+		}
+		return w.Secret, nil
+	}
+
+	job, err := db.Jobs.GetJobByID(delivery.JobID)
+	if err != nil {
+		return "", err
+	}
+
+	setting, err := db.Settings.GetSetting(job.OrgID, types.WebhookSecret)
+	if err != nil {
+		// Deliver unsigned if no callback secret is set.
+		return "", nil
+	}
+	return setting.Value, nil
+}
+
+func post(delivery *types.WebhookDelivery, secret string) (int, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest("POST", delivery.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, payload))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the seconds until the next attempt, doubling from 10s.
+func backoff(job *work.Job) int64 {
+	secs := 10 * math.Pow(2, float64(job.Fails-1))
+	if secs > MaxBackoff {
+		return MaxBackoff
+	}
+	return int64(secs)
+}
@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/alfg/openencoder/api/data"
+	"github.com/alfg/openencoder/api/types"
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Dispatcher sends job events to the subscribed webhooks and the job callback.
+type Dispatcher struct {
+	enqueuer *work.Enqueuer
+}
+
+// NewDispatcher creates a dispatcher sending deliveries to the work queue.
+func NewDispatcher(namespace string, pool *redis.Pool) *Dispatcher {
+	return &Dispatcher{
+		enqueuer: work.NewEnqueuer(namespace, pool),
+	}
+}
+
+// Send records a delivery of an event for each target and queues it.
+func (d *Dispatcher) Send(eventType string, job types.Job) error {
+	db := data.New()
+	payload, err := json.Marshal(NewEvent(eventType, job))
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := db.Webhooks.GetActiveWebhooksByEvent(eventType)
+	if err != nil {
+		return err
+	}
+
+	deliveries := []types.WebhookDelivery{}
+	for _, w := range *webhooks {
+		deliveries = append(deliveries, types.WebhookDelivery{
+			WebhookID: types.NullInt64{
+				NullInt64: sql.NullInt64{Int64: w.ID, Valid: true},
+			},
+			URL: w.URL,
+		})
+	}
+	if job.CallbackURL != "" {
+		deliveries = append(deliveries, types.WebhookDelivery{URL: job.CallbackURL})
+	}
+
+	for _, delivery := range deliveries {
+		delivery.JobID = job.ID
+		delivery.Event = eventType
+		delivery.Payload = string(payload)
+		delivery.Status = types.DeliveryPending
+
+		created, err := db.Webhooks.CreateWebhookDelivery(delivery)
+		if err != nil {
+			return err
+		}
+
+		_, err = d.enqueuer.Enqueue(JobName, work.Q{"delivery_id": created.ID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
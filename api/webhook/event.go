@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/alfg/openencoder/api/types"
+)
+
+// Event defines the JSON payload delivered for a job status transition.
+type Event struct {
+	Type    string   `json:"event"`
+	Created string   `json:"created"`
+	Job     EventJob `json:"job"`
+}
+
+// EventJob defines the job data of an event.
+type EventJob struct {
+	ID                  int64   `json:"id"`
+	GUID                string  `json:"guid"`
+	Preset              string  `json:"preset"`
+	Status              string  `json:"status"`
+	Source              string  `json:"source"`
+	Destination         string  `json:"destination"`
+	ResolvedDestination string  `json:"resolved_destination"`
+	Output              string  `json:"output"`
+	Progress            float64 `json:"progress"`
+}
+
+// NewEvent creates an event for a job.
+func NewEvent(eventType string, job types.Job) Event {
+	return Event{
+		Type:    eventType,
+		Created: time.Now().UTC().Format(time.RFC3339),
+		Job: EventJob{
+			ID:                  job.ID,
+			GUID:                job.GUID,
+			Preset:              job.Preset,
+			Status:              job.Status,
+			Source:              job.Source,
+			Destination:         job.Destination,
+			ResolvedDestination: job.ResolvedDestination,
+			Output:              job.Output,
+			Progress:            job.Progress.Float64,
+		},
+	}
+}
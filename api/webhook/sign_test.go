@@ -0,0 +1,52 @@
+package webhook
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		payload string
+		want    string
+	}{
+		{"empty", "", "",
+			"sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+		{"payload", "key", "The quick brown fox jumps over the lazy dog",
+			"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, []byte(tt.payload)); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignSecrets(t *testing.T) {
+	payload := []byte(`{"event":"job.completed"}`)
+	if Sign("a", payload) == Sign("b", payload) {
+		t.Error("Sign() is the same for different secrets")
+	}
+	if Sign("a", payload) == Sign("a", []byte(`{"event":"job.failed"}`)) {
+		t.Error("Sign() is the same for different payloads")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 64 {
+		t.Errorf("len(GenerateSecret()) = %d, want 64", len(a))
+	}
+	if a == b {
+		t.Error("GenerateSecret() returned the same secret twice")
+	}
+}
@@ -0,0 +1,24 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/alfg/openencoder/api/logging"
+)
+
+var log = logging.Log
+
+// Webhook settings.
+const (
+	JobName     = "webhook" // Work queue job name for deliveries.
+	MaxAttempts = 8         // Delivery attempts before a delivery fails.
+	Timeout     = 10 * time.Second
+	MaxBackoff  = 3600 // Max seconds between attempts.
+
+	HeaderEvent     = "X-Openencoder-Event"
+	HeaderDelivery  = "X-Openencoder-Delivery"
+	HeaderSignature = "X-Openencoder-Signature"
+)
+
+// ProgressMilestones are the encode progress percentages sending job.progress.
+var ProgressMilestones = []float64{25, 50, 75}
@@ -8,19 +8,28 @@ import (
 	"github.com/alfg/openencoder/api/config"
 	"github.com/alfg/openencoder/api/data"
 	"github.com/alfg/openencoder/api/logging"
+	"github.com/alfg/openencoder/api/stream"
 	"github.com/alfg/openencoder/api/types"
+	"github.com/alfg/openencoder/api/webhook"
 	"github.com/gocraft/work"
 	"github.com/gomodule/redigo/redis"
 )
 
-var log = logging.Log
+var (
+	log        = logging.Log
+	dispatcher *webhook.Dispatcher
+)
 
 // Context defines the job context to be passed to the worker.
 type Context struct {
-	GUID        string
-	Preset      string
-	Source      string
-	Destination string
+	GUID          string
+	Preset        string
+	Source        string
+	Destination   string
+	SourceProfile string
+	DestProfile   string
+	SourceFrom    int64
+	OrgID         int64
 }
 
 // Log worker middleware for logging job.
@@ -55,6 +64,34 @@ func (c *Context) FindJob(job *work.Job, next work.NextMiddlewareFunc) error {
 			return err
 		}
 	}
+	if _, ok := job.Args["source_profile"]; ok {
+		c.SourceProfile = job.ArgString("source_profile")
+		if err := job.ArgError(); err != nil {
+			return err
+		}
+	}
+	if _, ok := job.Args["dest_profile"]; ok {
+		c.DestProfile = job.ArgString("dest_profile")
+		if err := job.ArgError(); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := job.Args["source_from"]; ok {
+		c.SourceFrom = job.ArgInt64("source_from")
+		if err := job.ArgError(); err != nil {
+			return err
+		}
+	}
+
+	// Jobs queued before organizations belong to the default organization.
+	c.OrgID = types.DefaultOrgID
+	if _, ok := job.Args["org_id"]; ok {
+		c.OrgID = job.ArgInt64("org_id")
+		if err := job.ArgError(); err != nil {
+			return err
+		}
+	}
 	return next()
 }
 
@@ -66,10 +103,14 @@ func (c *Context) SendJob(job *work.Job) error {
 	destination := job.ArgString("destination")
 
 	j := types.Job{
-		GUID:        guid,
-		Preset:      preset,
-		Source:      source,
-		Destination: destination,
+		GUID:          guid,
+		Preset:        preset,
+		Source:        source,
+		Destination:   destination,
+		SourceProfile: c.SourceProfile,
+		DestProfile:   c.DestProfile,
+		SourceFrom:    c.SourceFrom,
+		OrgID:         c.OrgID,
 	}
 
 	// Check if job is cancelled.
@@ -121,6 +162,12 @@ func NewWorker(workerCfg Config) {
 		},
 	}
 
+	// Send job events through the webhook queue.
+	dispatcher = webhook.NewDispatcher(workerCfg.Namespace, redisPool)
+
+	// Publish live job updates.
+	stream.Init(redisPool, workerCfg.Namespace)
+
 	// Make a new pool.
 	pool := work.NewWorkerPool(Context{},
 		workerCfg.Concurrency, workerCfg.Namespace, redisPool)
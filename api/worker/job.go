@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alfg/openencoder/api/config"
@@ -15,7 +17,15 @@ import (
 	"github.com/alfg/openencoder/api/helpers"
 	"github.com/alfg/openencoder/api/net"
 	"github.com/alfg/openencoder/api/notify"
+	"github.com/alfg/openencoder/api/stream"
 	"github.com/alfg/openencoder/api/types"
+	"github.com/alfg/openencoder/api/webhook"
+)
+
+// Max lengths of a resolved destination and output name, as stored.
+const (
+	destinationMaxLength = 128
+	outputMaxLength      = 256
 )
 
 var progressCh chan struct{}
@@ -24,8 +34,7 @@ func generatePresignedURL(job types.Job) (string, error) {
 	log.Info("generating a presigned URL")
 
 	// Update status.
-	db := data.New()
-	db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobDownloading)
+	updateStatus(job.GUID, types.JobDownloading)
 
 	// Get presigned URL.
 	str, err := net.GetPresignedURL(job)
@@ -40,7 +49,7 @@ func download(job types.Job, storageDriver string) error {
 
 	// Update status.
 	db := data.New()
-	db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobDownloading)
+	updateStatus(job.GUID, types.JobDownloading)
 
 	// Get job data.
 	j, err := db.Jobs.GetJobByGUID(job.GUID)
@@ -57,6 +66,7 @@ func download(job types.Job, storageDriver string) error {
 
 	// Set progress to 100.
 	db.Jobs.UpdateTransferProgressByID(encodeID, 100)
+	stream.Transfer(job.GUID, 100)
 	return err
 }
 
@@ -65,7 +75,7 @@ func probe(job types.Job) (*encoder.FFProbeResponse, error) {
 
 	// Update status.
 	db := data.New()
-	db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobProbing)
+	updateStatus(job.GUID, types.JobProbing)
 
 	// Run FFProbe.
 	f := encoder.FFProbe{}
@@ -87,13 +97,13 @@ func encode(job types.Job, probeData *encoder.FFProbeResponse) error {
 
 	// Update status.
 	db := data.New()
-	db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobEncoding)
+	updateStatus(job.GUID, types.JobEncoding)
 
-	p, err := db.Presets.GetPresetByName(job.Preset)
+	p, err := db.Presets.GetPresetByName(job.OrgID, job.Preset)
 	if err != nil {
 		return err
 	}
-	dest := path.Dir(job.LocalSource) + "/dst/" + p.Output
+	dest := path.Dir(job.LocalSource) + "/dst/" + job.Output
 
 	// Get job data.
 	j, _ := db.Jobs.GetJobByGUID(job.GUID)
@@ -103,6 +113,7 @@ func encode(job types.Job, probeData *encoder.FFProbeResponse) error {
 
 	// Run FFmpeg.
 	f := &encoder.FFmpeg{}
+	start := time.Now()
 	go trackEncodeProgress(j.GUID, j.EncodeID, probeData, f)
 	err = f.Run(job.Source, dest, p.Data)
 	if err != nil {
@@ -110,15 +121,131 @@ func encode(job types.Job, probeData *encoder.FFProbeResponse) error {
 		return err
 	}
 	close(progressCh)
+
+	// Record the encode time for estimating jobs.
+	elapsed := time.Since(start).Seconds()
+	db.Jobs.UpdateEncodeStatsByID(j.EncodeID, probeData.Duration(), elapsed, config.Get().WorkerSize)
 	return err
 }
 
+func resolveOutput(job types.Job, source string, probeData *encoder.FFProbeResponse) (types.Job, error) {
+	log.Info("resolving output templates")
+
+	db := data.New()
+	p, err := db.Presets.GetPresetByName(job.OrgID, job.Preset)
+	if err != nil {
+		return job, err
+	}
+	j, err := db.Jobs.GetJobByGUID(job.GUID)
+	if err != nil {
+		return job, err
+	}
+
+	vars := outputTemplateVars(job, j.ID, source, probeData, time.Now().UTC())
+	destination, output, err := expandOutput(job.Destination, p.Output, vars)
+	if err != nil {
+		return job, err
+	}
+	job.ResolvedDestination = destination
+	job.Output = output
+
+	// Record the resolved destination and output name.
+	err = db.Jobs.UpdateJobOutputByGUID(job.GUID, job.ResolvedDestination, job.Output)
+	return job, err
+}
+
+// outputTemplateVars gets the template variables from the job, source and
+// probe data. The width and height are only set for sources with a video
+// stream.
+func outputTemplateVars(job types.Job, jobID int64, source string, probeData *encoder.FFProbeResponse, now time.Time) map[string]string {
+	// Sources are storage URLs, e.g. "s3:///src/video.mp4", or keys.
+	if u, err := url.Parse(source); err == nil && u.Path != "" {
+		source = u.Path
+	}
+
+	ext := path.Ext(source)
+	vars := map[string]string{
+		"guid":            job.GUID,
+		"job_id":          strconv.FormatInt(jobID, 10),
+		"preset":          job.Preset,
+		"source_basename": strings.TrimSuffix(path.Base(source), ext),
+		"source_filename": path.Base(source),
+		"source_ext":      strings.TrimPrefix(ext, "."),
+		"source_dir":      sanitizePathValue(path.Dir(source)),
+		"date":            now.Format("2006-01-02"),
+		"time":            now.Format("150405"),
+		"timestamp":       strconv.FormatInt(now.Unix(), 10),
+	}
+	if probeData == nil {
+		return vars
+	}
+	for _, s := range probeData.Streams {
+		if s.CodecType == "video" && s.Width > 0 && s.Height > 0 {
+			vars["width"] = strconv.Itoa(s.Width)
+			vars["height"] = strconv.Itoa(s.Height)
+			break
+		}
+	}
+	return vars
+}
+
+// expandOutput expands the destination and output name templates. Values
+// can't add path segments to the output name, or climb out of the
+// destination. Templates using a variable without a value, and results
+// longer than can be stored, are errors.
+func expandOutput(destination, output string, vars map[string]string) (string, string, error) {
+	for _, tmpl := range []string{destination, output} {
+		if missing := helpers.MissingTemplateVariables(tmpl, vars); len(missing) > 0 {
+			return "", "", fmt.Errorf("template variable {%s} is not available for the source", missing[0])
+		}
+	}
+
+	destVars := map[string]string{}
+	outputVars := map[string]string{}
+	for k, v := range vars {
+		destVars[k] = sanitizePathValue(v)
+		outputVars[k] = sanitizeFileValue(v)
+	}
+	destination = helpers.ExpandTemplate(destination, destVars)
+	output = helpers.ExpandTemplate(output, outputVars)
+
+	if len(destination) > destinationMaxLength {
+		return "", "", fmt.Errorf("destination is longer than %d characters: %s", destinationMaxLength, destination)
+	}
+	if len(output) > outputMaxLength {
+		return "", "", fmt.Errorf("output name is longer than %d characters: %s", outputMaxLength, output)
+	}
+	return destination, output, nil
+}
+
+// sanitizePathValue removes empty, "." and ".." segments from a template
+// value used in a path.
+func sanitizePathValue(v string) string {
+	segments := []string{}
+	for _, s := range strings.Split(v, "/") {
+		if s != "" && s != "." && s != ".." {
+			segments = append(segments, s)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// sanitizeFileValue replaces the path separators of a template value used in
+// a file name.
+func sanitizeFileValue(v string) string {
+	v = strings.NewReplacer("/", "_", "\\", "_").Replace(v)
+	if v == "." || v == ".." {
+		return "_"
+	}
+	return v
+}
+
 func upload(job types.Job) error {
 	log.Info("running upload task")
 
 	// Update status.
 	db := data.New()
-	db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobUploading)
+	updateStatus(job.GUID, types.JobUploading)
 
 	// Get job data.
 	j, err := db.Jobs.GetJobByGUID(job.GUID)
@@ -128,14 +255,23 @@ func upload(job types.Job) error {
 	}
 	encodeID := j.EncodeID
 
-	if err := net.Upload(job); err != nil {
+	checksums, err := net.Upload(job)
+	if err != nil {
 		log.Error(err)
 		return err
 	}
 
+	// Record the output checksums.
+	b, err := json.Marshal(checksums)
+	if err != nil {
+		log.Error(err)
+	}
+	db.Jobs.UpdateEncodeChecksumsByID(encodeID, string(b))
+
 	// Set progress to 100.
 	db.Jobs.UpdateTransferProgressByID(encodeID, 100)
-	return err
+	stream.Transfer(job.GUID, 100)
+	return nil
 }
 
 func cleanup(job types.Job) error {
@@ -153,57 +289,75 @@ func completed(job types.Job) error {
 	log.Info("job completed")
 
 	// Update status.
-	db := data.New()
-	db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobCompleted)
+	updateStatus(job.GUID, types.JobCompleted)
+	sendEvent(types.EventJobCompleted, job.GUID)
 	return nil
 }
 
-func sendAlert(job types.Job) error {
-	log.Info("sending alert")
+func failed(job types.Job) {
+	log.Info("job failed")
 
+	// Update status.
+	updateStatus(job.GUID, types.JobError)
+	sendEvent(types.EventJobFailed, job.GUID)
+}
+
+// updateStatus updates a job status and publishes it to live streams.
+func updateStatus(guid, status string) {
+	db := data.New()
+	db.Jobs.UpdateJobStatusByGUID(guid, status)
+	stream.Status(guid, status)
+}
+
+// sendEvent sends a job event to the webhooks, job callback and
+// notification channels.
+func sendEvent(eventType, guid string) {
 	db := data.New()
-	webhook, err := db.Settings.GetSetting(types.SlackWebhook)
+	job, err := db.Jobs.GetJobByGUID(guid)
 	if err != nil {
-		return err
+		log.Error(err)
+		return
 	}
 
-	// Only send Slack alert if configured.
-	if webhook.Value != "" {
-		message := fmt.Sprintf(AlertMessageFormat, job.GUID, job.Preset, job.Source, job.Destination)
-		err := notify.SendSlackMessage(webhook.Value, message)
-		if err != nil {
-			return err
-		}
+	if err := dispatcher.Send(eventType, *job); err != nil {
+		log.Error(err)
 	}
-	return nil
+	notify.Dispatch(eventType, *job)
 }
 
 func runEncodeJob(job types.Job) {
+	source := job.Source
+
 	// Set local src path.
 	job.LocalSource = helpers.CreateLocalSourcePath(
 		config.Get().WorkDirectory, job.Source, job.GUID)
 
 	db := data.New()
-	storageDriver, err := db.Settings.GetSetting(types.StorageDriver)
+	sendEvent(types.EventJobStarted, job.GUID)
+
+	storage, err := net.GetSourceStorage(job)
 	if err != nil {
 		log.Error(err)
+		failed(job)
 		return
 	}
 
-	// If STREAMING setting is enabled, get a presigned URL and update
-	// the job.Source.
-	s3Streaming, err := db.Settings.GetSetting(types.S3Streaming)
-	if err != nil {
+	// Record the source version encoded for duplicate detection.
+	if etag, err := net.GetSourceETag(job); err != nil {
 		log.Error(err)
-		return
+	} else {
+		db.Jobs.UpdateJobSourceETagByGUID(job.GUID, etag)
 	}
 
-	if s3Streaming.Value == "enabled" && storageDriver.Value == "s3" {
+	// If STREAMING setting is enabled, get a presigned URL and update
+	// the job.Source. Streaming is disabled if the setting isn't set.
+	s3Streaming, err := db.Settings.GetSetting(job.OrgID, types.S3Streaming)
+	if err == nil && s3Streaming.Value == "enabled" && storage.Driver == types.StorageS3 {
 		// 1a. Get presigned URL.
 		presigned, err := generatePresignedURL(job)
 		if err != nil {
 			log.Error(err)
-			db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+			failed(job)
 			return
 		}
 
@@ -212,10 +366,10 @@ func runEncodeJob(job types.Job) {
 
 	} else {
 		// 1b. Download.
-		err := download(job, storageDriver.Value)
+		err := download(job, storage.Driver)
 		if err != nil {
 			log.Error(err)
-			db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+			failed(job)
 			return
 		}
 
@@ -226,7 +380,15 @@ func runEncodeJob(job types.Job) {
 	probeData, err := probe(job)
 	if err != nil {
 		log.Error(err)
-		db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+		failed(job)
+		return
+	}
+
+	// 2b. Resolve output templates.
+	job, err = resolveOutput(job, source, probeData)
+	if err != nil {
+		log.Error(err)
+		failed(job)
 		return
 	}
 
@@ -240,10 +402,10 @@ func runEncodeJob(job types.Job) {
 
 		// Set job to 'cancelled' if it was cancelled.
 		if err.Error() == types.JobCancelled {
-			db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobCancelled)
+			updateStatus(job.GUID, types.JobCancelled)
 			return
 		}
-		db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+		failed(job)
 		return
 	}
 
@@ -251,7 +413,7 @@ func runEncodeJob(job types.Job) {
 	err = upload(job)
 	if err != nil {
 		log.Error(err)
-		db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+		failed(job)
 		return
 	}
 
@@ -259,27 +421,22 @@ func runEncodeJob(job types.Job) {
 	err = cleanup(job)
 	if err != nil {
 		log.Error(err)
-		db.Jobs.UpdateJobStatusByGUID(job.GUID, types.JobError)
+		failed(job)
 		return
 	}
 
-	// 6. Done
+	// 6. Done and notify.
 	completed(job)
 	if err != nil {
 		log.Error(err)
 	}
-
-	// 7. Alert
-	sendAlert(job)
-	if err != nil {
-		log.Error(err)
-	}
 }
 
 func trackEncodeProgress(guid string, encodeID int64, p *encoder.FFProbeResponse, f *encoder.FFmpeg) {
 	db := data.New()
 	progressCh = make(chan struct{})
 	ticker := time.NewTicker(ProgressInterval)
+	milestone := 0 // Next progress milestone to send.
 
 	for {
 		select {
@@ -306,6 +463,16 @@ func trackEncodeProgress(guid string, encodeID int64, p *encoder.FFProbeResponse
 				pct = math.Round(pct*100) / 100
 				log.Infof("progress: %d / %d - %0.2f%%", currentFrame, totalFrames, pct)
 				db.Jobs.UpdateEncodeProgressByID(encodeID, pct, speed, fps)
+				stream.Encode(guid, pct, fps, speed)
+
+				// Send an event once the next progress milestone is passed.
+				passed := milestone
+				for milestone < len(webhook.ProgressMilestones) && pct >= webhook.ProgressMilestones[milestone] {
+					milestone++
+				}
+				if milestone > passed {
+					sendEvent(types.EventJobProgress, guid)
+				}
 			}
 		}
 	}
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alfg/openencoder/api/encoder"
+	"github.com/alfg/openencoder/api/types"
+)
+
+func TestOutputTemplateVars(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	job := types.Job{GUID: "abc", Preset: "h264_720p"}
+
+	tests := []struct {
+		name   string
+		source string
+		probe  string // FFProbe JSON, or empty for no probe data.
+		want   map[string]string
+	}{
+		{"url", "s3:///src/movies/video.mp4", "", map[string]string{
+			"source_basename": "video",
+			"source_filename": "video.mp4",
+			"source_ext":      "mp4",
+			"source_dir":      "src/movies",
+		}},
+		{"key", "src/video.mp4", "", map[string]string{
+			"source_dir":      "src",
+			"source_filename": "video.mp4",
+		}},
+		{"root", "s3:///video.mp4", "", map[string]string{
+			"source_dir": "",
+		}},
+		{"dot names", "s3:///src/.hidden/../.video", "", map[string]string{
+			"source_dir":      "src",
+			"source_basename": "",
+			"source_ext":      "video",
+		}},
+		{"video stream", "s3:///src/video.mp4",
+			`{"streams":[{"codec_type":"audio"},{"codec_type":"video","width":1280,"height":720}]}`,
+			map[string]string{"width": "1280", "height": "720"}},
+		{"job and time", "s3:///src/video.mp4", "", map[string]string{
+			"guid":      "abc",
+			"job_id":    "42",
+			"preset":    "h264_720p",
+			"date":      "2020-01-02",
+			"time":      "030405",
+			"timestamp": "1577934245",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var probe *encoder.FFProbeResponse
+			if tt.probe != "" {
+				probe = &encoder.FFProbeResponse{}
+				if err := json.Unmarshal([]byte(tt.probe), probe); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			vars := outputTemplateVars(job, 42, tt.source, probe, now)
+			for k, want := range tt.want {
+				if got, ok := vars[k]; !ok || got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputTemplateVarsNoVideo(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe string
+	}{
+		{"no streams", `{"streams":[]}`},
+		{"audio only", `{"streams":[{"codec_type":"audio"}]}`},
+		{"no dimensions", `{"streams":[{"codec_type":"video"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &encoder.FFProbeResponse{}
+			if err := json.Unmarshal([]byte(tt.probe), probe); err != nil {
+				t.Fatal(err)
+			}
+
+			vars := outputTemplateVars(types.Job{}, 1, "s3:///src/audio.mp3", probe, time.Now())
+			for _, k := range []string{"width", "height"} {
+				if v, ok := vars[k]; ok {
+					t.Errorf("%s = %q, want unset", k, v)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandOutput(t *testing.T) {
+	vars := map[string]string{
+		"source_basename": "video",
+		"source_dir":      "src/movies",
+		"preset":          "h264",
+		"width":           "1280",
+		"height":          "720",
+	}
+
+	tests := []struct {
+		name        string
+		destination string
+		output      string
+		vars        map[string]string
+		wantDest    string
+		wantOutput  string
+		wantErr     bool
+	}{
+		{"plain", "s3:///dst/", "out.mp4", vars, "s3:///dst/", "out.mp4", false},
+		{"variables", "s3:///dst/{source_dir}/{source_basename}/", "{preset}_{width}x{height}.mp4", vars,
+			"s3:///dst/src/movies/video/", "h264_1280x720.mp4", false},
+		{"dir in output", "s3:///dst/", "{source_dir}.mp4", vars, "s3:///dst/", "src_movies.mp4", false},
+		{"climb in destination", "s3:///dst/{source_basename}/", "out.mp4",
+			map[string]string{"source_basename": "../../etc"}, "s3:///dst/etc/", "out.mp4", false},
+		{"climb in output", "s3:///dst/", "{source_basename}.mp4",
+			map[string]string{"source_basename": "../x"}, "s3:///dst/", ".._x.mp4", false},
+		{"dots in output", "s3:///dst/", "{source_basename}",
+			map[string]string{"source_basename": ".."}, "s3:///dst/", "_", false},
+		{"backslash in output", "s3:///dst/", "{source_basename}.mp4",
+			map[string]string{"source_basename": `a\b`}, "s3:///dst/", "a_b.mp4", false},
+		{"missing width", "s3:///dst/", "{width}x{height}.mp4",
+			map[string]string{}, "", "", true},
+		{"missing in destination", "s3:///dst/{height}/", "out.mp4",
+			map[string]string{}, "", "", true},
+		{"destination too long", "s3:///dst/{source_basename}/", "out.mp4",
+			map[string]string{"source_basename": strings.Repeat("a", destinationMaxLength)}, "", "", true},
+		{"output too long", "s3:///dst/", "{source_basename}.mp4",
+			map[string]string{"source_basename": strings.Repeat("a", outputMaxLength)}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, output, err := expandOutput(tt.destination, tt.output, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if dest != tt.wantDest {
+				t.Errorf("destination = %q, want %q", dest, tt.wantDest)
+			}
+			if output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output, tt.wantOutput)
+			}
+		})
+	}
+}
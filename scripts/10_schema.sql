@@ -1,3 +1,19 @@
+-- auto-generated definition
+create table organizations
+(
+    id           serial       not null
+        constraint organizations_pk
+            primary key,
+    name         varchar(128) not null,
+    created_date timestamp    default CURRENT_TIMESTAMP
+);
+
+alter table organizations
+    owner to postgres;
+
+create unique index organizations_name_uindex
+    on organizations (name);
+
 -- auto-generated definition
 create table jobs
 (
@@ -9,7 +25,21 @@ create table jobs
   created_date timestamp default CURRENT_TIMESTAMP,
   status       varchar(64),
   source       varchar(128),
-  destination  varchar(128)
+  destination  varchar(128),
+  output       varchar(256) default '',
+  resolved_destination varchar(128) default '',
+  source_profile varchar(128) default '',
+  dest_profile   varchar(128) default '',
+  callback_url   varchar(1024) default '',
+  created_by     varchar(128) default '',
+  source_etag    varchar(128) default '',
+  workflow_id    integer      default 0 not null,
+  workflow_step  varchar(128) default '',
+  depends_on     integer[]    default '{}' not null,
+  source_from    integer      default 0 not null,
+  org_id         integer      default 1 not null
+    constraint jobs_organizations_id_fk
+    references organizations (id)
 );
 
 alter table jobs
@@ -24,6 +54,36 @@ create unique index jobs_guid_uindex
 create index jobs_status_index
   on jobs (status);
 
+create index jobs_status_id_index
+  on jobs (status, id);
+
+create index jobs_preset_id_index
+  on jobs (preset, id);
+
+create index jobs_created_date_id_index
+  on jobs (created_date, id);
+
+create index jobs_source_preset_index
+  on jobs (source, preset, source_etag);
+
+create index jobs_workflow_id_index
+  on jobs (workflow_id);
+
+create index jobs_created_by_id_index
+  on jobs (created_by, id);
+
+create index jobs_org_id_id_index
+  on jobs (org_id, id);
+
+-- Trigram indexes for source and destination substring search.
+create extension if not exists pg_trgm;
+
+create index jobs_source_trgm_index
+  on jobs using gin (source gin_trgm_ops);
+
+create index jobs_destination_trgm_index
+  on jobs using gin (destination gin_trgm_ops);
+
 -- auto-generated definition
 create table encode
 (
@@ -37,7 +97,11 @@ create table encode
             references jobs (id),
     speed    varchar(64),
     fps      double precision default 0,
-    options  json
+    options  json,
+    checksums json,
+    duration    double precision default 0,
+    encode_time double precision default 0,
+    worker_size varchar(64) default ''
 );
 
 alter table encode
@@ -47,6 +111,25 @@ create unique index encode_id_uindex
     on encode (id);
 
 
+-- auto-generated definition
+create table roles
+(
+    id           serial       not null
+        constraint roles_pk
+            primary key,
+    name         varchar(64)  not null,
+    description  varchar(256) default '',
+    permissions  varchar(64)[] default '{}' not null,
+    builtin      boolean      default false,
+    created_date timestamp    default CURRENT_TIMESTAMP
+);
+
+alter table roles
+    owner to postgres;
+
+create unique index roles_name_uindex
+    on roles (name);
+
 -- auto-generated definition
 create table users
 (
@@ -55,9 +138,18 @@ create table users
     constraint user_pkey
     primary key,
   password varchar(128) not null,
-  role     varchar(64),
+  role     varchar(64)
+    constraint users_roles_name_fk
+    references roles (name),
   force_password_reset boolean default false,
-  active boolean default true
+  active boolean default true,
+  service_account boolean default false,
+  totp_secret varchar(512) default '',
+  totp_enabled boolean default false,
+  recovery_codes varchar(64)[] default '{}',
+  pending boolean default false,
+  oidc_issuer varchar(512) default '',
+  oidc_subject varchar(255) default ''
 );
 
 alter table users
@@ -69,6 +161,52 @@ create unique index user_id_uindex
 create unique index user_username_uindex
   on users (username);
 
+create unique index user_oidc_subject_uindex
+  on users (oidc_issuer, oidc_subject)
+  where oidc_subject <> '';
+
+-- auto-generated definition
+create table organization_members
+(
+    org_id       integer     not null
+        constraint organization_members_organizations_id_fk
+            references organizations (id)
+            on delete cascade,
+    user_id      integer     not null
+        constraint organization_members_users_id_fk
+            references users (id)
+            on delete cascade,
+    role         varchar(64)
+        constraint organization_members_roles_name_fk
+            references roles (name),
+    created_date timestamp   default CURRENT_TIMESTAMP,
+    constraint organization_members_pk
+        primary key (org_id, user_id)
+);
+
+alter table organization_members
+    owner to postgres;
+
+-- auto-generated definition
+create table quotas
+(
+    org_id                    integer      not null
+        constraint quotas_organizations_id_fk
+            references organizations (id)
+            on delete cascade,
+    username                  varchar(128) default '' not null,
+    max_concurrent_jobs       integer      default 0 not null,
+    jobs_per_day              integer      default 0 not null,
+    encoded_minutes_per_month integer      default 0 not null,
+    max_source_size           bigint       default 0 not null,
+    updated_date              timestamp    default CURRENT_TIMESTAMP,
+    constraint quotas_pk
+        primary key (org_id, username)
+);
+
+alter table quotas
+    owner to postgres;
+
 -- auto-generated definition
 create table settings_option
 (
@@ -96,7 +234,11 @@ create table settings
     id                 serial  not null
         constraint settings_pk
             primary key,
-    encrypted          boolean default false
+    encrypted          boolean default false,
+    org_id             integer default 1 not null
+        constraint settings_organizations_id_fk
+            references organizations (id)
+            on delete cascade
 );
 
 alter table settings
@@ -105,6 +247,9 @@ alter table settings
 create unique index settings_id_uindex
     on settings (id);
 
+create unique index settings_org_id_settings_option_id_uindex
+    on settings (org_id, settings_option_id);
+
 
 -- auto-generated definition
 create table presets
@@ -116,7 +261,11 @@ create table presets
     description varchar,
     data        varchar,
     active      boolean default false,
-    output      varchar(128)
+    output      varchar(128),
+    org_id      integer default 1 not null
+        constraint presets_organizations_id_fk
+            references organizations (id)
+            on delete cascade
 );
 
 alter table presets
@@ -125,3 +274,321 @@ alter table presets
 create unique index presets_id_uindex
     on presets (id);
 
+create index presets_org_id_name_index
+    on presets (org_id, name);
+
+
+
+-- auto-generated definition
+create table storage_profiles
+(
+    id              serial       not null
+        constraint storage_profiles_pk
+            primary key,
+    name            varchar(128) not null,
+    driver          varchar(64)  not null,
+    provider        varchar(64)  default '',
+    endpoint        varchar(256) default '',
+    region          varchar(64)  default '',
+    access_key      varchar(512) default '',
+    secret_key      varchar(512) default '',
+    inbound_bucket  varchar(256) default '',
+    outbound_bucket varchar(256) default '',
+    ftp_addr        varchar(256) default '',
+    ftp_username    varchar(512) default '',
+    ftp_password    varchar(512) default '',
+    created_date    timestamp    default CURRENT_TIMESTAMP,
+    org_id          integer      default 1 not null
+        constraint storage_profiles_organizations_id_fk
+            references organizations (id)
+            on delete cascade
+);
+
+alter table storage_profiles
+    owner to postgres;
+
+create unique index storage_profiles_org_id_name_uindex
+    on storage_profiles (org_id, name);
+
+-- auto-generated definition
+create table watchers
+(
+    id               serial       not null
+        constraint watchers_pk
+            primary key,
+    name             varchar(128) not null,
+    profile          varchar(128) default '',
+    prefix           varchar(256) default '',
+    glob             varchar(128) default '*',
+    presets          varchar(128)[] not null,
+    destination      varchar(128) not null,
+    dest_profile     varchar(128) default '',
+    active           boolean      default true,
+    created_date     timestamp    default CURRENT_TIMESTAMP,
+    last_scan_date   timestamp,
+    last_scan_status varchar(64)  default '',
+    last_scan_error  varchar(1024) default '',
+    last_scan_jobs   integer      default 0,
+    org_id           integer      default 1 not null
+        constraint watchers_organizations_id_fk
+            references organizations (id)
+            on delete cascade
+);
+
+alter table watchers
+    owner to postgres;
+
+-- auto-generated definition
+create table watcher_objects
+(
+    id           serial       not null
+        constraint watcher_objects_pk
+            primary key,
+    watcher_id   integer      not null
+        constraint watcher_objects_watchers_id_fk
+            references watchers (id)
+            on delete cascade,
+    key          varchar(1024) not null,
+    etag         varchar(128) not null,
+    preset       varchar(128) not null default '',
+    created_date timestamp    default CURRENT_TIMESTAMP
+);
+
+alter table watcher_objects
+    owner to postgres;
+
+create unique index watcher_objects_watcher_id_key_etag_preset_uindex
+    on watcher_objects (watcher_id, key, etag, preset);
+
+-- auto-generated definition
+create table ingest_rules
+(
+    id             serial       not null
+        constraint ingest_rules_pk
+            primary key,
+    name           varchar(128) not null,
+    bucket         varchar(256) not null,
+    prefix         varchar(256) default '',
+    glob           varchar(128) default '*',
+    presets        varchar(128)[] not null,
+    source_profile varchar(128) default '',
+    destination    varchar(128) not null,
+    dest_profile   varchar(128) default '',
+    active         boolean      default true,
+    created_date   timestamp    default CURRENT_TIMESTAMP,
+    org_id         integer      default 1 not null
+        constraint ingest_rules_organizations_id_fk
+            references organizations (id)
+            on delete cascade
+);
+
+alter table ingest_rules
+    owner to postgres;
+
+create index ingest_rules_bucket_index
+    on ingest_rules (bucket);
+
+-- auto-generated definition
+create table ingest_events
+(
+    id           serial        not null
+        constraint ingest_events_pk
+            primary key,
+    bucket       varchar(256)  not null,
+    key          varchar(1024) not null,
+    event_id     varchar(128)  not null,
+    rule_id      integer       not null,
+    preset       varchar(128)  not null,
+    created_date timestamp     default CURRENT_TIMESTAMP
+);
+
+alter table ingest_events
+    owner to postgres;
+
+create unique index ingest_events_bucket_key_event_id_rule_id_preset_uindex
+    on ingest_events (bucket, key, event_id, rule_id, preset);
+
+-- auto-generated definition
+create table webhooks
+(
+    id           serial        not null
+        constraint webhooks_pk
+            primary key,
+    url          varchar(1024) not null,
+    secret       varchar(512)  not null,
+    events       varchar(64)[] not null,
+    active       boolean       default true,
+    created_date timestamp     default CURRENT_TIMESTAMP
+);
+
+alter table webhooks
+    owner to postgres;
+
+-- auto-generated definition
+create table webhook_deliveries
+(
+    id            serial        not null
+        constraint webhook_deliveries_pk
+            primary key,
+    webhook_id    integer
+        constraint webhook_deliveries_webhooks_id_fk
+            references webhooks (id)
+            on delete cascade,
+    job_id        integer,
+    url           varchar(1024) not null,
+    event         varchar(64)   not null,
+    payload       json          not null,
+    status        varchar(64)   default 'pending',
+    attempts      integer       default 0,
+    response_code integer       default 0,
+    error         varchar(1024) default '',
+    created_date  timestamp     default CURRENT_TIMESTAMP,
+    updated_date  timestamp     default CURRENT_TIMESTAMP
+);
+
+alter table webhook_deliveries
+    owner to postgres;
+
+create index webhook_deliveries_webhook_id_index
+    on webhook_deliveries (webhook_id);
+
+-- auto-generated definition
+create table notification_channels
+(
+    id           serial        not null
+        constraint notification_channels_pk
+            primary key,
+    name         varchar(128)  not null,
+    type         varchar(64)   not null,
+    config       text          not null,
+    events       varchar(64)[] not null,
+    template     text          default '',
+    active       boolean       default true,
+    created_date timestamp     default CURRENT_TIMESTAMP
+);
+
+alter table notification_channels
+    owner to postgres;
+
+-- auto-generated definition
+create table workflows
+(
+    id           serial       not null
+        constraint workflows_pk
+            primary key,
+    name         varchar(128) not null,
+    created_by   varchar(128) default '',
+    created_date timestamp    default CURRENT_TIMESTAMP,
+    org_id       integer      default 1 not null
+        constraint workflows_organizations_id_fk
+            references organizations (id)
+            on delete cascade
+);
+
+alter table workflows
+    owner to postgres;
+
+-- auto-generated definition
+create table api_keys
+(
+    id             serial       not null
+        constraint api_keys_pk
+            primary key,
+    user_id        integer      not null
+        constraint api_keys_users_id_fk
+            references users (id)
+            on delete cascade,
+    name           varchar(128) not null,
+    prefix         varchar(16)  not null,
+    key_hash       varchar(64)  not null,
+    created_by     varchar(128) default '',
+    created_date   timestamp    default CURRENT_TIMESTAMP,
+    last_used_date timestamp,
+    expires_date   timestamp,
+    revoked        boolean      default false,
+    rate_limit     integer      default 0 not null,
+    org_id         integer      default 1 not null
+        constraint api_keys_organizations_id_fk
+            references organizations (id)
+            on delete cascade
+);
+
+alter table api_keys
+    owner to postgres;
+
+create unique index api_keys_key_hash_uindex
+    on api_keys (key_hash);
+
+create table invitations
+(
+    id           serial       not null
+        constraint invitations_pk
+            primary key,
+    token_hash   varchar(64)  not null,
+    email        varchar(256) default '',
+    role         varchar(64)  not null
+        constraint invitations_roles_name_fk
+            references roles (name),
+    org_id       integer      default 1 not null
+        constraint invitations_organizations_id_fk
+            references organizations (id)
+            on delete cascade,
+    created_by   varchar(128) default '',
+    created_date timestamp    default CURRENT_TIMESTAMP,
+    expires_date timestamp    not null,
+    used_by      varchar(128) default '',
+    used_date    timestamp,
+    revoked      boolean      default false
+);
+
+alter table invitations
+    owner to postgres;
+
+create unique index invitations_token_hash_uindex
+    on invitations (token_hash);
+
+
+-- auto-generated definition
+create table audit_log
+(
+    id           bigserial    not null
+        constraint audit_log_pk
+            primary key,
+    created_date timestamp    default CURRENT_TIMESTAMP,
+    actor        varchar(128) default '' not null,
+    org_id       integer,
+    ip           varchar(64)  default '' not null,
+    action       varchar(64)  not null,
+    target_type  varchar(64)  default '' not null,
+    target_id    varchar(128) default '' not null,
+    success      boolean      default true not null,
+    message      varchar(256) default '' not null,
+    changes      json
+);
+
+alter table audit_log
+    owner to postgres;
+
+create index audit_log_created_date_index
+    on audit_log (created_date);
+
+create index audit_log_actor_index
+    on audit_log (actor);
+
+create index audit_log_target_index
+    on audit_log (target_type, target_id);
+
+-- The audit log is append-only.
+create function audit_log_append_only() returns trigger as $$
+begin
+    raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+create trigger audit_log_append_only
+    before update or delete on audit_log
+    for each row execute procedure audit_log_append_only();
+
+create trigger audit_log_no_truncate
+    before truncate on audit_log
+    for each statement execute procedure audit_log_append_only();
@@ -0,0 +1,3 @@
+INSERT INTO public.organizations (id, name) VALUES (1, 'default');
+
+SELECT setval('organizations_id_seq', max(id)) FROM organizations;
@@ -16,5 +16,14 @@ INSERT INTO public.settings_option (id, name, description, title, secure) VALUES
 INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (16, 'DIGITAL_OCEAN_REGION', 'Digital Ocean Machines Region (Required for Machines)', 'Digital Ocean Region', false);
 INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (17, 'DIGITAL_OCEAN_ENABLED', 'Enable Digital Ocean Machines', 'Digital Ocean Machines', false);
 INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (18, 'DIGITAL_OCEAN_VPC', 'Enable Digital Ocean Machines VPC', 'Digital Ocean Machines VPC', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (19, 'WEBHOOK_SECRET', 'Secret used to sign job callback_url deliveries with HMAC-SHA256', 'Webhook Callback Secret', true);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (20, 'RETENTION_COMPLETED_DAYS', 'Days to keep completed jobs before they are purged. Leave blank to keep forever.', 'Completed Job Retention (Days)', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (21, 'RETENTION_FAILED_DAYS', 'Days to keep failed jobs before they are purged. Leave blank to keep forever.', 'Failed Job Retention (Days)', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (22, 'RETENTION_CANCELLED_DAYS', 'Days to keep cancelled jobs before they are purged. Leave blank to keep forever.', 'Cancelled Job Retention (Days)', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (23, 'RETENTION_DELETE_OUTPUTS', 'Delete the output files from storage when a job is purged', 'Delete Outputs on Purge', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (24, 'JOB_VISIBILITY', 'Jobs visible to guests and operators. Admins see all jobs.', 'Job Visibility', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (25, 'REQUIRE_ADMIN_TOTP', 'Require two-factor authentication for admins. Admins without it enrol on their next login.', 'Require Admin 2FA', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (26, 'REGISTRATION_MODE', 'Who can register: open to anyone, invite-only, or disabled.', 'Registration Mode', false);
+INSERT INTO public.settings_option (id, name, description, title, secure) VALUES (27, 'REGISTRATION_APPROVAL', 'Require an admin to approve users who register without an invitation.', 'Registration Approval', false);
 
 SELECT setval('settings_option_id_seq', max(id)) FROM settings_option;
\ No newline at end of file
@@ -5,5 +5,7 @@ INSERT INTO public.settings (id, value, settings_option_id, encrypted) VALUES (4
 INSERT INTO public.settings (id, value, settings_option_id, encrypted) VALUES (5, 'sfo2', 7, false);
 INSERT INTO public.settings (id, value, settings_option_id, encrypted) VALUES (6, 'disabled', 10, false);
 INSERT INTO public.settings (id, value, settings_option_id, encrypted) VALUES (7, 's3', 14, false);
+INSERT INTO public.settings (id, value, settings_option_id, encrypted) VALUES (8, 'invite', 26, false);
+INSERT INTO public.settings (id, value, settings_option_id, encrypted) VALUES (9, 'enabled', 27, false);
 
 SELECT setval('settings_id_seq', max(id)) FROM settings;
\ No newline at end of file
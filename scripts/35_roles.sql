@@ -0,0 +1,5 @@
+INSERT INTO public.roles (id, name, description, permissions, builtin) VALUES (1, 'admin', 'Full access.', '{*}', true);
+INSERT INTO public.roles (id, name, description, permissions, builtin) VALUES (2, 'operator', 'Runs jobs, and manages presets, watchers, ingest rules and machines.', '{jobs:read,jobs:create,jobs:update,jobs:cancel,jobs:restart,jobs:delete,presets:read,presets:write,storage:read,watchers:manage,ingest:manage,machines:read,machines:manage,workers:read,stats:read}', true);
+INSERT INTO public.roles (id, name, description, permissions, builtin) VALUES (3, 'guest', 'Read-only access to jobs and presets.', '{jobs:read,presets:read,storage:read,workers:read,stats:read}', true);
+
+SELECT setval('roles_id_seq', max(id)) FROM roles;
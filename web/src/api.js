@@ -30,10 +30,18 @@ const Endpoints = {
 
   Users: `${root}/users`,
   UsersId: id => `${root}/users/${id}`,
+  UsersApprove: id => `${root}/users/${id}/approve`,
+  Invitations: `${root}/invitations`,
+  InvitationsId: id => `${root}/invitations/${id}`,
+  Roles: `${root}/roles`,
 
   Settings: `${root}/settings`,
 
   CurrentUser: `${root}/me`,
+  CurrentUserPermissions: `${root}/me/permissions`,
+  CurrentUserOrgs: `${root}/me/orgs`,
+  CurrentUserOrg: `${root}/me/org`,
+  Usage: `${root}/usage`,
 };
 
 function get(context, url, callback) {
@@ -189,6 +197,26 @@ export default {
     return update(context, Endpoints.UsersId(data.id), data, callback);
   },
 
+  approveUser(context, id, callback) {
+    return post(context, Endpoints.UsersApprove(id), {}, callback);
+  },
+
+  getInvitations(context, callback) {
+    return get(context, Endpoints.Invitations, callback);
+  },
+
+  createInvitation(context, data, callback) {
+    return post(context, Endpoints.Invitations, data, callback);
+  },
+
+  revokeInvitation(context, id, callback) {
+    return del(context, Endpoints.InvitationsId(id), callback);
+  },
+
+  getRoles(context, callback) {
+    return get(context, Endpoints.Roles, callback);
+  },
+
   getSettings(context, callback) {
     return get(context, Endpoints.Settings, callback);
   },
@@ -204,4 +232,20 @@ export default {
   updateCurrentUser(context, data, callback) {
     return update(context, Endpoints.CurrentUser, data, callback);
   },
+
+  getCurrentUserPermissions(context, callback) {
+    return get(context, Endpoints.CurrentUserPermissions, callback);
+  },
+
+  getCurrentUserOrgs(context, callback) {
+    return get(context, Endpoints.CurrentUserOrgs, callback);
+  },
+
+  switchCurrentUserOrg(context, orgId, callback) {
+    return update(context, Endpoints.CurrentUserOrg, { org_id: orgId }, callback);
+  },
+
+  getUsage(context, callback) {
+    return get(context, Endpoints.Usage, callback);
+  },
 };
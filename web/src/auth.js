@@ -5,12 +5,16 @@ import store from './store';
 const LOGIN_URL = '/api/login';
 const REGISTER_URL = '/api/register';
 const UPDATE_PASSWORD_URL = '/api/update-password';
+const LOGOUT_URL = '/api/logout';
+const PERMISSIONS_URL = '/api/me/permissions';
 
 export default {
 
   user: {
     username: null,
     role: null,
+    orgId: null,
+    permissions: [],
     authenticated: false,
   },
 
@@ -22,6 +26,12 @@ export default {
       this.user.authenticated = true;
       this.user.username = jwtDecode(data.body.token).id;
 
+      // Show recovery codes from a two-factor enrolment before redirecting.
+      if (data.body.recovery_codes) {
+        callback(null, data.body);
+        return;
+      }
+
       if (redirect) {
         context.$router.push({ name: redirect });
         context.$router.go();
@@ -37,7 +47,7 @@ export default {
   },
 
   register(context, creds, redirect, callback) {
-    context.$http.post(REGISTER_URL, creds).then(() => {
+    context.$http.post(REGISTER_URL, creds).then((data) => {
       // TODO: Authenticate after registration?
       // cookie.set('token', data.body.token);
       // store.setTokenAction(data.body.token);
@@ -45,9 +55,11 @@ export default {
       // this.user.authenticated = true;
       // this.user.username = jwtDecode(data.body.token).id;
 
-      if (redirect) {
+      // Users pending approval stay to see the message.
+      if (redirect && !data.body.pending) {
         context.$router.push({ name: redirect });
       }
+      callback(null, data.body);
     }, (err) => {
       callback(err);
     });
@@ -65,10 +77,14 @@ export default {
 
 
   logout(context) {
-    cookie.remove('token');
-    this.user.authenticated = false;
-    context.$router.push({ name: 'login' });
-    context.$router.go();
+    // Revoke the token server-side, then clear it regardless of the result.
+    const done = () => {
+      cookie.remove('token');
+      this.user.authenticated = false;
+      context.$router.push({ name: 'login' });
+      context.$router.go();
+    };
+    context.$http.post(LOGOUT_URL, {}, { headers: this.getAuthHeader() }).then(done, done);
   },
 
   checkAuth(context) {
@@ -85,11 +101,30 @@ export default {
       this.user.authenticated = true;
       this.user.username = jwtDecode(jwt).id;
       this.user.role = jwtDecode(jwt).role;
+      this.user.orgId = jwtDecode(jwt).org;
+      this.getPermissions(context);
     } else if (context.$route.name !== 'login') {
       context.$router.push({ name: 'login' });
     }
   },
 
+  // Use the token of another organization and reload.
+  switchOrg(context, token) {
+    cookie.set('token', token);
+    store.setTokenAction(token);
+    context.$router.go();
+  },
+
+  getPermissions(context) {
+    context.$http.get(PERMISSIONS_URL, { headers: this.getAuthHeader() }).then((data) => {
+      this.user.permissions = data.body.permissions;
+    });
+  },
+
+  can(permission) {
+    return this.user.permissions.includes(permission);
+  },
+
   isExpired(jwt) {
     return Date.now() >= jwtDecode(jwt).exp * 1000;
   },